@@ -0,0 +1,67 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DomainPolicySpec defines the domains, issuers and delegated domains allowed for namespaces.
+type DomainPolicySpec struct {
+	// Namespaces is the list of names of the namespaces to which this policy applies.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector selects the namespaces to which this policy applies by their labels.
+	// A namespace is selected if it is listed in Namespaces or matches NamespaceSelector.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowedDomains is the list of domains for which DNS records and certificates can be generated.
+	// A hostname is allowed if it is equal to or under one of the domains.
+	// +kubebuilder:validation:MinItems=1
+	AllowedDomains []string `json:"allowedDomains"`
+
+	// AllowedIssuers is the list of issuers that can be used for certificates.
+	// If empty, any issuer is allowed.
+	// +optional
+	AllowedIssuers []IssuerReference `json:"allowedIssuers,omitempty"`
+
+	// AllowedDelegatedDomains is the list of domains to which DNS-01 validation can be delegated.
+	// If empty, any delegated domain allowed by the command-line flags is allowed.
+	// +optional
+	AllowedDelegatedDomains []string `json:"allowedDelegatedDomains,omitempty"`
+}
+
+// IssuerReference refers to a cert-manager Issuer or ClusterIssuer.
+type IssuerReference struct {
+	// Kind is the kind of the issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind"`
+
+	// Name is the name of the issuer.
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// DomainPolicy is the Schema for the domainpolicies API.
+// It restricts the hostnames, issuers and delegated domains that HTTPProxies in the selected namespaces can use.
+type DomainPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DomainPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DomainPolicyList contains a list of DomainPolicy
+type DomainPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DomainPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DomainPolicy{}, &DomainPolicyList{})
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/cybozu-go/contour-plus/controllers"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Explain how contour-plus handles the HTTPProxies in the cluster",
+	Long: `Explain how contour-plus handles the HTTPProxies in the cluster with the same flags as the controller.
+
+For each HTTPProxy, it lists the resources that would be generated and the reasons why the others are not.
+It also reports FQDN conflicts, the generated resources that are missing or differ from the desired ones,
+and the resources whose owners do not exist. It exits with an error if any problems are found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runDoctor(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+func runDoctor(ctx context.Context, w io.Writer) error {
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+
+	opts, err := parseReconcilerOptions()
+	if err != nil {
+		return err
+	}
+	if err := parseServiceKey(&opts); err != nil {
+		return err
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	report, err := controllers.Doctor(ctx, c, opts, scheme, ctrl.Log.WithName("doctor"))
+	if err != nil {
+		return err
+	}
+
+	for _, diag := range report.HTTPProxies {
+		fmt.Fprintf(w, "HTTPProxy %s\n", diag.ObjectKey)
+		for _, child := range diag.Children {
+			fmt.Fprintf(w, "  generates %s\n", child)
+		}
+		for _, note := range diag.Notes {
+			fmt.Fprintf(w, "  note: %s\n", note)
+		}
+		for _, problem := range diag.Problems {
+			fmt.Fprintf(w, "  PROBLEM: %s\n", problem)
+		}
+	}
+	if len(report.Orphans) != 0 {
+		fmt.Fprintln(w, "Orphans")
+		for _, orphan := range report.Orphans {
+			fmt.Fprintf(w, "  PROBLEM: %s\n", orphan)
+		}
+	}
+
+	if n := report.NumProblems(); n != 0 {
+		return fmt.Errorf("found %d problems", n)
+	}
+	return nil
+}
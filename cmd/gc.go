@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/cybozu-go/contour-plus/controllers"
+)
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete the generated resources whose owners do not exist",
+	Long: `Delete the DNSEndpoints, Certificates and TLSCertificateDelegations whose owners, i.e. the HTTPProxies
+or HTTPRoutes in the contour-plus.cybozu.com/owned-by annotation, do not exist, with the same flags as the controller.
+
+Only the resources in the namespaces of their owners or in the allowed namespaces are deleted.
+With --dry-run, the resources are deleted in dry-run mode only.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runGC(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+func runGC(ctx context.Context, w io.Writer) error {
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+
+	opts, err := parseReconcilerOptions()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	deleted, err := controllers.CollectGarbage(ctx, c, opts, scheme, ctrl.Log.WithName("gc"))
+	verb := "deleted"
+	if opts.DryRun {
+		verb = "would delete"
+	}
+	for _, desc := range deleted {
+		fmt.Fprintf(w, "%s %s\n", verb, desc)
+	}
+	return err
+}
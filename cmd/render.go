@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cybozu-go/contour-plus/controllers"
+)
+
+var renderOpts struct {
+	filename         string
+	serviceIPs       []string
+	serviceHostnames []string
+}
+
+func init() {
+	fs := renderCmd.Flags()
+	fs.StringVarP(&renderOpts.filename, "filename", "f", "", "YAML file of HTTPProxy resources. \"-\" reads the standard input")
+	fs.StringSliceVar(&renderOpts.serviceIPs, "service-ip", []string{}, "IP addresses of the load balancer of the Contour Service")
+	fs.StringSliceVar(&renderOpts.serviceHostnames, "service-hostname", []string{}, "Hostnames of the load balancer of the Contour Service")
+	if err := renderCmd.MarkFlagRequired("filename"); err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(renderCmd)
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the resources that would be generated for HTTPProxies in a file",
+	Long: `Print the DNSEndpoints, Certificates and TLSCertificateDelegations that would be generated
+for HTTPProxies in a file with the same flags as the controller, without accessing the API server.
+
+DomainPolicy, FQDN conflicts and the namespace selectors are not evaluated.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runRender(cmd.OutOrStdout())
+	},
+}
+
+func runRender(w io.Writer) error {
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	log := ctrl.Log.WithName("render")
+
+	opts, err := parseReconcilerOptions()
+	if err != nil {
+		return err
+	}
+
+	var serviceIPs []net.IP
+	for _, value := range renderOpts.serviceIPs {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("service-ip should be a list of IP addresses: %q", value)
+		}
+		serviceIPs = append(serviceIPs, ip)
+	}
+
+	hps, err := readHTTPProxies(renderOpts.filename)
+	if err != nil {
+		return err
+	}
+
+	first := true
+	for _, hp := range hps {
+		objs, err := controllers.Render(hp, opts, scheme, serviceIPs, renderOpts.serviceHostnames, log.WithValues("httpproxy", client.ObjectKeyFromObject(hp)))
+		if err != nil {
+			return fmt.Errorf("failed to render resources for HTTPProxy %s/%s: %w", hp.Namespace, hp.Name, err)
+		}
+		for _, obj := range objs {
+			data, err := marshalManifest(obj)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, "---\n"); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readHTTPProxies reads HTTPProxies from a file of YAML or JSON documents.
+// The namespace of an HTTPProxy defaults to "default" as kubectl does.
+func readHTTPProxies(filename string) ([]*projectcontourv1.HTTPProxy, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var hps []*projectcontourv1.HTTPProxy
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var content map[string]interface{}
+		err := decoder.Decode(&content)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: content}
+		if obj.GroupVersionKind() != projectcontourv1.GroupVersion.WithKind(controllers.HTTPProxyKind) {
+			return nil, fmt.Errorf("unsupported resource %s %s: only HTTPProxy is supported", obj.GetAPIVersion(), obj.GetKind())
+		}
+		hp := &projectcontourv1.HTTPProxy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, hp); err != nil {
+			return nil, err
+		}
+		if hp.Namespace == "" {
+			hp.Namespace = "default"
+		}
+		hps = append(hps, hp)
+	}
+	return hps, nil
+}
+
+// marshalManifest returns the YAML of obj without the fields set by the API server.
+func marshalManifest(obj client.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	delete(content, "status")
+	unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+	return yaml.Marshal(content)
+}
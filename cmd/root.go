@@ -32,10 +32,11 @@ func Execute() {
 func init() {
 	controllers.SetupScheme(scheme)
 
-	fs := rootCmd.Flags()
+	fs := rootCmd.PersistentFlags()
 	fs.String("metrics-addr", ":8180", "Bind address for the metrics endpoint")
 	fs.StringSlice("crds", []string{controllers.DNSEndpointKind, controllers.CertificateKind}, "List of CRD names to be created")
 	fs.String("name-prefix", "", "Prefix of CRD names to be created")
+	fs.String("previous-name-prefix", "", "Previous value of name-prefix. If specified, the resources named with it are replaced by those named with name-prefix")
 	fs.String("service-name", "", "NamespacedName of the Contour LoadBalancer Service")
 	fs.String("default-issuer-name", "", "Issuer name used by default")
 	fs.String("default-issuer-kind", controllers.ClusterIssuerKind, "Issuer kind used by default")
@@ -44,14 +45,33 @@ func init() {
 	fs.Bool("allow-custom-delegations", false, "Allow custom delegated domains via annotations")
 	fs.Uint("csr-revision-limit", 0, "Maximum number of CertificateRequest revisions to keep")
 	fs.String("ingress-class-name", "", "Ingress class name that watched by Contour Plus. If not specified, then all classes are watched")
+	fs.StringSlice("ingress-class-services", []string{}, "List of additional ingress class names to be watched with the NamespacedNames of their Envoy Services in the form of <class>=<namespace>/<name>")
+	fs.StringSlice("allowed-target-services", []string{}, "List of NamespacedNames of Services that can be specified as the target of DNS records via annotations")
+	fs.StringSlice("allowed-dns-target-cidrs", []string{}, "List of CIDRs of IP addresses that can be specified as the target of DNS records via annotations")
+	fs.StringSlice("allowed-dns-target-domains", []string{}, "List of parent domains of hostnames that can be specified as the target of DNS records via annotations")
+	fs.String("fqdn-conflict-policy", controllers.FQDNConflictPolicyNone, "Policy to resolve HTTPProxies with the same FQDN: none or oldest")
+	fs.Bool("enable-domain-policy", false, "Restrict hostnames, issuers and delegated domains by DomainPolicy resources")
+	fs.Bool("dry-run", false, "Make changes in dry-run mode only and log the differences from the live resources")
+	fs.Duration("gc-interval", 0, "Interval of deleting the generated resources whose owners do not exist (0 disables it)")
+	fs.String("allowed-dns-namespace-selector", "", "Label selector of namespaces where DNSEndpoint resources can be created in addition to allowed-dns-namespaces")
+	fs.String("allowed-issuer-namespace-selector", "", "Label selector of namespaces where Certificate resources can be created in addition to allowed-issuer-namespaces")
 	fs.Bool("leader-election", true, "Enable/disable leader election")
 	fs.StringSlice("propagated-annotations", []string{}, "List of annotation keys to be propagated from HTTPProxy to generated resources")
 	fs.StringSlice("propagated-labels", []string{}, "List of label keys to be propagated from HTTPProxy to generated resources")
 	fs.StringSlice("allowed-dns-namespaces", []string{}, "List of namespaces where DNSEndpoint resources can be created. If empty, no namespaces are allowed")
 	fs.StringSlice("allowed-issuer-namespaces", []string{}, "List of namespaces where Certificate resources can be created. If empty, no namespaces are allowed")
 	fs.Float64("certificate-apply-limit", 0, "Maximum number of certificate apply operations allowed per second (0 disables rate limiting)")
+	fs.StringSlice("certificate-apply-issuer-limits", []string{}, "List of maximum numbers of certificate apply operations allowed per second for issuers in the form of <kind>/<name>=<limit>. Issuers not listed use certificate-apply-limit (0 disables rate limiting)")
 	fs.Duration("certificate-apply-retry-base-delay", controllers.DefaultRetryBaseDelay, "Base delay for certificate apply exponential backoff retry")
 	fs.Duration("certificate-apply-retry-max-delay", controllers.DefaultRetryMaxDelay, "Maximum delay for certificate apply exponential backoff retry")
+	fs.Float64("dnsendpoint-apply-limit", 0, "Maximum number of DNSEndpoint apply operations allowed per second (0 disables rate limiting)")
+	fs.Duration("dnsendpoint-apply-retry-base-delay", controllers.DefaultRetryBaseDelay, "Base delay for DNSEndpoint apply exponential backoff retry")
+	fs.Duration("dnsendpoint-apply-retry-max-delay", controllers.DefaultRetryMaxDelay, "Maximum delay for DNSEndpoint apply exponential backoff retry")
+	fs.Bool("watch-httproute", false, "Watch Gateway API HTTPRoute in addition to HTTPProxy")
+	fs.Int64("default-dns-ttl", controllers.DefaultDNSTTL, "TTL of DNS records in seconds used by default")
+	fs.Duration("default-certificate-duration", 0, "Duration of Certificates used by default. If 0, the default of cert-manager is used")
+	fs.Duration("default-certificate-renew-before", 0, "Time before expiry to renew Certificates used by default. If 0, the default of cert-manager is used")
+	fs.StringSlice("allowed-additional-dns-domains", []string{}, "List of parent domains of DNS names that can be added via annotations. If empty, no additional DNS names are allowed")
 	if err := viper.BindPFlags(fs); err != nil {
 		panic(err)
 	}
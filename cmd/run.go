@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -27,14 +32,79 @@ func init() {
 func run() error {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
 
+	opts, err := parseReconcilerOptions()
+	if err != nil {
+		return err
+	}
+
+	if err := parseServiceKey(&opts); err != nil {
+		return err
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme: scheme,
+		Metrics: metricsserver.Options{
+			BindAddress: viper.GetString("metrics-addr"),
+		},
+		LeaderElection:   viper.GetBool("leader-election"),
+		LeaderElectionID: "contour-plus-leader",
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		return err
+	}
+
+	err = controllers.SetupReconciler(mgr, mgr.GetScheme(), opts)
+	if err != nil {
+		setupLog.Error(err, "unable to create controllers")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+	return nil
+}
+
+// parseServiceKey parses service-name into opts.ServiceKey.
+// service-name can be omitted if all the ingress classes are specified by ingress-class-services.
+func parseServiceKey(opts *controllers.ReconcilerOptions) error {
+	serviceName := viper.GetString("service-name")
+	if serviceName != "" || len(opts.IngressClassServiceKeys) == 0 {
+		serviceKey, err := parseServiceName(serviceName)
+		if err != nil {
+			return errors.New("service-name should be valid string as namespaced-name")
+		}
+		opts.ServiceKey = serviceKey
+	}
+	if len(opts.IngressClassServiceKeys) != 0 && (serviceName == "") != (opts.IngressClassName == "") {
+		return errors.New("service-name and ingress-class-name should be specified together with ingress-class-services")
+	}
+	return nil
+}
+
+// parseReconcilerOptions parses the flags of ReconcilerOptions except for service-name,
+// which is required only by the controllers.
+func parseReconcilerOptions() (controllers.ReconcilerOptions, error) {
 	opts := controllers.ReconcilerOptions{
 		Prefix:            viper.GetString("name-prefix"),
 		DefaultIssuerName: viper.GetString("default-issuer-name"),
 	}
 
+	// the previous prefix may be empty, so the migration is enabled by specifying it
+	if viper.IsSet("previous-name-prefix") {
+		opts.PreviousPrefix = viper.GetString("previous-name-prefix")
+		opts.MigrateNamePrefix = true
+		if opts.PreviousPrefix == opts.Prefix {
+			return opts, errors.New("previous-name-prefix must be different from name-prefix")
+		}
+	}
+
 	crds := viper.GetStringSlice("crds")
 	if len(crds) == 0 {
-		return errors.New("at least one service need to be enabled")
+		return opts, errors.New("at least one service need to be enabled")
 	}
 	for _, crd := range crds {
 		switch crd {
@@ -43,29 +113,61 @@ func run() error {
 		case controllers.CertificateKind:
 			opts.CreateCertificate = true
 		default:
-			return errors.New("unsupported CRD: " + crd)
+			return opts, errors.New("unsupported CRD: " + crd)
 		}
 	}
 
-	serviceName := viper.GetString("service-name")
-	nsname := strings.Split(serviceName, "/")
-	if len(nsname) != 2 || nsname[0] == "" || nsname[1] == "" {
-		return errors.New("service-name should be valid string as namespaced-name")
+	opts.IngressClassName = viper.GetString("ingress-class-name")
+	classServices, err := parseIngressClassServices(viper.GetStringSlice("ingress-class-services"))
+	if err != nil {
+		return opts, err
 	}
-	opts.ServiceKey = client.ObjectKey{
-		Namespace: nsname[0],
-		Name:      nsname[1],
+	if _, ok := classServices[opts.IngressClassName]; ok {
+		return opts, errors.New("ingress-class-services should not contain ingress-class-name")
+	}
+	opts.IngressClassServiceKeys = classServices
+
+	for _, targetService := range viper.GetStringSlice("allowed-target-services") {
+		serviceKey, err := parseServiceName(targetService)
+		if err != nil {
+			return opts, fmt.Errorf("allowed-target-services should be a list of namespaced-names: %w", err)
+		}
+		opts.AllowedTargetServices = append(opts.AllowedTargetServices, serviceKey)
 	}
 
+	for _, cidr := range viper.GetStringSlice("allowed-dns-target-cidrs") {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return opts, fmt.Errorf("allowed-dns-target-cidrs should be a list of CIDRs: %w", err)
+		}
+		opts.AllowedDNSTargetCIDRs = append(opts.AllowedDNSTargetCIDRs, ipNet)
+	}
+	opts.AllowedDNSTargetDomains = viper.GetStringSlice("allowed-dns-target-domains")
+
 	defaultIssuerKind := viper.GetString("default-issuer-kind")
 	switch defaultIssuerKind {
 	case controllers.IssuerKind, controllers.ClusterIssuerKind:
 	default:
-		return errors.New("unsupported Issuer kind: " + defaultIssuerKind)
+		return opts, errors.New("unsupported Issuer kind: " + defaultIssuerKind)
 	}
 	opts.DefaultIssuerKind = defaultIssuerKind
 
-	opts.IngressClassName = viper.GetString("ingress-class-name")
+	fqdnConflictPolicy := viper.GetString("fqdn-conflict-policy")
+	switch fqdnConflictPolicy {
+	case controllers.FQDNConflictPolicyNone, controllers.FQDNConflictPolicyOldest:
+	default:
+		return opts, errors.New("unsupported FQDN conflict policy: " + fqdnConflictPolicy)
+	}
+	opts.FQDNConflictPolicy = fqdnConflictPolicy
+
+	opts.EnableDomainPolicy = viper.GetBool("enable-domain-policy")
+
+	opts.DryRun = viper.GetBool("dry-run")
+
+	opts.GarbageCollectionInterval = viper.GetDuration("gc-interval")
+	if opts.GarbageCollectionInterval < 0 {
+		return opts, errors.New("gc-interval must be greater than or equal to 0")
+	}
 
 	opts.CSRRevisionLimit = viper.GetUint("csr-revision-limit")
 
@@ -76,48 +178,143 @@ func run() error {
 	opts.AllowCustomDelegations = viper.GetBool("allow-custom-delegations")
 	opts.AllowedDelegatedDomains = viper.GetStringSlice("allowed-delegated-domains")
 
+	opts.AllowedAdditionalDNSDomains = viper.GetStringSlice("allowed-additional-dns-domains")
 	opts.AllowedDNSNamespaces = viper.GetStringSlice("allowed-dns-namespaces")
 	opts.AllowedIssuerNamespaces = viper.GetStringSlice("allowed-issuer-namespaces")
+	opts.AllowedDNSNamespaceSelector, err = parseNamespaceSelector(viper.GetString("allowed-dns-namespace-selector"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid allowed-dns-namespace-selector: %w", err)
+	}
+	opts.AllowedIssuerNamespaceSelector, err = parseNamespaceSelector(viper.GetString("allowed-issuer-namespace-selector"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid allowed-issuer-namespace-selector: %w", err)
+	}
 	opts.CertificateApplyLimit = viper.GetFloat64("certificate-apply-limit")
 	if opts.CertificateApplyLimit < 0 {
-		return errors.New("certificate-apply-limit must be greater than or equal to 0")
+		return opts, errors.New("certificate-apply-limit must be greater than or equal to 0")
+	}
+	opts.CertificateApplyIssuerLimits, err = parseIssuerLimits(viper.GetStringSlice("certificate-apply-issuer-limits"))
+	if err != nil {
+		return opts, err
 	}
 
 	opts.CertificateApplyRetryBaseDelay = viper.GetDuration("certificate-apply-retry-base-delay")
 	if opts.CertificateApplyRetryBaseDelay <= 0 {
-		return errors.New("certificate-apply-retry-base-delay must be greater than 0")
+		return opts, errors.New("certificate-apply-retry-base-delay must be greater than 0")
 	}
 	opts.CertificateApplyRetryMaxDelay = viper.GetDuration("certificate-apply-retry-max-delay")
 	if opts.CertificateApplyRetryMaxDelay <= 0 {
-		return errors.New("certificate-apply-retry-max-delay must be greater than 0")
+		return opts, errors.New("certificate-apply-retry-max-delay must be greater than 0")
 	}
 	if opts.CertificateApplyRetryMaxDelay < opts.CertificateApplyRetryBaseDelay {
-		return errors.New("certificate-apply-retry-max-delay must be greater than or equal to certificate-apply-retry-base-delay")
+		return opts, errors.New("certificate-apply-retry-max-delay must be greater than or equal to certificate-apply-retry-base-delay")
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: viper.GetString("metrics-addr"),
-		},
-		LeaderElection:   viper.GetBool("leader-election"),
-		LeaderElectionID: "contour-plus-leader",
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		return err
+	opts.DNSEndpointApplyLimit = viper.GetFloat64("dnsendpoint-apply-limit")
+	if opts.DNSEndpointApplyLimit < 0 {
+		return opts, errors.New("dnsendpoint-apply-limit must be greater than or equal to 0")
+	}
+	opts.DNSEndpointApplyRetryBaseDelay = viper.GetDuration("dnsendpoint-apply-retry-base-delay")
+	if opts.DNSEndpointApplyRetryBaseDelay <= 0 {
+		return opts, errors.New("dnsendpoint-apply-retry-base-delay must be greater than 0")
+	}
+	opts.DNSEndpointApplyRetryMaxDelay = viper.GetDuration("dnsendpoint-apply-retry-max-delay")
+	if opts.DNSEndpointApplyRetryMaxDelay <= 0 {
+		return opts, errors.New("dnsendpoint-apply-retry-max-delay must be greater than 0")
+	}
+	if opts.DNSEndpointApplyRetryMaxDelay < opts.DNSEndpointApplyRetryBaseDelay {
+		return opts, errors.New("dnsendpoint-apply-retry-max-delay must be greater than or equal to dnsendpoint-apply-retry-base-delay")
 	}
 
-	err = controllers.SetupReconciler(mgr, mgr.GetScheme(), opts)
-	if err != nil {
-		setupLog.Error(err, "unable to create controllers")
-		os.Exit(1)
+	opts.WatchHTTPRoute = viper.GetBool("watch-httproute")
+
+	opts.DefaultDNSTTL = viper.GetInt64("default-dns-ttl")
+	if opts.DefaultDNSTTL <= 0 || opts.DefaultDNSTTL > math.MaxInt32 {
+		return opts, errors.New("default-dns-ttl must be greater than 0 and less than or equal to 2147483647")
 	}
 
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+	opts.DefaultCertificateDuration = viper.GetDuration("default-certificate-duration")
+	if opts.DefaultCertificateDuration < 0 {
+		return opts, errors.New("default-certificate-duration must be greater than or equal to 0")
 	}
-	return nil
+	opts.DefaultCertificateRenewBefore = viper.GetDuration("default-certificate-renew-before")
+	if opts.DefaultCertificateRenewBefore < 0 {
+		return opts, errors.New("default-certificate-renew-before must be greater than or equal to 0")
+	}
+	if opts.DefaultCertificateDuration > 0 && opts.DefaultCertificateRenewBefore >= opts.DefaultCertificateDuration {
+		return opts, errors.New("default-certificate-renew-before must be less than default-certificate-duration")
+	}
+
+	return opts, nil
+}
+
+// parseServiceName parses a NamespacedName of a Service in the form of <namespace>/<name>.
+func parseServiceName(serviceName string) (client.ObjectKey, error) {
+	nsname := strings.Split(serviceName, "/")
+	if len(nsname) != 2 || nsname[0] == "" || nsname[1] == "" {
+		return client.ObjectKey{}, fmt.Errorf("invalid namespaced-name: %q", serviceName)
+	}
+	return client.ObjectKey{
+		Namespace: nsname[0],
+		Name:      nsname[1],
+	}, nil
+}
+
+// parseIngressClassServices parses a list of ingress class names and Service names in the form of <class>=<namespace>/<name>.
+func parseIngressClassServices(values []string) (map[string]client.ObjectKey, error) {
+	classServices := make(map[string]client.ObjectKey, len(values))
+	for _, value := range values {
+		className, serviceName, ok := strings.Cut(value, "=")
+		if !ok || className == "" {
+			return nil, fmt.Errorf("ingress-class-services should be in the form of <class>=<namespace>/<name>: %q", value)
+		}
+		if _, ok := classServices[className]; ok {
+			return nil, fmt.Errorf("duplicate ingress class name in ingress-class-services: %q", className)
+		}
+		serviceKey, err := parseServiceName(serviceName)
+		if err != nil {
+			return nil, fmt.Errorf("ingress-class-services should be in the form of <class>=<namespace>/<name>: %w", err)
+		}
+		classServices[className] = serviceKey
+	}
+	return classServices, nil
+}
+
+// parseIssuerLimits parses the values of certificate-apply-issuer-limits into a map keyed by controllers.IssuerLimitKey.
+func parseIssuerLimits(values []string) (map[string]float64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	limits := make(map[string]float64, len(values))
+	for _, value := range values {
+		issuer, limitValue, ok := strings.Cut(value, "=")
+		kind, name, ok2 := strings.Cut(issuer, "/")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("certificate-apply-issuer-limits should be in the form of <kind>/<name>=<limit>: %q", value)
+		}
+		if kind != controllers.IssuerKind && kind != controllers.ClusterIssuerKind {
+			return nil, fmt.Errorf("invalid issuer kind in certificate-apply-issuer-limits: %q", kind)
+		}
+		key := controllers.IssuerLimitKey(kind, name)
+		if _, ok := limits[key]; ok {
+			return nil, fmt.Errorf("duplicate issuer in certificate-apply-issuer-limits: %q", issuer)
+		}
+		limit, err := strconv.ParseFloat(limitValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit in certificate-apply-issuer-limits: %w", err)
+		}
+		if limit < 0 {
+			return nil, fmt.Errorf("limit in certificate-apply-issuer-limits must be greater than or equal to 0: %q", value)
+		}
+		limits[key] = limit
+	}
+	return limits, nil
+}
+
+// parseNamespaceSelector parses a label selector of namespaces. An empty value selects no namespaces.
+func parseNamespaceSelector(value string) (labels.Selector, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return labels.Parse(value)
 }
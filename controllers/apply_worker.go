@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+type viaQueueValue string
+type applyResultValue string
+
+const (
+	labelViaQueue                       = "via_queue"
+	viaQueueYes        viaQueueValue    = "true"
+	viaQueueNo         viaQueueValue    = "false"
+	labelApplyResult                    = "result"
+	applyResultSuccess applyResultValue = "success"
+	applyResultError   applyResultValue = "error"
+
+	DefaultRetryBaseDelay = 5 * time.Second
+	DefaultRetryMaxDelay  = 10 * time.Minute
+)
+
+type Applier[T client.Object] interface {
+	Apply(ctx context.Context, obj T) error
+}
+
+// ApplyWorker is Applier with Start method so that it can be used directly by manager.Manager.Add
+// Implement ApplyWorker if the Applier requires a worker that runs in a background and start it via controller manager.
+type ApplyWorker[T client.Object] interface {
+	Applier[T]
+	// manager.Runnable defines signature for Start
+	manager.Runnable
+	// GetRetryChannel should return a receive only channel that can be used by the main reconciliation loop.
+	// For e.g. by WatchesRawSource and source.Channel in SetupWithManager to add a retry path.
+	GetRetryChannel() <-chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy]
+	// GetRouteRetryChannel is GetRetryChannel for HTTPRoute. It returns nil unless WatchHTTPRoute is true.
+	GetRouteRetryChannel() <-chan event.TypedGenericEvent[*gatewayv1.HTTPRoute]
+	// RegisterMetrics should register metrics that ApplyWorker records
+	RegisterMetrics(metrics.RegistererGatherer) error
+}
+
+// Forgetter is implemented by ApplyWorker that can drop the queued objects before they are applied.
+type Forgetter interface {
+	// Forget drops the queued objects owned by owner, the value of ownerAnnotation, unless keep returns true for them.
+	// It returns the keys of the dropped objects.
+	Forget(owner string, keep func(childKey) bool) []childKey
+}
+
+// applyWorker is the queue and the loop shared by the ApplyWorkers of each kind.
+// The objects queued by enqueue are applied in Start one by one as the workqueue releases their keys.
+// If an object fails to be applied, its owner is sent to retryCh or routeRetryCh so that it is reconciled again.
+type applyWorker[T client.Object] struct {
+	mu sync.Mutex
+	// name is the name of the workqueue and the controller label of the metrics
+	name string
+	// kind is the kind of T used in logs and events
+	kind   string
+	client client.Client
+	// recorder records events on the owner of the objects applied from the queue
+	recorder events.EventRecorder
+	// internal workqueue for rate limiting the changes
+	workqueue workqueue.TypedRateLimitingInterface[types.NamespacedName]
+	// manifests contains full client.Object that should be applied for the key
+	manifests map[types.NamespacedName]T
+	// channel for queueing HTTPProxy back into main reconcile loop for a retry
+	retryCh chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy]
+	// routeRetryCh is retryCh for HTTPRoute. It is nil unless HTTPRoute is watched.
+	routeRetryCh chan event.TypedGenericEvent[*gatewayv1.HTTPRoute]
+	// applyFunc applies an object to the API server
+	applyFunc func(context.Context, client.Client, T) error
+	// dequeueFunc is called with mu held when the manifest for a key is taken out of the queue.
+	// If it returns a positive delay, the key is put back to the queue after the delay instead of being applied.
+	// It may be nil.
+	dequeueFunc func(types.NamespacedName, T) time.Duration
+	// appliedTotal keeps track of the number of objects applied either via queue or directly.
+	appliedTotal *prometheus.CounterVec
+}
+
+func newApplyWorker[T client.Object](name, kind string, client client.Client, recorder events.EventRecorder, queue workqueue.TypedRateLimitingInterface[types.NamespacedName], applyFunc func(context.Context, client.Client, T) error, opt ReconcilerOptions) *applyWorker[T] {
+	w := &applyWorker[T]{
+		name:      name,
+		kind:      kind,
+		client:    client,
+		recorder:  recorder,
+		workqueue: queue,
+		manifests: make(map[types.NamespacedName]T),
+		retryCh:   make(chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy], 10),
+		applyFunc: applyFunc,
+	}
+	if opt.WatchHTTPRoute {
+		w.routeRetryCh = make(chan event.TypedGenericEvent[*gatewayv1.HTTPRoute], 10)
+	}
+	return w
+}
+
+// registerAppliedTotal registers the counter of the applied objects as metricName.
+func (w *applyWorker[T]) registerAppliedTotal(registry metrics.RegistererGatherer, metricName string) error {
+	appliedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricName,
+			Help: fmt.Sprintf("Total number of %s resources applied.", w.kind),
+		},
+		[]string{"controller", labelViaQueue, labelApplyResult},
+	)
+	if err := registerCollector(registry, appliedTotal); err != nil {
+		return err
+	}
+	w.appliedTotal = appliedTotal
+	return nil
+}
+
+// registerCollector registers c to registry unless it is already registered.
+// MustRegister cannot be used because controller-runtime uses global prometheus registry which cannot be reset
+// during testing.
+func registerCollector(registry metrics.RegistererGatherer, c prometheus.Collector) error {
+	if err := registry.Register(c); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDirectly applies obj without queueing.
+func (w *applyWorker[T]) applyDirectly(ctx context.Context, obj T) error {
+	log := crlog.FromContext(ctx)
+	objKey := client.ObjectKeyFromObject(obj)
+	if err := w.applyFunc(ctx, w.client, obj); err != nil {
+		log.Error(err, "apply failed", "kind", w.kind, "key", objKey.String())
+		w.recordApply(viaQueueNo, applyResultError)
+		return err
+	}
+	log.Info("applied without queueing", "kind", w.kind, "key", objKey.String())
+	w.recordApply(viaQueueNo, applyResultSuccess)
+	return nil
+}
+
+// enqueue queues obj to be applied. If obj is already queued, it is replaced with the new manifest.
+func (w *applyWorker[T]) enqueue(objKey types.NamespacedName, obj T) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.manifests[objKey] = obj
+	w.workqueue.AddRateLimited(objKey)
+}
+
+func (w *applyWorker[T]) Forget(owner string, keep func(childKey) bool) []childKey {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var forgotten []childKey
+	for objKey, obj := range w.manifests {
+		key := childKey{Kind: w.kind, ObjectKey: objKey}
+		if obj.GetAnnotations()[ownerAnnotation] != owner || keep(key) {
+			continue
+		}
+		// the key left in the workqueue is skipped when it is taken out
+		delete(w.manifests, objKey)
+		w.workqueue.Forget(objKey)
+		forgotten = append(forgotten, key)
+	}
+	return forgotten
+}
+
+// run applies the queued objects until ctx is done.
+func (w *applyWorker[T]) run(ctx context.Context) error {
+	log := crlog.FromContext(ctx)
+	go func() {
+		<-ctx.Done()
+		log.Info("context.Done received. Shutting down apply worker.", "kind", w.kind)
+		w.workqueue.ShutDown()
+	}()
+
+	for {
+		objKey, shutdown := w.workqueue.Get()
+		if shutdown {
+			return nil
+		}
+		log.Info("processing queue item", "kind", w.kind, "key", objKey.String())
+		w.processItem(ctx, objKey)
+	}
+}
+
+func (w *applyWorker[T]) processItem(ctx context.Context, objKey types.NamespacedName) {
+	log := crlog.FromContext(ctx)
+	defer w.workqueue.Done(objKey)
+
+	w.mu.Lock()
+	obj, ok := w.manifests[objKey]
+	if ok {
+		if w.dequeueFunc != nil {
+			if delay := w.dequeueFunc(objKey, obj); delay > 0 {
+				w.mu.Unlock()
+				w.workqueue.AddAfter(objKey, delay)
+				return
+			}
+		}
+		delete(w.manifests, objKey)
+	}
+	w.mu.Unlock()
+
+	if !ok {
+		log.Info("skipping forgotten queue item", "kind", w.kind, "key", objKey.String())
+		return
+	}
+
+	if ctx.Err() != nil {
+		log.Info("context cancelled, skipping apply", "kind", w.kind, "key", objKey.String())
+		return
+	}
+
+	if err := w.applyFunc(ctx, w.client, obj); err != nil {
+		log.Error(err, "apply from queue failed", "kind", w.kind, "key", objKey.String())
+		w.recordApply(viaQueueYes, applyResultError)
+		recordOwnerEvent(ctx, w.client, w.recorder, obj, corev1.EventTypeWarning, eventReasonApplyFailed, "failed to apply %s %s: %v", w.kind, objKey.String(), err)
+		w.enqueueOwner(ctx, obj)
+		return
+	}
+
+	log.Info("applied from queue", "kind", w.kind, "key", objKey.String())
+	w.recordApply(viaQueueYes, applyResultSuccess)
+	recordOwnerEvent(ctx, w.client, w.recorder, obj, corev1.EventTypeNormal, eventReasonApplied, "Applied %s %s from the rate-limited queue", w.kind, objKey.String())
+	// Forget the key to reset the exponential backoff counter so that
+	// a future failure starts from the base delay again.
+	w.workqueue.Forget(objKey)
+}
+
+func (w *applyWorker[T]) GetRetryChannel() <-chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy] {
+	return w.retryCh
+}
+
+func (w *applyWorker[T]) GetRouteRetryChannel() <-chan event.TypedGenericEvent[*gatewayv1.HTTPRoute] {
+	return w.routeRetryCh
+}
+
+// pendingManifests returns the number of objects waiting to be applied from the queue.
+func (w *applyWorker[T]) pendingManifests() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return float64(len(w.manifests))
+}
+
+func (w *applyWorker[T]) recordApply(viaQueue viaQueueValue, applyResult applyResultValue) {
+	if w.appliedTotal == nil {
+		return
+	}
+	w.appliedTotal.WithLabelValues(w.name, string(viaQueue), string(applyResult)).Inc()
+}
+
+// enqueueOwner sends the HTTPProxy or HTTPRoute that owns obj directly to the retry channel for its kind so the
+// reconcile loop picks it up. The exponential backoff is handled by the workqueue of the
+// worker when the reconcile loop applies obj again on subsequent failures.
+func (w *applyWorker[T]) enqueueOwner(ctx context.Context, obj T) {
+	log := crlog.FromContext(ctx)
+	key, ok := obj.GetAnnotations()[ownerAnnotation]
+	if !ok {
+		log.Error(fmt.Errorf("annotation %q not found on %s/%s", ownerAnnotation, obj.GetNamespace(), obj.GetName()), "skipping owner enqueue", "objectName", obj.GetName(), "objectNamespace", obj.GetNamespace())
+		return
+	}
+	owner, err := ownerFromKey(key)
+	if err != nil {
+		log.Error(err, "skipping owner enqueue", "objectName", obj.GetName(), "objectNamespace", obj.GetNamespace())
+		return
+	}
+	log.Info("re-queueing owner for retry", "owner", key)
+	switch owner := owner.(type) {
+	case *projectcontourv1.HTTPProxy:
+		sendRetry(ctx, w.retryCh, owner)
+	case *gatewayv1.HTTPRoute:
+		// routeRetryCh is nil unless HTTPRoute is watched
+		if w.routeRetryCh != nil {
+			sendRetry(ctx, w.routeRetryCh, owner)
+		}
+	}
+}
+
+func sendRetry[O client.Object](ctx context.Context, retryCh chan<- event.TypedGenericEvent[O], owner O) {
+	select {
+	case retryCh <- event.TypedGenericEvent[O]{Object: owner}:
+	case <-ctx.Done():
+	}
+}
+
+// recordOwnerEvent records an event on the HTTPProxy or HTTPRoute that owns obj. The owner is read with c.
+func recordOwnerEvent(ctx context.Context, c client.Client, recorder events.EventRecorder, obj client.Object, eventtype, reason, note string, args ...interface{}) {
+	if recorder == nil {
+		return
+	}
+	log := crlog.FromContext(ctx)
+	key, ok := obj.GetAnnotations()[ownerAnnotation]
+	if !ok {
+		return
+	}
+	owner, err := ownerFromKey(key)
+	if err != nil {
+		log.Error(err, "skipping event", "objectName", obj.GetName(), "objectNamespace", obj.GetNamespace())
+		return
+	}
+	// the owner must be read to fill its UID in the event
+	if err := c.Get(ctx, client.ObjectKeyFromObject(owner), owner); err != nil {
+		log.Error(err, "skipping event", "objectName", obj.GetName(), "objectNamespace", obj.GetNamespace())
+		return
+	}
+	recorder.Eventf(owner, obj, eventtype, reason, eventActionApply, note, args...)
+}
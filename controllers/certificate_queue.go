@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"container/heap"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// certificatePriority is the priority class of a Certificate in the apply queue.
+// Smaller values are dequeued first.
+type certificatePriority int
+
+const (
+	// priorityCritical is for the Certificates of owners annotated with criticalAnnotation
+	priorityCritical certificatePriority = iota
+	// priorityUpdate is for the spec changes of existing Certificates
+	priorityUpdate
+	// priorityCreate is for new Certificates
+	priorityCreate
+)
+
+var certificatePriorities = []certificatePriority{priorityCritical, priorityUpdate, priorityCreate}
+
+func (p certificatePriority) String() string {
+	switch p {
+	case priorityCritical:
+		return "critical"
+	case priorityUpdate:
+		return "update"
+	default:
+		return "create"
+	}
+}
+
+var _ workqueue.Queue[types.NamespacedName] = &priorityQueue{}
+
+// priorityQueue implements workqueue.Queue. Items are popped in the order of their priorities,
+// then in the order of their sequence numbers. The sequence number of an item is assigned when it
+// is pushed unless it is reserved by reserve beforehand.
+type priorityQueue struct {
+	mu sync.Mutex
+	// items is the heap of the items ready to be popped
+	items []types.NamespacedName
+	// index is the position of each item in items
+	index map[types.NamespacedName]int
+	// priorities holds the priorities of the items set by setPriority until forget is called
+	priorities map[types.NamespacedName]certificatePriority
+	// sequences holds the sequence numbers of the items reserved or pushed
+	sequences map[types.NamespacedName]uint64
+	next      uint64
+	// counts is the number of the items in priorities for each priority class
+	counts map[certificatePriority]int
+	// depth reports the number of the items with priorities for each priority class
+	depth *prometheus.GaugeVec
+}
+
+func newPriorityQueue() *priorityQueue {
+	return &priorityQueue{
+		index:      make(map[types.NamespacedName]int),
+		priorities: make(map[types.NamespacedName]certificatePriority),
+		sequences:  make(map[types.NamespacedName]uint64),
+		counts:     make(map[certificatePriority]int),
+	}
+}
+
+// setPriority sets the priority of item. It should be called before item is added to the workqueue.
+// The priority of a pending item is only raised, so that a critical item re-added as an update stays critical.
+func (q *priorityQueue) setPriority(item types.NamespacedName, priority certificatePriority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if old, ok := q.priorities[item]; ok {
+		if old <= priority {
+			return
+		}
+		q.counts[old]--
+	}
+	q.priorities[item] = priority
+	q.counts[priority]++
+	if i, ok := q.index[item]; ok {
+		heap.Fix((*priorityHeap)(q), i)
+	}
+	q.updateDepth()
+}
+
+// reserve assigns the next sequence number to item so that it precedes the items pushed later
+// with the same priority.
+func (q *priorityQueue) reserve(item types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.sequences[item]; ok {
+		return
+	}
+	q.sequences[item] = q.next
+	q.next++
+}
+
+// forget removes the priority and the sequence number of item.
+// It should be called when item is no longer pending.
+func (q *priorityQueue) forget(item types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if old, ok := q.priorities[item]; ok {
+		q.counts[old]--
+		delete(q.priorities, item)
+	}
+	if _, ok := q.index[item]; !ok {
+		delete(q.sequences, item)
+	}
+	q.updateDepth()
+}
+
+func (q *priorityQueue) setDepthMetric(depth *prometheus.GaugeVec) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.depth = depth
+	q.updateDepth()
+}
+
+func (q *priorityQueue) updateDepth() {
+	if q.depth == nil {
+		return
+	}
+	for _, priority := range certificatePriorities {
+		q.depth.WithLabelValues(certificateApplierName, priority.String()).Set(float64(q.counts[priority]))
+	}
+}
+
+func (q *priorityQueue) Touch(item types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, ok := q.index[item]; ok {
+		heap.Fix((*priorityHeap)(q), i)
+	}
+}
+
+func (q *priorityQueue) Push(item types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.sequences[item]; !ok {
+		q.sequences[item] = q.next
+		q.next++
+	}
+	heap.Push((*priorityHeap)(q), item)
+}
+
+func (q *priorityQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *priorityQueue) Pop() types.NamespacedName {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item := heap.Pop((*priorityHeap)(q)).(types.NamespacedName)
+	// the sequence number is kept while the item is pending so that it keeps its position when re-added
+	if _, ok := q.priorities[item]; !ok {
+		delete(q.sequences, item)
+	}
+	return item
+}
+
+// priorityHeap implements heap.Interface for priorityQueue. The caller must hold mu.
+type priorityHeap priorityQueue
+
+func (h *priorityHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *priorityHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	pa, ok := h.priorities[a]
+	if !ok {
+		pa = priorityCreate
+	}
+	pb, ok := h.priorities[b]
+	if !ok {
+		pb = priorityCreate
+	}
+	if pa != pb {
+		return pa < pb
+	}
+	return h.sequences[a] < h.sequences[b]
+}
+
+func (h *priorityHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i]] = i
+	h.index[h.items[j]] = j
+}
+
+func (h *priorityHeap) Push(x any) {
+	item := x.(types.NamespacedName)
+	h.index[item] = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *priorityHeap) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items = h.items[:n-1]
+	delete(h.index, item)
+	return item
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: "default", Name: name}
+	}
+
+	q := newPriorityQueue()
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_depth"}, []string{"controller", "priority"})
+	q.setDepthMetric(depth)
+
+	q.setPriority(key("create-1"), priorityCreate)
+	q.Push(key("create-1"))
+	q.setPriority(key("update-1"), priorityUpdate)
+	q.Push(key("update-1"))
+	q.setPriority(key("create-2"), priorityCreate)
+	q.Push(key("create-2"))
+	q.setPriority(key("critical"), priorityCritical)
+	q.Push(key("critical"))
+	q.setPriority(key("update-2"), priorityUpdate)
+	q.Push(key("update-2"))
+	// raising the priority of an item in the queue
+	q.setPriority(key("create-2"), priorityUpdate)
+	// the priority is not lowered when the item is re-queued as an update;
+	// the workqueue does not push the item again while it is in the queue
+	q.setPriority(key("critical"), priorityUpdate)
+
+	for priority, expected := range map[certificatePriority]float64{priorityCritical: 1, priorityUpdate: 3, priorityCreate: 1} {
+		got := testutil.ToFloat64(depth.WithLabelValues(certificateApplierName, priority.String()))
+		if got != expected {
+			t.Errorf("depth of %s = %v, want %v", priority, got, expected)
+		}
+	}
+
+	expected := []string{"critical", "update-1", "create-2", "update-2", "create-1"}
+	if q.Len() != len(expected) {
+		t.Fatalf("Len() = %d, want %d", q.Len(), len(expected))
+	}
+	for _, name := range expected {
+		got := q.Pop()
+		if got != key(name) {
+			t.Errorf("Pop() = %s, want %s", got, key(name))
+		}
+		q.forget(got)
+	}
+
+	for _, priority := range certificatePriorities {
+		if got := testutil.ToFloat64(depth.WithLabelValues(certificateApplierName, priority.String())); got != 0 {
+			t.Errorf("depth of %s = %v, want 0", priority, got)
+		}
+	}
+	if len(q.sequences) != 0 || len(q.index) != 0 {
+		t.Errorf("items are left after forget: %v, %v", q.sequences, q.index)
+	}
+
+	// an item re-added before forget keeps its position
+	q.setPriority(key("a"), priorityCreate)
+	q.Push(key("a"))
+	q.setPriority(key("b"), priorityCreate)
+	q.Push(key("b"))
+	if got := q.Pop(); got != key("a") {
+		t.Fatalf("Pop() = %s, want a", got)
+	}
+	q.Push(key("a"))
+	if got := q.Pop(); got != key("a") {
+		t.Errorf("Pop() = %s, want a", got)
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// pendingCertificates returns the Certificates that are missing or stale.
+// A Certificate is stale if applying the one derived from the owner would trigger re-issuance,
+// as judged by certificateReissued in the same way as CertificateApplyWorker.Apply.
+// The returned Certificates carry ownerAnnotation, but not the other ownership metadata.
+// They are ordered by the creation timestamps of the owners so that the result is deterministic.
+func (r *HTTPProxyReconciler) pendingCertificates(ctx context.Context) ([]*cmv1.Certificate, error) {
+	if !r.CreateCertificate {
+		return nil, nil
+	}
+
+	var owners []client.Object
+	var hpList projectcontourv1.HTTPProxyList
+	if err := r.List(ctx, &hpList); err != nil {
+		return nil, err
+	}
+	for i := range hpList.Items {
+		hp := &hpList.Items[i]
+		if !r.isReconcileTarget(hp) || getCertificateSecretName(r, hp) == "" {
+			continue
+		}
+		winner, _, err := r.findFQDNConflict(ctx, hp)
+		if err != nil {
+			return nil, err
+		}
+		if winner != nil {
+			continue
+		}
+		owners = append(owners, hp)
+	}
+	if r.WatchHTTPRoute {
+		var routeList gatewayv1.HTTPRouteList
+		if err := r.List(ctx, &routeList); err != nil {
+			return nil, err
+		}
+		for i := range routeList.Items {
+			route := &routeList.Items[i]
+			if route.DeletionTimestamp != nil || route.Annotations[excludeAnnotation] == "true" {
+				continue
+			}
+			if !r.watchesAllIngressClasses() && !r.matchIngressClassName(route.Annotations, "") {
+				continue
+			}
+			owners = append(owners, route)
+		}
+	}
+	slices.SortFunc(owners, compareOwners)
+
+	var certs []*cmv1.Certificate
+	for _, owner := range owners {
+		cert, err := r.staleCertificate(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// staleCertificate returns the Certificate for the owner if it is missing or would be re-issued when reconciled.
+// It returns nil if no Certificate is generated for the owner.
+func (r *HTTPProxyReconciler) staleCertificate(ctx context.Context, owner client.Object) (*cmv1.Certificate, error) {
+	if owner.GetAnnotations()[testACMETLSAnnotation] != "true" {
+		return nil, nil
+	}
+	issuerKind, issuerName := r.getIssuer(owner)
+	if issuerName == "" {
+		return nil, nil
+	}
+
+	var hostnames []string
+	var secretName string
+	switch o := owner.(type) {
+	case *projectcontourv1.HTTPProxy:
+		hostnames = proxyHostnames(o)
+		secretName = getCertificateSecretName(r, o)
+	case *gatewayv1.HTTPRoute:
+		hostnames = routeHostnames(o)
+		secretName = getCertificateName(r, o)
+	}
+	_, certHostnames := r.expandHostnames(owner, hostnames, r.Log)
+
+	rules, err := r.getDomainPolicyRules(ctx, owner.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	certHostnames = slices.DeleteFunc(certHostnames, func(name string) bool {
+		return !rules.permitsHostname(name)
+	})
+	if len(certHostnames) == 0 || secretName == "" {
+		return nil, nil
+	}
+	if !rules.permitsIssuer(issuerKind, issuerName, certHostnames) {
+		return nil, nil
+	}
+
+	cert, err := r.desiredCertificate(ctx, owner, certHostnames, secretName, issuerKind, issuerName, r.Log)
+	if err != nil || cert == nil {
+		return nil, err
+	}
+	// the annotations are shared with the secret template
+	annotations := maps.Clone(cert.GetAnnotations())
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ownerAnnotation] = getOwnerKey(owner)
+	cert.SetAnnotations(annotations)
+
+	current := new(cmv1.Certificate)
+	err = r.Get(ctx, client.ObjectKeyFromObject(cert), current)
+	if k8serrors.IsNotFound(err) {
+		return cert, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !certificateReissued(cert, current) {
+		return nil, nil
+	}
+	return cert, nil
+}
+
+// compareOwners orders owners by creation timestamp. Ties are broken by namespace and name.
+func compareOwners(a, b client.Object) int {
+	ta, tb := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !ta.Equal(&tb) {
+		if ta.Before(&tb) {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(a.GetNamespace(), b.GetNamespace()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.GetName(), b.GetName())
+}
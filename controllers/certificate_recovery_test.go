@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPendingCertificates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := projectcontourv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	newHP := func(name, fqdn string, created time.Time) *projectcontourv1.HTTPProxy {
+		hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: name})
+		hp.Spec.VirtualHost.Fqdn = fqdn
+		hp.CreationTimestamp = v1.NewTime(created)
+		return hp
+	}
+	newCert := func(name, fqdn, issuer string) *cmv1.Certificate {
+		return &cmv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: cmv1.CertificateSpec{
+				DNSNames:   []string{fqdn},
+				SecretName: testSecretName,
+				CommonName: fqdn,
+				IssuerRef:  cmmeta.IssuerReference{Kind: IssuerKind, Name: issuer},
+				Usages:     []cmv1.KeyUsage{cmv1.UsageDigitalSignature, cmv1.UsageKeyEncipherment, cmv1.UsageServerAuth},
+			},
+		}
+	}
+	// a Certificate is stale if any field of the spec that triggers re-issuance differs, as in CertificateApplyWorker.Apply
+	durationChanged := newCert("duration-changed", "duration-changed.example.com", "test-issuer")
+	durationChanged.Spec.Duration = &v1.Duration{Duration: time.Hour}
+
+	noACME := newHP("no-acme", "no-acme.example.com", now.Add(-5*time.Hour))
+	delete(noACME.Annotations, testACMETLSAnnotation)
+	excluded := newHP("excluded", "excluded.example.com", now.Add(-4*time.Hour))
+	excluded.Annotations[excludeAnnotation] = "true"
+
+	objs := []client.Object{
+		noACME,
+		excluded,
+		newHP("missing-new", "missing-new.example.com", now),
+		newHP("missing-old", "missing-old.example.com", now.Add(-time.Hour)),
+		newHP("up-to-date", "up-to-date.example.com", now.Add(-3*time.Hour)),
+		newCert("up-to-date", "up-to-date.example.com", "test-issuer"),
+		newHP("fqdn-changed", "new.example.com", now.Add(-2*time.Hour)),
+		newCert("fqdn-changed", "old.example.com", "test-issuer"),
+		newHP("issuer-changed", "issuer-changed.example.com", now),
+		newCert("issuer-changed", "issuer-changed.example.com", "old-issuer"),
+		newHP("duration-changed", "duration-changed.example.com", now.Add(-6*time.Hour)),
+		durationChanged,
+	}
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		ChildReader: c,
+		Log:         logr.Discard(),
+		ReconcilerOptions: ReconcilerOptions{
+			CreateCertificate: true,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+		},
+	}
+
+	certs, err := r.pendingCertificates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cert := range certs {
+		got = append(got, cert.Name)
+		if owner := cert.Annotations[ownerAnnotation]; owner != "default/"+cert.Name {
+			t.Errorf("owner of %s = %q, want default/%s", cert.Name, owner, cert.Name)
+		}
+	}
+	// ordered by creation timestamp, then by name
+	expected := []string{"duration-changed", "fqdn-changed", "missing-old", "issuer-changed", "missing-new"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("pendingCertificates() = %v, want %v", got, expected)
+	}
+
+	r.CreateCertificate = false
+	certs, err = r.pendingCertificates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 0 {
+		t.Errorf("pendingCertificates() = %v, want none if Certificates are not created", certs)
+	}
+}
+
+func TestCertificateApplyWorkerRecover(t *testing.T) {
+	keys := []types.NamespacedName{
+		{Namespace: "default", Name: "first"},
+		{Namespace: "default", Name: "second"},
+		{Namespace: "default", Name: "third"},
+	}
+	// the owners of these stop desiring the Certificates, or apply them without the queue
+	forgottenKey := types.NamespacedName{Namespace: "default", Name: "forgotten"}
+	directKey := types.NamespacedName{Namespace: "default", Name: "direct"}
+	w := NewCertificateApplyWorker(nil, nil, ReconcilerOptions{
+		CertificateApplyLimit:          1,
+		CertificateApplyRetryBaseDelay: time.Millisecond,
+		CertificateApplyRetryMaxDelay:  time.Millisecond,
+	})
+	defer w.workqueue.ShutDown()
+	w.SetRecoverFunc(func(context.Context) ([]*cmv1.Certificate, error) {
+		var certs []*cmv1.Certificate
+		for _, key := range append(slices.Clone(keys), forgottenKey, directKey) {
+			certs = append(certs, &cmv1.Certificate{ObjectMeta: v1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Annotations: map[string]string{ownerAnnotation: key.Namespace + "/" + key.Name},
+			}})
+		}
+		return certs, nil
+	})
+	w.recover(context.Background())
+
+	select {
+	case <-w.recovered:
+	default:
+		t.Fatal("recovered is not closed")
+	}
+
+	// the recovered Certificates are dequeued in the recovered order regardless of the order of the applies
+	newKey := types.NamespacedName{Namespace: "default", Name: "new"}
+	for _, key := range []types.NamespacedName{newKey, keys[2], keys[0], keys[1]} {
+		w.enqueueCertificate(key, &cmv1.Certificate{}, priorityCreate)
+	}
+	deadline := time.Now().Add(time.Second)
+	for w.workqueue.Len() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("workqueue.Len() = %d, want 4", w.workqueue.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := append(slices.Clone(keys), newKey)
+	for _, key := range expected {
+		got, _ := w.workqueue.Get()
+		if got != key {
+			t.Errorf("dequeued %s, want %s", got, key)
+		}
+		w.workqueue.Done(got)
+	}
+
+	// the reservations that are not used are released
+	w.Forget("default/forgotten", func(childKey) bool { return false })
+	w.release(directKey)
+	for _, key := range []types.NamespacedName{forgottenKey, directKey} {
+		_, sequenced := w.pending.sequences[key]
+		_, reserved := w.reserved[key]
+		if sequenced || reserved {
+			t.Errorf("reservation of %s is not released", key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// certificateStatusChanged passes the updates of Certificates that change the readiness, the expiry
+// or the result of the latest issuance. The other events are handled by ownChildren.
+var certificateStatusChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCert, ok := e.ObjectOld.(*cmv1.Certificate)
+		if !ok {
+			return false
+		}
+		newCert, ok := e.ObjectNew.(*cmv1.Certificate)
+		if !ok {
+			return false
+		}
+		if isCertificateReady(oldCert) != isCertificateReady(newCert) {
+			return true
+		}
+		if !oldCert.Status.NotAfter.Equal(newCert.Status.NotAfter) {
+			return true
+		}
+		return !oldCert.Status.LastFailureTime.Equal(newCert.Status.LastFailureTime)
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// listCertificateOwner returns the request for the HTTPProxy owning the Certificate.
+// Unlike the controller reference, ownerAnnotation is set on Certificates in other namespaces as well.
+func listCertificateOwner(ctx context.Context, obj client.Object) []reconcile.Request {
+	owner, err := ownerFromKey(obj.GetAnnotations()[ownerAnnotation])
+	if err != nil {
+		return nil
+	}
+	if _, ok := owner.(*projectcontourv1.HTTPProxy); !ok || owner.GetName() == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(owner)}}
+}
+
+// reportCertificateStatus reads the Certificate of the HTTPProxy and records a warning event if its issuance has failed
+// since the previous reconciliation.
+// It returns the Certificate, or nil if the HTTPProxy has no Certificate.
+func (r *HTTPProxyReconciler) reportCertificateStatus(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) (*cmv1.Certificate, error) {
+	if !r.CreateCertificate || hp.Annotations[testACMETLSAnnotation] != "true" {
+		return nil, nil
+	}
+
+	namespace, err := r.getCertificateNamespace(ctx, hp)
+	if err != nil {
+		return nil, err
+	}
+	cert := new(cmv1.Certificate)
+	key := client.ObjectKey{Namespace: namespace, Name: getCertificateName(r, hp)}
+	err = r.ChildReader.Get(ctx, key, cert)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cert.Annotations[ownerAnnotation] != getOwnerKey(hp) {
+		return nil, nil
+	}
+
+	// the failure is recorded once for each LastFailureTime
+	var failures []string
+	if cert.Status.LastFailureTime != nil && !isCertificateReady(cert) {
+		failures = append(failures, cert.Status.LastFailureTime.UTC().Format(time.RFC3339))
+	}
+	if len(r.warnings.update(getOwnerKey(hp), eventReasonIssuanceFailed, failures)) != 0 {
+		message := "unknown reason"
+		if cond := certificateCondition(cert, cmv1.CertificateConditionIssuing); cond != nil && cond.Message != "" {
+			message = cond.Message
+		}
+		log.Info("Certificate issuance failed", "certificate", key, "lastFailureTime", cert.Status.LastFailureTime)
+		r.recordEvent(hp, cert, corev1.EventTypeWarning, eventReasonIssuanceFailed, eventActionReconcile,
+			"Certificate %s failed to be issued: %s", key, message)
+	}
+	return cert, nil
+}
+
+// certificateCondition returns the condition of cert of the type, or nil if cert has none.
+func certificateCondition(cert *cmv1.Certificate, conditionType cmv1.CertificateConditionType) *cmv1.CertificateCondition {
+	for i := range cert.Status.Conditions {
+		if cert.Status.Conditions[i].Type == conditionType {
+			return &cert.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// isCertificateReady returns true if cert has the Ready condition of True.
+func isCertificateReady(cert *cmv1.Certificate) bool {
+	cond := certificateCondition(cert, cmv1.CertificateConditionReady)
+	return cond != nil && cond.Status == cmmeta.ConditionTrue
+}
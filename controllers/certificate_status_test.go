@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestCertificateStatusChanged(t *testing.T) {
+	now := v1.NewTime(time.Now().Truncate(time.Second))
+	later := v1.NewTime(now.Add(time.Hour))
+	newCert := func(ready cmmeta.ConditionStatus, notAfter, lastFailure *v1.Time) *cmv1.Certificate {
+		return &cmv1.Certificate{
+			Status: cmv1.CertificateStatus{
+				Conditions:      []cmv1.CertificateCondition{{Type: cmv1.CertificateConditionReady, Status: ready}},
+				NotAfter:        notAfter,
+				LastFailureTime: lastFailure,
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		old    *cmv1.Certificate
+		new    *cmv1.Certificate
+		expect bool
+	}{
+		{
+			name:   "no change",
+			old:    newCert(cmmeta.ConditionTrue, &now, nil),
+			new:    newCert(cmmeta.ConditionTrue, &now, nil),
+			expect: false,
+		},
+		{
+			name:   "ready",
+			old:    newCert(cmmeta.ConditionFalse, nil, nil),
+			new:    newCert(cmmeta.ConditionTrue, &now, nil),
+			expect: true,
+		},
+		{
+			name:   "renewed",
+			old:    newCert(cmmeta.ConditionTrue, &now, nil),
+			new:    newCert(cmmeta.ConditionTrue, &later, nil),
+			expect: true,
+		},
+		{
+			name:   "failed",
+			old:    newCert(cmmeta.ConditionFalse, nil, nil),
+			new:    newCert(cmmeta.ConditionFalse, nil, &now),
+			expect: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := certificateStatusChanged.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new})
+			if got != tt.expect {
+				t.Errorf("certificateStatusChanged.Update() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestReportCertificateStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := cmv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	hpKey := client.ObjectKey{Namespace: "default", Name: "foo"}
+	notAfter := v1.NewTime(time.Unix(1700000000, 0))
+	failed := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "foo",
+			Annotations: map[string]string{ownerAnnotation: "default/foo"},
+		},
+		Status: cmv1.CertificateStatus{
+			Conditions: []cmv1.CertificateCondition{
+				{Type: cmv1.CertificateConditionReady, Status: cmmeta.ConditionTrue},
+				{Type: cmv1.CertificateConditionIssuing, Status: cmmeta.ConditionFalse, Reason: "Failed", Message: "rate limited"},
+			},
+			NotAfter:        &notAfter,
+			LastFailureTime: ptr.To(v1.Now()),
+		},
+	}
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(failed).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	r := &HTTPProxyReconciler{
+		Client:            c,
+		ChildReader:       c,
+		Recorder:          recorder,
+		ReconcilerOptions: ReconcilerOptions{CreateCertificate: true},
+	}
+	hp := newDummyHTTPProxy(hpKey)
+
+	// the issuance failure of a Certificate which is still ready is not reported
+	cert, err := r.reportCertificateStatus(context.Background(), hp, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert == nil {
+		t.Fatal("reportCertificateStatus() returned nil")
+	}
+	setCertificateStatusMetrics(hpKey, cert)
+	if v := testutil.ToFloat64(certificateExpiryTimestamp.WithLabelValues("default", "foo")); v != 1700000000 {
+		t.Errorf("contour_plus_certificate_expiry_timestamp_seconds = %v, want 1700000000", v)
+	}
+	if v := testutil.ToFloat64(certificateReady.WithLabelValues("default", "foo")); v != 1 {
+		t.Errorf("contour_plus_certificate_ready = %v, want 1", v)
+	}
+
+	failed.Status.Conditions[0].Status = cmmeta.ConditionFalse
+	if err := c.Update(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = r.reportCertificateStatus(context.Background(), hp, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCertificateStatusMetrics(hpKey, cert)
+	if v := testutil.ToFloat64(certificateReady.WithLabelValues("default", "foo")); v != 0 {
+		t.Errorf("contour_plus_certificate_ready = %v, want 0", v)
+	}
+
+	// the same failure is reported only once, and a new failure is reported again
+	if _, err := r.reportCertificateStatus(context.Background(), hp, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	failed.Status.LastFailureTime = ptr.To(v1.NewTime(failed.Status.LastFailureTime.Add(time.Hour)))
+	failed.Status.Conditions[1].Message = "rate limited again"
+	if err := c.Update(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reportCertificateStatus(context.Background(), hp, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	close(recorder.Events)
+	var got []string
+	for e := range recorder.Events {
+		got = append(got, e)
+	}
+	expect := []string{
+		"Warning IssuanceFailed Certificate default/foo failed to be issued: rate limited",
+		"Warning IssuanceFailed Certificate default/foo failed to be issued: rate limited again",
+	}
+	if !slices.Equal(got, expect) {
+		t.Errorf("reportCertificateStatus() recorded %q, want %q", got, expect)
+	}
+
+	setCertificateStatusMetrics(hpKey, nil)
+	if n := testutil.CollectAndCount(certificateReady); n != 0 {
+		t.Errorf("contour_plus_certificate_ready has %d series, want 0", n)
+	}
+}
@@ -2,46 +2,28 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"math"
-	"sync"
 	"time"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	crlog "sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-type viaQueueValue string
-type applyResultValue string
+const certificateApplierName = "certificate-apply"
 
-const (
-	certificateApplierName                  = "certificate-apply"
-	labelViaQueue                           = "via_queue"
-	viaQueueYes            viaQueueValue    = "true"
-	viaQueueNo             viaQueueValue    = "false"
-	labelApplyResult                        = "result"
-	applyResultSuccess     applyResultValue = "success"
-	applyResultError       applyResultValue = "error"
-
-	DefaultRetryBaseDelay = 5 * time.Second
-	DefaultRetryMaxDelay  = 10 * time.Minute
-)
-
-type Applier[T client.Object] interface {
-	Apply(ctx context.Context, obj T) error
+// IssuerLimitKey returns the key of CertificateApplyIssuerLimits for the issuer.
+func IssuerLimitKey(kind, name string) string {
+	return kind + "/" + name
 }
 
 var _ Applier[*cmv1.Certificate] = &CertificateApplier{}
@@ -62,236 +44,351 @@ func NewCertificateApplier(client client.Client) *CertificateApplier {
 	}
 }
 
-// ApplyWorker is Applier with Start method so that it can be used directly by manager.Manager.Add
-// Implement ApplyWorker if the Applier requires a worker that runs in a background and start it via controller manager.
-type ApplyWorker[T client.Object] interface {
-	Applier[T]
-	// manager.Runnable defines signature for Start
-	manager.Runnable
-	// GetRetryChannel should return a receive only channel that can be used by the main reconciliation loop.
-	// For e.g. by WatchesRawSource and source.Channel in SetupWithManager to add a retry path.
-	// NOTE: could use second generics type instead of HTTPProxy if we want to use this somewhere else.
-	GetRetryChannel() <-chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy]
-	// RegisterMetrics should register metrics that ApplyWorker records
-	RegisterMetrics(metrics.RegistererGatherer) error
+// Recoverer is implemented by ApplyWorker that rebuilds its queue lost on restart.
+type Recoverer[T client.Object] interface {
+	// SetRecoverFunc sets the function that returns the objects pending to be applied, in the order they should be applied.
+	// It is called when the worker starts.
+	SetRecoverFunc(func(context.Context) ([]T, error))
 }
 
 var _ ApplyWorker[*cmv1.Certificate] = &CertificateApplyWorker{}
+var _ Recoverer[*cmv1.Certificate] = &CertificateApplyWorker{}
+var _ Forgetter = &CertificateApplyWorker{}
 
 // CertificateApplyWorker implements Applier and ApplyWorker.
 type CertificateApplyWorker struct {
-	mu sync.Mutex
+	*applyWorker[*cmv1.Certificate]
 	ReconcilerOptions
-	client client.Client
-	// internal workqueue for rate limiting Certificate changes
-	workqueue workqueue.TypedRateLimitingInterface[types.NamespacedName]
-	// manifests contains full client.Object that should be applied for the key
-	manifests map[types.NamespacedName]*cmv1.Certificate
-	// limiter is the underlying rate limiter used by the workqueue
-	limiter *rate.Limiter
-	// channel for queueing HTTPProxy back into main reconcile loop for a retry
-	retryCh chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy]
-	// certificatesAppliedTotal keeps track of the number of certificates applied either via queue or directly.
-	certificatesAppliedTotal *prometheus.CounterVec
+	// pending is the underlying queue of the workqueue that orders the keys by priority
+	pending *priorityQueue
+	// limiters holds the token buckets keyed by issuerLimitKey. They are created on demand.
+	limiters map[string]*rate.Limiter
+	// recoverFunc returns the keys of the Certificates pending to be applied when the worker starts
+	recoverFunc func(context.Context) ([]*cmv1.Certificate, error)
+	// recovered is closed when the pending Certificates are recovered. Apply waits for it if recoverFunc is set.
+	recovered chan struct{}
+	// reserved maps the keys reserved by recover to the owners of the Certificates until they are applied or forgotten.
+	// It is guarded by mu.
+	reserved map[types.NamespacedName]string
+	// certificatesRecoveredTotal keeps track of the number of pending certificates recovered on start.
+	certificatesRecoveredTotal prometheus.Counter
+	// certificateQueueDepth keeps track of the number of pending certificates for each priority class.
+	certificateQueueDepth *prometheus.GaugeVec
 }
 
-func NewCertificateApplyWorker(client client.Client, opt ReconcilerOptions) *CertificateApplyWorker {
-	limit := opt.CertificateApplyLimit
-
-	var limiter *rate.Limiter
-	if limit <= 0 {
-		// Unlimited: allow everything, burst doesn’t really matter in this case
-		limiter = rate.NewLimiter(rate.Inf, 1)
-	} else {
-		burst := max(int(math.Ceil(limit)), 1)
-		limiter = rate.NewLimiter(rate.Limit(limit), burst)
-	}
-
-	global := &workqueue.TypedBucketRateLimiter[types.NamespacedName]{Limiter: limiter}
+// NewCertificateApplyWorker creates a CertificateApplyWorker.
+// Certificates are rate limited by the token bucket of their issuer. The limit of each bucket is taken from
+// CertificateApplyIssuerLimits, or CertificateApplyLimit if the issuer is not listed there.
+// Queued Certificates are dequeued in the order of certificatePriority.
+func NewCertificateApplyWorker(client client.Client, recorder events.EventRecorder, opt ReconcilerOptions) *CertificateApplyWorker {
+	// The token buckets are not passed to the workqueue because the bucket of an item depends on its manifest,
+	// and tokens taken on enqueue would let earlier items of lower priority go first.
+	// The tokens are taken when the items are dequeued in Start instead.
 	expFailure := workqueue.NewTypedItemExponentialFailureRateLimiter[types.NamespacedName](opt.CertificateApplyRetryBaseDelay, opt.CertificateApplyRetryMaxDelay)
+	pending := newPriorityQueue()
 	certQueue := workqueue.NewTypedRateLimitingQueueWithConfig(
-		workqueue.NewTypedMaxOfRateLimiter(global, expFailure),
+		expFailure,
 		workqueue.TypedRateLimitingQueueConfig[types.NamespacedName]{
 			Name: certificateApplierName,
+			DelayingQueue: workqueue.NewTypedDelayingQueueWithConfig(workqueue.TypedDelayingQueueConfig[types.NamespacedName]{
+				Name: certificateApplierName,
+				Queue: workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[types.NamespacedName]{
+					Name:  certificateApplierName,
+					Queue: pending,
+				}),
+			}),
 		},
 	)
-	retryCh := make(chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy], 10)
-	return &CertificateApplyWorker{
+	w := &CertificateApplyWorker{
+		applyWorker:       newApplyWorker(certificateApplierName, CertificateKind, client, recorder, certQueue, applyCertificate, opt),
 		ReconcilerOptions: opt,
-		client:            client,
-		workqueue:         certQueue,
-		manifests:         make(map[types.NamespacedName]*cmv1.Certificate),
-		limiter:           limiter,
-		retryCh:           retryCh,
+		pending:           pending,
+		limiters:          make(map[string]*rate.Limiter),
+		reserved:          make(map[types.NamespacedName]string),
 	}
+	w.dequeueFunc = w.dequeue
+	return w
 }
 
 func (w *CertificateApplyWorker) RegisterMetrics(registry metrics.RegistererGatherer) error {
-	certificatesAppliedTotal := prometheus.NewCounterVec(
+	certificatesRecoveredTotal := prometheus.NewCounter(
 		prometheus.CounterOpts{
-			Name: "contour_plus_certificates_applied_total",
-			Help: "Total number of Certificate resources applied.",
+			Name:        "contour_plus_certificates_recovered_total",
+			Help:        "Total number of pending Certificate resources recovered into the queue when the worker starts.",
+			ConstLabels: prometheus.Labels{"controller": certificateApplierName},
 		},
-		[]string{"controller", labelViaQueue, labelApplyResult},
 	)
 
-	// cannot use MustRegister because controller-runtime uses global prometheus registry which cannot be reset during testing
-	// we need to explicitly check for duplicate metrics error
-	if err := registry.Register(certificatesAppliedTotal); err != nil {
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+	certificateQueueDepth := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "contour_plus_certificate_queue_depth",
+			Help: "Number of Certificate resources pending in the queue for each priority class.",
+		},
+		[]string{"controller", "priority"},
+	)
+
+	certificateQueuePending := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "contour_plus_certificate_queue_pending",
+			Help:        "Number of Certificate resources waiting to be applied from the queue, including those waiting for retries.",
+			ConstLabels: prometheus.Labels{"controller": certificateApplierName},
+		},
+		w.pendingManifests,
+	)
+
+	if err := w.registerAppliedTotal(registry, "contour_plus_certificates_applied_total"); err != nil {
+		return err
+	}
+	for _, c := range []prometheus.Collector{certificatesRecoveredTotal, certificateQueueDepth, certificateQueuePending} {
+		if err := registerCollector(registry, c); err != nil {
 			return err
 		}
 	}
-	w.certificatesAppliedTotal = certificatesAppliedTotal
+	w.certificatesRecoveredTotal = certificatesRecoveredTotal
+	w.certificateQueueDepth = certificateQueueDepth
+	w.pending.setDepthMetric(certificateQueueDepth)
 	return nil
 }
 
+// SetRecoverFunc sets the function to recover the Certificates pending to be applied.
+// It must be called before Start.
+func (w *CertificateApplyWorker) SetRecoverFunc(f func(context.Context) ([]*cmv1.Certificate, error)) {
+	w.recoverFunc = f
+	w.recovered = make(chan struct{})
+}
+
 func (w *CertificateApplyWorker) Apply(ctx context.Context, obj *cmv1.Certificate) error {
 	log := crlog.FromContext(ctx)
 	objKey := types.NamespacedName{
 		Namespace: obj.GetNamespace(),
 		Name:      obj.GetName(),
 	}
-	requiresQueue, err := w.RequiresQueue(ctx, objKey, obj)
+	if err := w.waitRecovered(ctx); err != nil {
+		return err
+	}
+	defer w.release(objKey)
+	found, changed, err := w.compareWithCurrent(ctx, objKey, obj)
 	if err != nil {
 		return err
 	}
-	if requiresQueue {
-		w.enqueueCertificate(objKey, obj)
-		log.Info("cert queued for apply", "key", objKey.String())
+	// Certificates of unlimited issuers are also queued so that they are applied in the order of the priority classes.
+	// Their infinite token buckets never delay them.
+	if !found || changed {
+		priority := priorityCreate
+		if found {
+			priority = priorityUpdate
+		}
+		if w.hasCriticalOwner(ctx, obj) {
+			priority = priorityCritical
+		}
+		w.enqueueCertificate(objKey, obj, priority)
+		log.Info("cert queued for apply", "key", objKey.String(), "priority", priority.String())
 		return nil
 	}
-	if err := applyCertificate(ctx, w.client, obj); err != nil {
-		log.Error(err, "cert apply failed", "key", objKey.String())
-		w.recordApply(viaQueueNo, applyResultError)
-		return err
-	}
-	log.Info("cert applied without queueing", "key", objKey.String())
-	w.recordApply(viaQueueNo, applyResultSuccess)
-	return nil
+	return w.applyDirectly(ctx, obj)
 }
 
 func (w *CertificateApplyWorker) Start(ctx context.Context) error {
-	log := crlog.FromContext(ctx)
-	go func() {
-		<-ctx.Done()
-		log.Info("context.Done received. Shutting down certificate apply worker.")
-		w.workqueue.ShutDown()
-	}()
-
-	for {
-		objKey, shutdown := w.workqueue.Get()
-		if shutdown {
-			return nil
-		}
-		log.Info("processing cert queue item", "key", objKey.String())
-
-		func() {
-			defer w.workqueue.Done(objKey)
-
-			w.mu.Lock()
-			obj, ok := w.manifests[objKey]
-			delete(w.manifests, objKey)
-			w.mu.Unlock()
-
-			if !ok {
-				log.Error(fmt.Errorf("cannot find certificate manifest for %s", objKey.String()), "cert apply failed", "key", objKey.String())
-				return
-			}
-
-			if ctx.Err() != nil {
-				log.Info("context cancelled, skipping cert apply", "key", objKey.String())
-				return
-			}
-
-			if err := applyCertificate(ctx, w.client, obj); err != nil {
-				log.Error(err, "cert apply from queue failed", "key", objKey.String())
-				w.recordApply(viaQueueYes, applyResultError)
-				w.enqueueHTTPProxy(ctx, obj)
-				return
-			}
-
-			log.Info("cert applied from queue", "key", objKey.String())
-			w.recordApply(viaQueueYes, applyResultSuccess)
-			// Forget the cert key to reset the exponential backoff counter so that
-			// a future failure starts from the base delay again.
-			w.workqueue.Forget(objKey)
-		}()
+	if w.recoverFunc != nil {
+		w.recover(ctx)
 	}
+	return w.run(ctx)
 }
 
-func (w *CertificateApplyWorker) GetRetryChannel() <-chan event.TypedGenericEvent[*projectcontourv1.HTTPProxy] {
-	return w.retryCh
+// dequeue takes a token for obj when it is taken out of the queue, so that the Certificates of higher priority
+// take the tokens first. If the bucket of the issuer is empty, the key is put back until the next token is available,
+// which does not block the Certificates of other issuers. The caller must hold w.mu.
+func (w *CertificateApplyWorker) dequeue(objKey types.NamespacedName, obj *cmv1.Certificate) time.Duration {
+	if delay := w.takeToken(obj); delay > 0 {
+		return delay
+	}
+	w.pending.forget(objKey)
+	return 0
 }
 
-// RequiresQueue indicates whether the object should be queued or not.
-func (w *CertificateApplyWorker) RequiresQueue(ctx context.Context, key types.NamespacedName, obj *cmv1.Certificate) (bool, error) {
-	log := crlog.FromContext(ctx)
-
-	current := new(cmv1.Certificate)
-	err := w.client.Get(ctx, key, current)
-	if k8serrors.IsNotFound(err) {
-		// MUST be queued with rate limit
-		return true, nil
+// Forget also releases the reservations made by recover for the Certificates of the owner that are not kept,
+// because their owner no longer applies them.
+func (w *CertificateApplyWorker) Forget(owner string, keep func(childKey) bool) []childKey {
+	forgotten := w.applyWorker.Forget(owner, keep)
+	for _, key := range forgotten {
+		w.pending.forget(key.ObjectKey)
 	}
-	if err != nil {
-		log.Error(err, "unable to get Certificate resource")
-		return false, err
+
+	w.mu.Lock()
+	var released []types.NamespacedName
+	for objKey, reservedOwner := range w.reserved {
+		if reservedOwner == owner && !keep(childKey{Kind: CertificateKind, ObjectKey: objKey}) {
+			released = append(released, objKey)
+		}
 	}
-	// MUST COMPARE specs of desired and current to see if there will be re-issuance of the Certificate
-	// can safely Ignore spec.secretTemplate changes as they are only secret metadata change and does not trigger re-issuance
-	objCopy := obj.DeepCopy()
-	objCopy.Spec.SecretTemplate = nil
-	current.Spec.SecretTemplate = nil
-	if equality.Semantic.DeepEqual(objCopy.Spec, current.Spec) {
-		// no-reissuance, safe to patch without rate limit
-		return false, nil
+	w.mu.Unlock()
+	for _, objKey := range released {
+		w.release(objKey)
 	}
-	return true, nil
+	return forgotten
 }
 
-func (w *CertificateApplyWorker) enqueueCertificate(objKey types.NamespacedName, obj *cmv1.Certificate) {
+// release drops the reservation of objKey made by recover. The reserved sequence number is freed
+// unless objKey has been queued, in which case the queue frees it when objKey is dequeued.
+// Every recovered key is released once its owner is reconciled after the start, so the reservations
+// do not outlive the recovery.
+func (w *CertificateApplyWorker) release(objKey types.NamespacedName) {
 	w.mu.Lock()
-	defer w.mu.Unlock()
-	w.manifests[objKey] = obj
-	w.workqueue.AddRateLimited(objKey)
+	_, reserved := w.reserved[objKey]
+	delete(w.reserved, objKey)
+	_, queued := w.manifests[objKey]
+	w.mu.Unlock()
+	if reserved && !queued {
+		w.pending.forget(objKey)
+	}
 }
 
-// enqueueHTTPProxy sends the HTTPProxy that owns obj directly to retryCh so the main
-// reconcile loop picks it up. The exponential backoff is handled by the certQueue's
-// MaxOfRateLimiter when the reconcile loop calls AddRateLimited on subsequent failures.
-func (w *CertificateApplyWorker) enqueueHTTPProxy(ctx context.Context, obj *cmv1.Certificate) {
-	log := crlog.FromContext(ctx)
-	annotations := obj.GetAnnotations()
-	if annotations == nil {
-		log.Error(fmt.Errorf("annotations do not exist on certificate %s/%s", obj.Namespace, obj.Name), "skipping HTTPProxy enqueue", "certificateName", obj.Name, "certificateNamespace", obj.Namespace)
-		return
+// issuerLimit returns the rate limit for the issuer. 0 means unlimited.
+func (w *CertificateApplyWorker) issuerLimit(kind, name string) float64 {
+	if limit, ok := w.CertificateApplyIssuerLimits[IssuerLimitKey(kind, name)]; ok {
+		return limit
+	}
+	return w.CertificateApplyLimit
+}
+
+// issuerLimiter returns the token bucket for the issuer. The caller must hold w.mu.
+func (w *CertificateApplyWorker) issuerLimiter(kind, name string) *rate.Limiter {
+	key := IssuerLimitKey(kind, name)
+	if limiter, ok := w.limiters[key]; ok {
+		return limiter
 	}
-	owner, ok := annotations[ownerAnnotation]
+
+	var limiter *rate.Limiter
+	limit := w.issuerLimit(kind, name)
+	if limit <= 0 {
+		// Unlimited: allow everything, burst doesn’t really matter in this case
+		limiter = rate.NewLimiter(rate.Inf, 1)
+	} else {
+		burst := max(int(math.Ceil(limit)), 1)
+		limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		// The bucket starts empty so that a restart does not allow a burst on top of the applies
+		// made just before the restart.
+		limiter.ReserveN(time.Now(), burst)
+	}
+	w.limiters[key] = limiter
+	return limiter
+}
+
+// takeToken takes a token from the bucket of the issuer of obj.
+// If no token is available, it returns the time until the next token without taking it.
+// The caller must hold w.mu.
+func (w *CertificateApplyWorker) takeToken(obj *cmv1.Certificate) time.Duration {
+	r := w.issuerLimiter(obj.Spec.IssuerRef.Kind, obj.Spec.IssuerRef.Name).Reserve()
+	delay := r.Delay()
+	if delay > 0 {
+		r.Cancel()
+	}
+	return delay
+}
+
+// hasCriticalOwner returns true if the owner of obj is annotated with criticalAnnotation.
+func (w *CertificateApplyWorker) hasCriticalOwner(ctx context.Context, obj *cmv1.Certificate) bool {
+	key, ok := obj.GetAnnotations()[ownerAnnotation]
 	if !ok {
-		log.Error(fmt.Errorf("annotation %q not found on certificate %s/%s", ownerAnnotation, obj.Namespace, obj.Name), "skipping HTTPProxy enqueue", "certificateName", obj.Name, "certificateNamespace", obj.Namespace)
-		return
+		return false
+	}
+	owner, err := ownerFromKey(key)
+	if err != nil {
+		return false
+	}
+	if err := w.client.Get(ctx, client.ObjectKeyFromObject(owner), owner); err != nil {
+		return false
 	}
-	ns, name, err := cache.SplitMetaNamespaceKey(owner)
+	return owner.GetAnnotations()[criticalAnnotation] == "true"
+}
+
+// recover reserves the positions in the queue for the pending Certificates in the order returned by recoverFunc.
+// The queue is not persisted, so the Certificates queued before restart are applied again when
+// their owners are reconciled. Without the reservations, the order of the applies would depend on
+// the order of the reconciliation.
+func (w *CertificateApplyWorker) recover(ctx context.Context) {
+	log := crlog.FromContext(ctx)
+	defer close(w.recovered)
+
+	certs, err := w.recoverFunc(ctx)
 	if err != nil {
-		log.Error(err, "skipping HTTPProxy enqueue", "certificateName", obj.Name, "certificateNamespace", obj.Namespace)
+		log.Error(err, "unable to recover pending certificates")
 		return
 	}
-	h := &projectcontourv1.HTTPProxy{}
-	h.SetNamespace(ns)
-	h.SetName(name)
-	log.Info("re-queueing HTTPProxy for retry", "namespace", ns, "name", name)
+
+	w.mu.Lock()
+	for _, cert := range certs {
+		objKey := client.ObjectKeyFromObject(cert)
+		w.pending.reserve(objKey)
+		w.reserved[objKey] = cert.GetAnnotations()[ownerAnnotation]
+	}
+	w.mu.Unlock()
+	if w.certificatesRecoveredTotal != nil {
+		w.certificatesRecoveredTotal.Add(float64(len(certs)))
+	}
+	log.Info("recovered pending certificates", "count", len(certs))
+}
+
+// waitRecovered waits for the pending Certificates to be recovered so that the recovered order is not
+// overtaken by the Certificates applied during the recovery.
+func (w *CertificateApplyWorker) waitRecovered(ctx context.Context) error {
+	if w.recovered == nil {
+		return nil
+	}
 	select {
-	case w.retryCh <- event.TypedGenericEvent[*projectcontourv1.HTTPProxy]{Object: h}:
+	case <-w.recovered:
+		return nil
 	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (w *CertificateApplyWorker) recordApply(viaQueue viaQueueValue, applyResult applyResultValue) {
-	if w.certificatesAppliedTotal == nil {
-		return
+// RequiresQueue indicates whether the object should be queued or not.
+func (w *CertificateApplyWorker) RequiresQueue(ctx context.Context, key types.NamespacedName, obj *cmv1.Certificate) (bool, error) {
+	found, changed, err := w.compareWithCurrent(ctx, key, obj)
+	if err != nil {
+		return false, err
 	}
-	w.certificatesAppliedTotal.WithLabelValues(certificateApplierName, string(viaQueue), string(applyResult)).Inc()
+	return !found || changed, nil
+}
+
+// compareWithCurrent returns whether the Certificate exists and whether applying obj will re-issue it.
+func (w *CertificateApplyWorker) compareWithCurrent(ctx context.Context, key types.NamespacedName, obj *cmv1.Certificate) (found bool, changed bool, err error) {
+	log := crlog.FromContext(ctx)
+
+	current := new(cmv1.Certificate)
+	err = w.client.Get(ctx, key, current)
+	if k8serrors.IsNotFound(err) {
+		// MUST be queued with rate limit
+		return false, false, nil
+	}
+	if err != nil {
+		log.Error(err, "unable to get Certificate resource")
+		return false, false, err
+	}
+	// MUST COMPARE specs of desired and current to see if there will be re-issuance of the Certificate
+	if !certificateReissued(obj, current) {
+		// no-reissuance, safe to patch without rate limit
+		return true, false, nil
+	}
+	return true, true, nil
+}
+
+// certificateReissued returns whether applying desired to the current Certificate will re-issue it.
+// It is shared by Apply and the recovery so that both agree on which Certificates are pending.
+func certificateReissued(desired, current *cmv1.Certificate) bool {
+	// can safely Ignore spec.secretTemplate changes as they are only secret metadata change and does not trigger re-issuance
+	desiredSpec := desired.Spec.DeepCopy()
+	currentSpec := current.Spec.DeepCopy()
+	desiredSpec.SecretTemplate = nil
+	currentSpec.SecretTemplate = nil
+	return !equality.Semantic.DeepEqual(desiredSpec, currentSpec)
+}
+
+func (w *CertificateApplyWorker) enqueueCertificate(objKey types.NamespacedName, obj *cmv1.Certificate, priority certificatePriority) {
+	// the priority must be set before the key is pushed to the pending queue
+	w.pending.setPriority(objKey, priority)
+	w.enqueue(objKey, obj)
 }
 
 // applyCertificate applies provided certificate object with provided context and apiserver client
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
@@ -26,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -92,7 +94,7 @@ func testCertificateApplyWorker() {
 		It("queues a completely new object (NotFound path)", func() {
 			baseClient := newFakeClient() // no existing Certificate
 			cl := &applyAsUpdateClient{Client: baseClient}
-			worker := NewCertificateApplyWorker(cl, ReconcilerOptions{
+			worker := NewCertificateApplyWorker(cl, nil, ReconcilerOptions{
 				CertificateApplyLimit:          10, // avoid rate.Limit(0) semantics
 				CertificateApplyRetryBaseDelay: 1 * time.Millisecond,
 				CertificateApplyRetryMaxDelay:  10 * time.Millisecond,
@@ -131,7 +133,7 @@ func testCertificateApplyWorker() {
 
 			// assert metrics: 0 for all metrics
 			// queued apply is not recorded since we are not running Start
-			assertMetricsCombinations(worker.certificatesAppliedTotal, expectedValsForMetrics{
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
 				viaQueueSuccess: 0,
 				viaQueueError:   0,
 				directSuccess:   0,
@@ -157,7 +159,7 @@ func testCertificateApplyWorker() {
 
 			baseClient := newFakeClient(current) // init with a certificate
 			cl := &applyAsUpdateClient{Client: baseClient}
-			worker := NewCertificateApplyWorker(cl, ReconcilerOptions{
+			worker := NewCertificateApplyWorker(cl, nil, ReconcilerOptions{
 				CertificateApplyLimit: 10,
 			})
 
@@ -186,7 +188,7 @@ func testCertificateApplyWorker() {
 			Expect(got.Spec.DNSNames).To(ConsistOf("example.com"))
 
 			// assert metrics: 1 successful direct apply
-			assertMetricsCombinations(worker.certificatesAppliedTotal, expectedValsForMetrics{
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
 				viaQueueSuccess: 0,
 				viaQueueError:   0,
 				directSuccess:   1,
@@ -208,7 +210,7 @@ func testCertificateApplyWorker() {
 
 			baseClient := newFakeClient(current) // init with a certificate
 			cl := &applyAsUpdateClient{Client: baseClient}
-			worker := NewCertificateApplyWorker(cl, ReconcilerOptions{
+			worker := NewCertificateApplyWorker(cl, nil, ReconcilerOptions{
 				CertificateApplyLimit:          10,
 				CertificateApplyRetryBaseDelay: 1 * time.Millisecond,
 				CertificateApplyRetryMaxDelay:  10 * time.Millisecond,
@@ -240,7 +242,62 @@ func testCertificateApplyWorker() {
 
 			// assert metrics: 0 for all metrics
 			// queued apply is not recorded since we are not running Start
-			assertMetricsCombinations(worker.certificatesAppliedTotal, expectedValsForMetrics{
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
+				viaQueueSuccess: 0,
+				viaQueueError:   0,
+				directSuccess:   0,
+				directError:     0,
+			})
+		})
+	})
+
+	Describe("per-issuer rate limiting", func() {
+		It("queues Certificates of unlimited issuers and limits each issuer separately", func() {
+			baseClient := newFakeClient()
+			cl := &applyAsUpdateClient{Client: baseClient}
+			worker := NewCertificateApplyWorker(cl, nil, ReconcilerOptions{
+				CertificateApplyLimit: 10,
+				CertificateApplyIssuerLimits: map[string]float64{
+					IssuerLimitKey(ClusterIssuerKind, "internal-ca"): 0,
+					IssuerLimitKey(ClusterIssuerKind, "acme"):        0.5,
+				},
+				CertificateApplyRetryBaseDelay: 1 * time.Millisecond,
+				CertificateApplyRetryMaxDelay:  10 * time.Millisecond,
+			})
+
+			reg := prometheus.NewRegistry()
+			Expect(worker.RegisterMetrics(reg)).To(Succeed())
+
+			newCert := func(name, issuerKind, issuerName string) *cmv1.Certificate {
+				return &cmv1.Certificate{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "default",
+						Name:      name,
+					},
+					Spec: cmv1.CertificateSpec{
+						SecretName: name,
+						DNSNames:   []string{name + ".example.com"},
+						IssuerRef:  cmmeta.IssuerReference{Kind: issuerKind, Name: issuerName},
+					},
+				}
+			}
+
+			// every issuer is queued with its own token bucket; the unlimited one is never delayed
+			Expect(worker.Apply(ctx, newCert("internal", ClusterIssuerKind, "internal-ca"))).To(Succeed())
+			Expect(worker.Apply(ctx, newCert("acme", ClusterIssuerKind, "acme"))).To(Succeed())
+			Expect(worker.Apply(ctx, newCert("other", IssuerKind, "other"))).To(Succeed())
+			Eventually(func() int { return worker.workqueue.Len() }, 500*time.Millisecond, time.Millisecond).Should(Equal(3))
+
+			Expect(worker.issuerLimit(ClusterIssuerKind, "internal-ca")).To(Equal(0.0))
+			Expect(worker.issuerLimit(ClusterIssuerKind, "acme")).To(Equal(0.5))
+			Expect(worker.issuerLimit(IssuerKind, "other")).To(Equal(10.0))
+			worker.mu.Lock()
+			Expect(worker.takeToken(newCert("internal", ClusterIssuerKind, "internal-ca"))).To(BeZero())
+			Expect(worker.takeToken(newCert("internal", ClusterIssuerKind, "internal-ca"))).To(BeZero())
+			Expect(worker.takeToken(newCert("acme", ClusterIssuerKind, "acme"))).To(BeNumerically(">", 0))
+			worker.mu.Unlock()
+
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
 				viaQueueSuccess: 0,
 				viaQueueError:   0,
 				directSuccess:   0,
@@ -253,7 +310,7 @@ func testCertificateApplyWorker() {
 		It("dequeues and applies a certificate from the queue", func() {
 			baseClient := newFakeClient() // no existing certificate
 			cl := &applyAsUpdateClient{Client: baseClient}
-			worker := NewCertificateApplyWorker(cl, ReconcilerOptions{
+			worker := NewCertificateApplyWorker(cl, nil, ReconcilerOptions{
 				CertificateApplyLimit:          10, // avoid rate.Limit(0) oddness
 				CertificateApplyRetryBaseDelay: 1 * time.Millisecond,
 				CertificateApplyRetryMaxDelay:  10 * time.Millisecond,
@@ -304,7 +361,7 @@ func testCertificateApplyWorker() {
 			Expect(got.Spec.DNSNames).To(ConsistOf("example.com"))
 
 			// assert metrics: 1 successful apply via queue
-			assertMetricsCombinations(worker.certificatesAppliedTotal, expectedValsForMetrics{
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
 				viaQueueSuccess: 1,
 				viaQueueError:   0,
 				directSuccess:   0,
@@ -320,10 +377,17 @@ func testCertificateApplyWorker() {
 		})
 
 		It("sends an HTTPProxy event on retry channel when certificate apply fails", func() {
-			baseClient := newFakeClient()
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "test-httpproxy",
+				},
+			}
+			baseClient := newFakeClient(hp)
 			cl := &failingPatchClient{Client: baseClient}
+			recorder := events.NewFakeRecorder(10)
 
-			worker := NewCertificateApplyWorker(cl, ReconcilerOptions{
+			worker := NewCertificateApplyWorker(cl, recorder, ReconcilerOptions{
 				CertificateApplyLimit:          10,
 				CertificateApplyRetryBaseDelay: 100 * time.Millisecond,
 				CertificateApplyRetryMaxDelay:  1 * time.Second,
@@ -332,7 +396,7 @@ func testCertificateApplyWorker() {
 			reg := prometheus.NewRegistry()
 			Expect(worker.RegisterMetrics(reg)).To(Succeed())
 
-			// Certificate annotated with ownerAnnotation so enqueueHTTPProxy can find the HTTPProxy key
+			// Certificate annotated with ownerAnnotation so enqueueOwner can find the HTTPProxy key
 			cert := &cmv1.Certificate{
 				ObjectMeta: metav1.ObjectMeta{
 					Namespace: "default",
@@ -372,8 +436,11 @@ func testCertificateApplyWorker() {
 			Expect(evt.Object.Namespace).To(Equal("default"))
 			Expect(evt.Object.Name).To(Equal("test-httpproxy"))
 
+			// a warning event should be recorded on the HTTPProxy
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning ApplyFailed failed to apply Certificate default/cert-with-owner")))
+
 			// assert metrics: 1 apply error via queue
-			assertMetricsCombinations(worker.certificatesAppliedTotal, expectedValsForMetrics{
+			assertMetricsCombinations(worker.appliedTotal, expectedValsForMetrics{
 				viaQueueSuccess: 0,
 				viaQueueError:   1,
 				directSuccess:   0,
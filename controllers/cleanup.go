@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// ownerIndexField is the field index to look up child resources by the value of ownerAnnotation.
+const ownerIndexField = ".metadata.annotations.owned-by"
+
+// childKey identifies a child resource generated by contour-plus.
+type childKey struct {
+	Kind string
+	client.ObjectKey
+}
+
+// getOwnerKey returns the value of ownerAnnotation for the owner.
+// HTTPRoute is prefixed with its kind to be distinguished from HTTPProxy with the same name.
+func getOwnerKey(owner client.Object) string {
+	key := owner.GetNamespace() + "/" + owner.GetName()
+	if _, ok := owner.(*gatewayv1.HTTPRoute); ok {
+		return HTTPRouteKind + "/" + key
+	}
+	return key
+}
+
+// ownerFromKey returns an empty HTTPProxy or HTTPRoute named after the value of ownerAnnotation.
+// It is the reverse of getOwnerKey.
+func ownerFromKey(key string) (client.Object, error) {
+	if routeKey, ok := strings.CutPrefix(key, HTTPRouteKind+"/"); ok {
+		ns, name, err := cache.SplitMetaNamespaceKey(routeKey)
+		if err != nil {
+			return nil, err
+		}
+		return &gatewayv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}, nil
+	}
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &projectcontourv1.HTTPProxy{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}, nil
+}
+
+// indexOwner is the indexer function for ownerIndexField.
+// Child resources created by old versions of contour-plus may lack ownerAnnotation,
+// so the controller reference is used for them instead.
+func indexOwner(obj client.Object) []string {
+	if owner, ok := obj.GetAnnotations()[ownerAnnotation]; ok {
+		return []string{owner}
+	}
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return nil
+	}
+	switch ref.Kind {
+	case HTTPProxyKind:
+		return []string{obj.GetNamespace() + "/" + ref.Name}
+	case HTTPRouteKind:
+		return []string{HTTPRouteKind + "/" + obj.GetNamespace() + "/" + ref.Name}
+	}
+	return nil
+}
+
+// childLists returns empty lists of the child resources that contour-plus may generate, keyed by kind.
+// The lists are of the same types as the objects watched by ownChildren so that they are read from the same informers.
+func (r *HTTPProxyReconciler) childLists() map[string]client.ObjectList {
+	lists := make(map[string]client.ObjectList)
+	if r.CreateDNSEndpoint {
+		deList := &unstructured.UnstructuredList{}
+		deList.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointListKind))
+		lists[DNSEndpointKind] = deList
+	}
+	if r.CreateCertificate {
+		lists[CertificateKind] = &cmv1.CertificateList{}
+		tcdList := &unstructured.UnstructuredList{}
+		tcdList.SetGroupVersionKind(contourGroupVersion.WithKind(TLSCertificateDelegationListKind))
+		lists[TLSCertificateDelegationKind] = tcdList
+	}
+	return lists
+}
+
+// setupOwnerIndex registers ownerIndexField for the child resources.
+func (r *HTTPProxyReconciler) setupOwnerIndex(ctx context.Context, indexer client.FieldIndexer) error {
+	if r.CreateDNSEndpoint {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		if err := indexer.IndexField(ctx, obj, ownerIndexField, indexOwner); err != nil {
+			return err
+		}
+	}
+	if r.CreateCertificate {
+		if err := indexer.IndexField(ctx, &cmv1.Certificate{}, ownerIndexField, indexOwner); err != nil {
+			return err
+		}
+		tcdObj := &unstructured.Unstructured{}
+		tcdObj.SetGroupVersionKind(contourGroupVersion.WithKind(TLSCertificateDelegationKind))
+		if err := indexer.IndexField(ctx, tcdObj, ownerIndexField, indexOwner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desiredChildren returns the child resources that should exist for the owner.
+// Resources that are not applied because of transient problems, such as the missing IP address of the load balancer
+// or invalid annotation values, are included so that they are kept as they are.
+// Resources denied by DomainPolicy are also included, so hostnames should not be filtered by the rules.
+func (r *HTTPProxyReconciler) desiredChildren(ctx context.Context, owner client.Object, hostnames []string, secretName string) (map[childKey]bool, error) {
+	desired := make(map[childKey]bool)
+
+	if r.CreateDNSEndpoint && len(hostnames) != 0 {
+		namespace, err := r.getDNSEndpointNamespace(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		name := getDNSEndpointName(r, owner)
+		desired[childKey{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: namespace, Name: name}}] = true
+		if r.getDelegatedDomain(owner) != "" {
+			desired[childKey{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: namespace, Name: name + "-delegation"}}] = true
+		}
+	}
+
+	if r.CreateCertificate && len(hostnames) != 0 && secretName != "" && owner.GetAnnotations()[testACMETLSAnnotation] == "true" {
+		namespace, err := r.getCertificateNamespace(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		desired[childKey{Kind: CertificateKind, ObjectKey: client.ObjectKey{Namespace: namespace, Name: getCertificateName(r, owner)}}] = true
+	}
+
+	// TLSCertificateDelegation is generated only for HTTPProxy
+	if hp, ok := owner.(*projectcontourv1.HTTPProxy); ok {
+		namespace, err := r.getDelegationNamespace(ctx, hp)
+		if err != nil {
+			return nil, err
+		}
+		if namespace != "" {
+			desired[childKey{Kind: TLSCertificateDelegationKind, ObjectKey: client.ObjectKey{Namespace: namespace, Name: getCertificateName(r, owner)}}] = true
+		}
+	}
+	return desired, nil
+}
+
+// cleanupAllResources deletes all the child resources owned by the owner.
+// This is called when the owner is no longer a target of contour-plus.
+// The finalizer added by trackResourceOwnership is removed as well unless children in other namespaces
+// are left to the other instances of contour-plus.
+func (r *HTTPProxyReconciler) cleanupAllResources(ctx context.Context, owner client.Object, log logr.Logger) error {
+	foreign, err := r.deleteUnusedChildren(ctx, owner, nil, log)
+	if err != nil {
+		log.Error(err, "unable to clean up unused resources")
+		return err
+	}
+	if foreign || owner.GetDeletionTimestamp() != nil || !controllerutil.ContainsFinalizer(owner, finalizerName) {
+		return nil
+	}
+	controllerutil.RemoveFinalizer(owner, finalizerName)
+	if err := r.Update(ctx, owner); err != nil {
+		log.Error(err, "unable to remove finalizer")
+		return err
+	}
+	return nil
+}
+
+// cleanupUnusedResources deletes the child resources owned by the owner that are not in desired.
+// It looks up both resources in the owner's namespace and in the allowed cross namespaces via ownerIndexField.
+// If ingress class names are specified, only resources generated by contour-plus for the watched ingress classes are deleted
+// so that multiple instances of contour-plus do not delete resources of each other. See ownsChild.
+// The resources waiting in the queues of the apply workers are dropped as well.
+func (r *HTTPProxyReconciler) cleanupUnusedResources(ctx context.Context, owner client.Object, desired map[childKey]bool, log logr.Logger) error {
+	_, err := r.deleteUnusedChildren(ctx, owner, desired, log)
+	return err
+}
+
+// deleteUnusedChildren implements cleanupUnusedResources. It returns true if a child in another namespace than the owner
+// is not deleted because it is generated by another instance of contour-plus.
+// The desired children generated by older versions of contour-plus are annotated with the ingress class of the owner
+// so that this instance can delete them even after the owner is moved to an ingress class watched by no instance.
+func (r *HTTPProxyReconciler) deleteUnusedChildren(ctx context.Context, owner client.Object, desired map[childKey]bool, log logr.Logger) (bool, error) {
+	r.forgetQueuedResources(owner, desired, log)
+	var foreign bool
+	for kind, list := range r.childLists() {
+		err := r.ChildReader.List(ctx, list, client.MatchingFields{ownerIndexField: getOwnerKey(owner)})
+		if err != nil {
+			return false, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return false, err
+		}
+
+		for _, item := range items {
+			obj := item.(client.Object)
+			key := childKey{Kind: kind, ObjectKey: client.ObjectKeyFromObject(obj)}
+			if desired[key] {
+				if err := r.stampIngressClass(ctx, owner, obj); err != nil {
+					log.Error(err, "failed to annotate "+kind+" with ingress class", "name", obj.GetName(), "namespace", obj.GetNamespace())
+					return false, err
+				}
+				continue
+			}
+			if !r.ownsChild(owner, obj) {
+				if obj.GetNamespace() != owner.GetNamespace() {
+					foreign = true
+				}
+				continue
+			}
+
+			err := r.Delete(ctx, obj)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				log.Error(err, "failed to delete unused "+kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+				return false, err
+			}
+			log.Info("deleted unused "+kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+		}
+	}
+	return foreign, nil
+}
+
+// stampIngressClass adds ingressClassOwnerAnnotation to obj generated by older versions of contour-plus.
+// Such children are not always applied again, e.g. when they are kept because of invalid annotations.
+func (r *HTTPProxyReconciler) stampIngressClass(ctx context.Context, owner, obj client.Object) error {
+	if r.watchesAllIngressClasses() {
+		return nil
+	}
+	if _, ok := obj.GetAnnotations()[ingressClassOwnerAnnotation]; ok {
+		return nil
+	}
+	className := r.getOwnerIngressClassName(owner)
+	if className == "" {
+		return nil
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	annotations[ingressClassOwnerAnnotation] = className
+	obj.SetAnnotations(annotations)
+	return r.Patch(ctx, obj, patch)
+}
+
+// ownsChild returns true if obj owned by the owner is generated by this instance of contour-plus.
+// If ingress class names are specified, obj must be annotated with a watched ingress class. Children without
+// ingressClassOwnerAnnotation were generated by older versions of contour-plus, and are owned by the instance
+// watching the ingress class of the owner.
+func (r *HTTPProxyReconciler) ownsChild(owner, obj client.Object) bool {
+	if r.watchesAllIngressClasses() {
+		return true
+	}
+	if className, ok := obj.GetAnnotations()[ingressClassOwnerAnnotation]; ok {
+		return r.isWatchedIngressClass(className)
+	}
+	return r.getOwnerIngressClassName(owner) != ""
+}
+
+// forgetQueuedResources drops the child resources owned by the owner that are not in desired from the queues
+// of the apply workers, so that they are not created after the owner stops desiring them.
+func (r *HTTPProxyReconciler) forgetQueuedResources(owner client.Object, desired map[childKey]bool, log logr.Logger) {
+	keep := func(key childKey) bool { return desired[key] }
+	for _, applier := range []any{r.CertApplier, r.DNSApplier} {
+		forgetter, ok := applier.(Forgetter)
+		if !ok {
+			continue
+		}
+		for _, key := range forgetter.Forget(getOwnerKey(owner), keep) {
+			log.Info("dropped queued "+key.Kind, "name", key.Name, "namespace", key.Namespace)
+		}
+	}
+}
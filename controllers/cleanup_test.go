@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestGetOwnerKey(t *testing.T) {
+	hpKey := client.ObjectKey{Namespace: "default", Name: "foo"}
+	if got := getOwnerKey(newDummyHTTPProxy(hpKey)); got != "default/foo" {
+		t.Errorf("getOwnerKey() for HTTPProxy = %q, want %q", got, "default/foo")
+	}
+	if got := getOwnerKey(newDummyHTTPRoute(hpKey, dnsName)); got != "HTTPRoute/default/foo" {
+		t.Errorf("getOwnerKey() for HTTPRoute = %q, want %q", got, "HTTPRoute/default/foo")
+	}
+}
+
+func TestIndexOwner(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ownerRef    *v1.OwnerReference
+		expect      []string
+	}{
+		{
+			name:        "annotation",
+			annotations: map[string]string{ownerAnnotation: "other/foo"},
+			ownerRef:    &v1.OwnerReference{Kind: HTTPProxyKind, Name: "bar", Controller: ptr.To(true)},
+			expect:      []string{"other/foo"},
+		},
+		{
+			name:     "HTTPProxy controller reference",
+			ownerRef: &v1.OwnerReference{Kind: HTTPProxyKind, Name: "bar", Controller: ptr.To(true)},
+			expect:   []string{"default/bar"},
+		},
+		{
+			name:     "HTTPRoute controller reference",
+			ownerRef: &v1.OwnerReference{Kind: HTTPRouteKind, Name: "bar", Controller: ptr.To(true)},
+			expect:   []string{"HTTPRoute/default/bar"},
+		},
+		{
+			name:     "non-controller reference",
+			ownerRef: &v1.OwnerReference{Kind: HTTPProxyKind, Name: "bar"},
+		},
+		{
+			name:     "unknown kind",
+			ownerRef: &v1.OwnerReference{Kind: "Ingress", Name: "bar", Controller: ptr.To(true)},
+		},
+		{
+			name: "no owner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			obj.SetNamespace("default")
+			obj.SetName("child")
+			obj.SetAnnotations(tt.annotations)
+			if tt.ownerRef != nil {
+				obj.SetOwnerReferences([]v1.OwnerReference{*tt.ownerRef})
+			}
+			if got := indexOwner(obj); !slices.Equal(got, tt.expect) {
+				t.Errorf("indexOwner() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDesiredChildren(t *testing.T) {
+	hpKey := client.ObjectKey{Namespace: "default", Name: "foo"}
+
+	tests := []struct {
+		name       string
+		opts       ReconcilerOptions
+		owner      client.Object
+		hostnames  []string
+		secretName string
+		expect     []childKey
+	}{
+		{
+			name:       "DNSEndpoint and Certificate",
+			opts:       ReconcilerOptions{CreateDNSEndpoint: true, CreateCertificate: true},
+			owner:      newDummyHTTPProxy(hpKey),
+			hostnames:  []string{dnsName},
+			secretName: testSecretName,
+			expect: []childKey{
+				{Kind: DNSEndpointKind, ObjectKey: hpKey},
+				{Kind: CertificateKind, ObjectKey: hpKey},
+			},
+		},
+		{
+			name:       "with delegation",
+			opts:       ReconcilerOptions{CreateDNSEndpoint: true, DefaultDelegatedDomain: testDelegationName},
+			owner:      newDummyHTTPProxy(hpKey),
+			hostnames:  []string{dnsName},
+			secretName: testSecretName,
+			expect: []childKey{
+				{Kind: DNSEndpointKind, ObjectKey: hpKey},
+				{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: "default", Name: "foo-delegation"}},
+			},
+		},
+		{
+			name:      "no hostnames",
+			opts:      ReconcilerOptions{CreateDNSEndpoint: true, CreateCertificate: true},
+			owner:     newDummyHTTPProxy(hpKey),
+			hostnames: nil,
+			expect:    nil,
+		},
+		{
+			name: "without tls-acme",
+			opts: ReconcilerOptions{CreateDNSEndpoint: true, CreateCertificate: true},
+			owner: func() client.Object {
+				hp := newDummyHTTPProxy(hpKey)
+				delete(hp.Annotations, testACMETLSAnnotation)
+				return hp
+			}(),
+			hostnames:  []string{dnsName},
+			secretName: testSecretName,
+			expect: []childKey{
+				{Kind: DNSEndpointKind, ObjectKey: hpKey},
+			},
+		},
+		{
+			name: "Certificate and TLSCertificateDelegation in the issuer namespace",
+			opts: ReconcilerOptions{CreateCertificate: true, AllowedIssuerNamespaces: []string{"issuer"}},
+			owner: func() client.Object {
+				hp := newDummyHTTPProxy(hpKey)
+				hp.Annotations[issuerNamespaceAnnotation] = "issuer"
+				return hp
+			}(),
+			hostnames:  []string{dnsName},
+			secretName: "default-foo",
+			expect: []childKey{
+				{Kind: CertificateKind, ObjectKey: client.ObjectKey{Namespace: "issuer", Name: "default-foo"}},
+				{Kind: TLSCertificateDelegationKind, ObjectKey: client.ObjectKey{Namespace: "issuer", Name: "default-foo"}},
+			},
+		},
+		{
+			name: "HTTPRoute has no TLSCertificateDelegation",
+			opts: ReconcilerOptions{CreateCertificate: true, AllowedIssuerNamespaces: []string{"issuer"}},
+			owner: func() client.Object {
+				route := newDummyHTTPRoute(hpKey, dnsName)
+				route.Annotations[issuerNamespaceAnnotation] = "issuer"
+				return route
+			}(),
+			hostnames:  []string{dnsName},
+			secretName: "httproute-default-foo",
+			expect: []childKey{
+				{Kind: CertificateKind, ObjectKey: client.ObjectKey{Namespace: "issuer", Name: "httproute-default-foo"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &HTTPProxyReconciler{ReconcilerOptions: tt.opts}
+			desired, err := r.desiredChildren(context.Background(), tt.owner, tt.hostnames, tt.secretName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := slices.Collect(maps.Keys(desired))
+			compare := func(a, b childKey) int {
+				return cmp.Or(strings.Compare(a.Kind, b.Kind), strings.Compare(a.String(), b.String()))
+			}
+			slices.SortFunc(got, compare)
+			want := slices.Clone(tt.expect)
+			slices.SortFunc(want, compare)
+			if !slices.Equal(got, want) {
+				t.Errorf("desiredChildren() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestForgetQueuedResources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+	de := &unstructured.Unstructured{}
+	de.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithIndex(de, ownerIndexField, indexOwner).Build()
+	w := NewDNSEndpointApplyWorker(c, nil, ReconcilerOptions{
+		DNSEndpointApplyLimit:          1,
+		DNSEndpointApplyRetryBaseDelay: time.Millisecond,
+		DNSEndpointApplyRetryMaxDelay:  time.Millisecond,
+	})
+	defer w.workqueue.ShutDown()
+	r := &HTTPProxyReconciler{
+		Client:            c,
+		ChildReader:       c,
+		DNSApplier:        w,
+		ReconcilerOptions: ReconcilerOptions{CreateDNSEndpoint: true},
+	}
+
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	for _, queued := range []struct{ name, owner string }{
+		{"foo", "default/foo"},
+		{"foo-delegation", "default/foo"},
+		{"bar", "default/bar"},
+	} {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		obj.SetNamespace("default")
+		obj.SetName(queued.name)
+		obj.SetAnnotations(map[string]string{ownerAnnotation: queued.owner})
+		w.enqueue(client.ObjectKeyFromObject(obj), obj)
+	}
+
+	// the delegation is no longer desired
+	desired, err := r.desiredChildren(context.Background(), hp, []string{dnsName}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.cleanupUnusedResources(context.Background(), hp, desired, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	queued := func() []string {
+		var names []string
+		for key := range w.manifests {
+			names = append(names, key.String())
+		}
+		slices.Sort(names)
+		return names
+	}
+	got := queued()
+	expect := []string{"default/bar", "default/foo"}
+	if !slices.Equal(got, expect) {
+		t.Errorf("queued after cleanup = %v, want %v", got, expect)
+	}
+
+	// nothing is left for the owner that is no longer a target
+	if err := r.cleanupAllResources(context.Background(), hp, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	got = queued()
+	expect = []string{"default/bar"}
+	if !slices.Equal(got, expect) {
+		t.Errorf("queued after cleanup of all = %v, want %v", got, expect)
+	}
+}
+
+func TestCleanupUnusedResourcesIngressClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	newCertificate := func(name, className string) *cmv1.Certificate {
+		cert := &cmv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: map[string]string{ownerAnnotation: "default/foo"},
+			},
+		}
+		if className != "" {
+			cert.Annotations[ingressClassOwnerAnnotation] = className
+		}
+		return cert
+	}
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&cmv1.Certificate{}, ownerIndexField, indexOwner).
+		WithIndex(&projectcontourv1.TLSCertificateDelegation{}, ownerIndexField, indexOwner).
+		WithObjects(newCertificate("own", "class-a"), newCertificate("other", "class-b"), newCertificate("legacy", "")).
+		Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		ChildReader: c,
+		Scheme:      scheme,
+		ReconcilerOptions: ReconcilerOptions{
+			IngressClassName:  "class-a",
+			CreateCertificate: true,
+		},
+	}
+	remaining := func() []string {
+		var certList cmv1.CertificateList
+		if err := c.List(context.Background(), &certList); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, cert := range certList.Items {
+			names = append(names, cert.Name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	// the children without the ingress class annotation are owned by the instance watching the class of the owner
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[ingressClassNameAnnotation] = "class-b"
+	if err := r.cleanupUnusedResources(context.Background(), hp, nil, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); !slices.Equal(got, []string{"legacy", "other"}) {
+		t.Errorf("Certificates left for the owner of the unwatched class = %v, want [legacy other]", got)
+	}
+
+	hp.Annotations[ingressClassNameAnnotation] = "class-a"
+	if err := r.cleanupUnusedResources(context.Background(), hp, nil, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); !slices.Equal(got, []string{"other"}) {
+		t.Errorf("Certificates left for the owner of the watched class = %v, want [other]", got)
+	}
+}
+
+func TestCleanupAllResourcesMovedOwner(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[ingressClassNameAnnotation] = "class-a"
+	hp.Finalizers = []string{finalizerName}
+	// generated by an older version of contour-plus without ingressClassOwnerAnnotation
+	legacy := newDummyCertificate(client.ObjectKey{Namespace: "certs", Name: "default-foo"}, "default/foo", dnsName)
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&cmv1.Certificate{}, ownerIndexField, indexOwner).
+		WithIndex(&projectcontourv1.TLSCertificateDelegation{}, ownerIndexField, indexOwner).
+		WithObjects(hp, legacy).
+		Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		ChildReader: c,
+		Scheme:      scheme,
+		ReconcilerOptions: ReconcilerOptions{
+			IngressClassName:  "class-a",
+			CreateCertificate: true,
+		},
+	}
+	ctx := context.Background()
+
+	// the desired child is annotated with the ingress class of the owner
+	desired := map[childKey]bool{{Kind: CertificateKind, ObjectKey: client.ObjectKeyFromObject(legacy)}: true}
+	if err := r.cleanupUnusedResources(ctx, hp, desired, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	cert := &cmv1.Certificate{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(legacy), cert); err != nil {
+		t.Fatal(err)
+	}
+	if got := cert.Annotations[ingressClassOwnerAnnotation]; got != "class-a" {
+		t.Errorf("ingress class of the child = %q, want class-a", got)
+	}
+
+	// the owner is moved to an ingress class that no instance watches
+	hp.Annotations[ingressClassNameAnnotation] = "class-c"
+	if err := c.Update(ctx, hp); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.cleanupAllResources(ctx, hp, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(legacy), &cmv1.Certificate{}); !apierrors.IsNotFound(err) {
+		t.Errorf("child of the moved owner is not deleted: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(hp), hp); err != nil {
+		t.Fatal(err)
+	}
+	if controllerutil.ContainsFinalizer(hp, finalizerName) {
+		t.Error("finalizer is not removed from the owner")
+	}
+
+	// the finalizer is kept for the children in other namespaces generated by other instances
+	other := newDummyCertificate(client.ObjectKey{Namespace: "certs", Name: "other-default-foo"}, "default/foo", dnsName)
+	other.Annotations[ingressClassOwnerAnnotation] = "class-b"
+	if err := c.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	controllerutil.AddFinalizer(hp, finalizerName)
+	if err := c.Update(ctx, hp); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.cleanupAllResources(ctx, hp, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(hp, finalizerName) {
+		t.Error("finalizer is removed while a child of another instance is left")
+	}
+}
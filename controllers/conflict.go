@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fqdnIndexField is the field index to look up HTTPProxies by spec.virtualhost.fqdn and the additional DNS names.
+const fqdnIndexField = ".spec.virtualhost.fqdn"
+
+// indexFQDN is the indexer function for fqdnIndexField.
+// The additional DNS names are indexed without validation, which is done by claimedHostnames.
+func indexFQDN(obj client.Object) []string {
+	hp, ok := obj.(*projectcontourv1.HTTPProxy)
+	if !ok {
+		return nil
+	}
+	hostnames := proxyHostnames(hp)
+	if len(hostnames) == 0 {
+		return nil
+	}
+	for _, name := range splitCommaSeparated(hp.Annotations[additionalDNSNamesAnnotation]) {
+		if !slices.Contains(hostnames, name) {
+			hostnames = append(hostnames, name)
+		}
+	}
+	return hostnames
+}
+
+// detectsFQDNConflicts returns true if FQDN conflicts between HTTPProxies are resolved by FQDNConflictPolicy.
+func (r *HTTPProxyReconciler) detectsFQDNConflicts() bool {
+	return r.FQDNConflictPolicy == FQDNConflictPolicyOldest
+}
+
+// isReconcileTarget returns true if contour-plus generates child resources for hp unless it conflicts with others.
+func (r *HTTPProxyReconciler) isReconcileTarget(hp *projectcontourv1.HTTPProxy) bool {
+	if hp.DeletionTimestamp != nil || hp.Annotations[excludeAnnotation] == "true" {
+		return false
+	}
+	return r.watchesAllIngressClasses() || r.isClassNameMatched(hp)
+}
+
+// claimedHostnamesFunc returns a function that returns the hostnames for which contour-plus generates child resources
+// for an HTTPProxy, that is, the FQDN and the additional DNS names permitted by DomainPolicy.
+// The DomainPolicy rules are read once for each namespace.
+func (r *HTTPProxyReconciler) claimedHostnamesFunc(ctx context.Context) func(*projectcontourv1.HTTPProxy) ([]string, error) {
+	rulesByNamespace := make(map[string]*domainPolicyRules)
+	return func(hp *projectcontourv1.HTTPProxy) ([]string, error) {
+		rules, ok := rulesByNamespace[hp.Namespace]
+		if !ok {
+			var err error
+			rules, err = r.getDomainPolicyRules(ctx, hp.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			rulesByNamespace[hp.Namespace] = rules
+		}
+		_, certHostnames := r.expandHostnames(hp, proxyHostnames(hp), logr.Discard())
+		var claimed []string
+		for _, hostname := range certHostnames {
+			if rules.permitsHostname(hostname) {
+				claimed = append(claimed, hostname)
+			}
+		}
+		return claimed, nil
+	}
+}
+
+// findFQDNConflict returns the HTTPProxy that wins a hostname claimed by hp and the hostname,
+// or nil if hp is the winner of all of them.
+// With FQDNConflictPolicyOldest, the HTTPProxy created earliest wins. Ties are broken by namespace and name.
+// HTTPProxies that are not reconciled by this contour-plus, and those not permitted to use the hostname by
+// DomainPolicy, are not taken into account.
+func (r *HTTPProxyReconciler) findFQDNConflict(ctx context.Context, hp *projectcontourv1.HTTPProxy) (*projectcontourv1.HTTPProxy, string, error) {
+	if !r.detectsFQDNConflicts() {
+		return nil, "", nil
+	}
+	claimedHostnames := r.claimedHostnamesFunc(ctx)
+	hostnames, err := claimedHostnames(hp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	winner, conflicting := hp, ""
+	for _, hostname := range hostnames {
+		var hpList projectcontourv1.HTTPProxyList
+		if err := r.List(ctx, &hpList, client.MatchingFields{fqdnIndexField: hostname}); err != nil {
+			return nil, "", err
+		}
+		for i := range hpList.Items {
+			other := &hpList.Items[i]
+			if other.UID == hp.UID || !r.isReconcileTarget(other) || !precedes(other, winner) {
+				continue
+			}
+			otherHostnames, err := claimedHostnames(other)
+			if err != nil {
+				return nil, "", err
+			}
+			if slices.Contains(otherHostnames, hostname) {
+				winner, conflicting = other, hostname
+			}
+		}
+	}
+	if winner == hp {
+		return nil, "", nil
+	}
+	return winner, conflicting, nil
+}
+
+// precedes returns true if a takes precedence over b for the same FQDN.
+func precedes(a, b *projectcontourv1.HTTPProxy) bool {
+	return compareOwners(a, b) < 0
+}
+
+// listConflictingHPs returns requests for the HTTPProxies sharing the hostnames of obj
+// so that the loser of a hostname is reconciled when the winner is changed or deleted.
+func (r *HTTPProxyReconciler) listConflictingHPs(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, hostname := range indexFQDN(obj) {
+		var hpList projectcontourv1.HTTPProxyList
+		if err := r.List(ctx, &hpList, client.MatchingFields{fqdnIndexField: hostname}); err != nil {
+			r.Log.Error(err, "listing HTTPProxy failed")
+			return nil
+		}
+		for _, hp := range hpList.Items {
+			req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&hp)}
+			if hp.UID == obj.GetUID() || slices.Contains(requests, req) {
+				continue
+			}
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
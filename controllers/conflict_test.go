@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+)
+
+func TestFindFQDNConflict(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	now := time.Now().Truncate(time.Second)
+	newHP := func(ns, name string, created time.Time) *projectcontourv1.HTTPProxy {
+		hp := newDummyHTTPProxy(client.ObjectKey{Namespace: ns, Name: name})
+		hp.UID = types.UID(ns + "/" + name)
+		hp.CreationTimestamp = v1.NewTime(created)
+		return hp
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		// domainPolicy enables DomainPolicy that permits example.com only for namespace b
+		domainPolicy bool
+		target       *projectcontourv1.HTTPProxy
+		others       []*projectcontourv1.HTTPProxy
+		expectWinner string
+		expectFQDN   string
+	}{
+		{
+			name:   "policy none",
+			policy: FQDNConflictPolicyNone,
+			target: newHP("b", "foo", now),
+			others: []*projectcontourv1.HTTPProxy{newHP("a", "foo", now.Add(-time.Hour))},
+		},
+		{
+			name:   "no conflicts",
+			policy: FQDNConflictPolicyOldest,
+			target: newHP("b", "foo", now),
+		},
+		{
+			name:   "oldest wins",
+			policy: FQDNConflictPolicyOldest,
+			target: newHP("a", "foo", now),
+			others: []*projectcontourv1.HTTPProxy{
+				newHP("b", "foo", now.Add(-time.Hour)),
+				newHP("c", "foo", now.Add(-time.Minute)),
+			},
+			expectWinner: "b/foo",
+			expectFQDN:   dnsName,
+		},
+		{
+			name:   "target is the oldest",
+			policy: FQDNConflictPolicyOldest,
+			target: newHP("b", "foo", now.Add(-time.Hour)),
+			others: []*projectcontourv1.HTTPProxy{newHP("a", "foo", now)},
+		},
+		{
+			name:         "tie broken by namespace",
+			policy:       FQDNConflictPolicyOldest,
+			target:       newHP("b", "foo", now),
+			others:       []*projectcontourv1.HTTPProxy{newHP("a", "foo", now)},
+			expectWinner: "a/foo",
+			expectFQDN:   dnsName,
+		},
+		{
+			name:   "excluded HTTPProxy is ignored",
+			policy: FQDNConflictPolicyOldest,
+			target: newHP("b", "foo", now),
+			others: func() []*projectcontourv1.HTTPProxy {
+				hp := newHP("a", "foo", now.Add(-time.Hour))
+				hp.Annotations[excludeAnnotation] = "true"
+				return []*projectcontourv1.HTTPProxy{hp}
+			}(),
+		},
+		{
+			name:   "HTTPProxy of another FQDN is ignored",
+			policy: FQDNConflictPolicyOldest,
+			target: newHP("b", "foo", now),
+			others: func() []*projectcontourv1.HTTPProxy {
+				hp := newHP("a", "foo", now.Add(-time.Hour))
+				hp.Spec.VirtualHost.Fqdn = "other.example.com"
+				return []*projectcontourv1.HTTPProxy{hp}
+			}(),
+		},
+		{
+			name:         "HTTPProxy denied by DomainPolicy is ignored",
+			policy:       FQDNConflictPolicyOldest,
+			domainPolicy: true,
+			target:       newHP("b", "foo", now),
+			others:       []*projectcontourv1.HTTPProxy{newHP("a", "foo", now.Add(-time.Hour))},
+		},
+		{
+			name:         "HTTPProxy permitted by DomainPolicy wins",
+			policy:       FQDNConflictPolicyOldest,
+			domainPolicy: true,
+			target:       newHP("b", "foo", now),
+			others: []*projectcontourv1.HTTPProxy{
+				newHP("a", "foo", now.Add(-time.Hour)),
+				newHP("b", "bar", now.Add(-time.Minute)),
+			},
+			expectWinner: "b/bar",
+			expectFQDN:   dnsName,
+		},
+		{
+			name:   "additional DNS names conflict",
+			policy: FQDNConflictPolicyOldest,
+			target: func() *projectcontourv1.HTTPProxy {
+				hp := newHP("b", "foo", now)
+				hp.Annotations[additionalDNSNamesAnnotation] = "extra.example.com"
+				return hp
+			}(),
+			others: func() []*projectcontourv1.HTTPProxy {
+				hp := newHP("a", "foo", now.Add(-time.Hour))
+				hp.Spec.VirtualHost.Fqdn = "other.example.com"
+				hp.Annotations[additionalDNSNamesAnnotation] = "extra.example.com"
+				return []*projectcontourv1.HTTPProxy{hp}
+			}(),
+			expectWinner: "a/foo",
+			expectFQDN:   "extra.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []client.Object{
+				tt.target,
+				&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "a"}},
+				&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "b"}},
+				&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "c"}},
+				&contourplusv1alpha1.DomainPolicy{
+					ObjectMeta: v1.ObjectMeta{Name: "b"},
+					Spec: contourplusv1alpha1.DomainPolicySpec{
+						Namespaces:     []string{"b"},
+						AllowedDomains: []string{"example.com"},
+					},
+				},
+			}
+			for _, hp := range tt.others {
+				objs = append(objs, hp)
+			}
+			c := crfake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objs...).
+				WithIndex(&projectcontourv1.HTTPProxy{}, fqdnIndexField, indexFQDN).
+				Build()
+			r := &HTTPProxyReconciler{
+				Client: c,
+				ReconcilerOptions: ReconcilerOptions{
+					FQDNConflictPolicy:          tt.policy,
+					EnableDomainPolicy:          tt.domainPolicy,
+					AllowedAdditionalDNSDomains: []string{"example.com"},
+				},
+			}
+
+			winner, fqdn, err := r.findFQDNConflict(context.Background(), tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if winner != nil {
+				got = client.ObjectKeyFromObject(winner).String()
+			}
+			if got != tt.expectWinner || fqdn != tt.expectFQDN {
+				t.Errorf("findFQDNConflict() = %q, %q, want %q, %q", got, fqdn, tt.expectWinner, tt.expectFQDN)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package controllers
 
 // Constants for Kinds
 const (
+	HTTPProxyKind                    = "HTTPProxy"
+	HTTPRouteKind                    = "HTTPRoute"
 	ClusterIssuerKind                = "ClusterIssuer"
 	IssuerKind                       = "Issuer"
 	CertificateKind                  = "Certificate"
@@ -18,3 +20,17 @@ const (
 	usageKeyEncipherment  = "key encipherment"
 	usageServerAuth       = "server auth"
 )
+
+// DefaultDNSTTL is the TTL of DNS records in seconds used when no TTL is specified
+const DefaultDNSTTL = 3600
+
+// Constants for FQDN conflict policies
+const (
+	// FQDNConflictPolicyNone generates child resources for all HTTPProxies regardless of FQDN conflicts
+	FQDNConflictPolicyNone = "none"
+	// FQDNConflictPolicyOldest generates child resources only for the HTTPProxy created earliest among those with the same FQDN
+	FQDNConflictPolicyOldest = "oldest"
+)
+
+// httpRouteNamePrefix is put before the names of the child resources of HTTPRoute
+const httpRouteNamePrefix = "httproute-"
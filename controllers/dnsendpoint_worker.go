@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+
+	"golang.org/x/time/rate"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const dnsEndpointApplierName = "dnsendpoint-apply"
+
+var _ Applier[*unstructured.Unstructured] = &DNSEndpointApplier{}
+
+// DNSEndpointApplier implements Applier[*unstructured.Unstructured] for DNSEndpoints without a workqueue.
+// Any objects applied with Apply method will be applied without going through a queue.
+type DNSEndpointApplier struct {
+	client client.Client
+}
+
+func (a *DNSEndpointApplier) Apply(ctx context.Context, obj *unstructured.Unstructured) error {
+	return applyDNSEndpoint(ctx, a.client, obj)
+}
+
+func NewDNSEndpointApplier(client client.Client) *DNSEndpointApplier {
+	return &DNSEndpointApplier{
+		client: client,
+	}
+}
+
+var _ ApplyWorker[*unstructured.Unstructured] = &DNSEndpointApplyWorker{}
+var _ Forgetter = &DNSEndpointApplyWorker{}
+
+// DNSEndpointApplyWorker implements Applier and ApplyWorker for DNSEndpoints.
+// Changes of the endpoints are rate limited so that a change of the load balancer addresses, which updates
+// all the DNSEndpoints at once, does not flood the DNS provider through external-dns.
+type DNSEndpointApplyWorker struct {
+	*applyWorker[*unstructured.Unstructured]
+	ReconcilerOptions
+}
+
+// NewDNSEndpointApplyWorker creates a DNSEndpointApplyWorker limited by DNSEndpointApplyLimit.
+func NewDNSEndpointApplyWorker(client client.Client, recorder events.EventRecorder, opt ReconcilerOptions) *DNSEndpointApplyWorker {
+	limit := opt.DNSEndpointApplyLimit
+
+	var limiter *rate.Limiter
+	if limit <= 0 {
+		limiter = rate.NewLimiter(rate.Inf, 1)
+	} else {
+		burst := max(int(math.Ceil(limit)), 1)
+		limiter = rate.NewLimiter(rate.Limit(limit), burst)
+	}
+
+	global := &workqueue.TypedBucketRateLimiter[types.NamespacedName]{Limiter: limiter}
+	expFailure := workqueue.NewTypedItemExponentialFailureRateLimiter[types.NamespacedName](opt.DNSEndpointApplyRetryBaseDelay, opt.DNSEndpointApplyRetryMaxDelay)
+	queue := workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedMaxOfRateLimiter(global, expFailure),
+		workqueue.TypedRateLimitingQueueConfig[types.NamespacedName]{
+			Name: dnsEndpointApplierName,
+		},
+	)
+	return &DNSEndpointApplyWorker{
+		applyWorker:       newApplyWorker(dnsEndpointApplierName, DNSEndpointKind, client, recorder, queue, applyDNSEndpoint, opt),
+		ReconcilerOptions: opt,
+	}
+}
+
+func (w *DNSEndpointApplyWorker) RegisterMetrics(registry metrics.RegistererGatherer) error {
+	return w.registerAppliedTotal(registry, "contour_plus_dnsendpoints_applied_total")
+}
+
+func (w *DNSEndpointApplyWorker) Apply(ctx context.Context, obj *unstructured.Unstructured) error {
+	log := crlog.FromContext(ctx)
+	objKey := client.ObjectKeyFromObject(obj)
+	requiresQueue, err := w.RequiresQueue(ctx, objKey, obj)
+	if err != nil {
+		return err
+	}
+	if requiresQueue {
+		w.enqueue(objKey, obj)
+		log.Info("dnsendpoint queued for apply", "key", objKey.String())
+		return nil
+	}
+	return w.applyDirectly(ctx, obj)
+}
+
+func (w *DNSEndpointApplyWorker) Start(ctx context.Context) error {
+	return w.run(ctx)
+}
+
+// RequiresQueue indicates whether the object should be queued or not.
+// New DNSEndpoints and changes of the spec are queued as they are propagated to the DNS provider.
+func (w *DNSEndpointApplyWorker) RequiresQueue(ctx context.Context, key types.NamespacedName, obj *unstructured.Unstructured) (bool, error) {
+	log := crlog.FromContext(ctx)
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(obj.GroupVersionKind())
+	err := w.client.Get(ctx, key, current)
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		log.Error(err, "unable to get DNSEndpoint resource")
+		return false, err
+	}
+	// The desired spec is built from typed maps and slices while the current one is decoded from JSON,
+	// so they are compared in JSON.
+	desiredSpec, err := json.Marshal(obj.Object["spec"])
+	if err != nil {
+		return false, err
+	}
+	currentSpec, err := json.Marshal(current.Object["spec"])
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(desiredSpec, currentSpec), nil
+}
+
+// applyDNSEndpoint applies provided DNSEndpoint object with provided context and apiserver client
+func applyDNSEndpoint(ctx context.Context, k8sClient client.Client, obj *unstructured.Unstructured) error {
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	return k8sClient.Patch(ctx, obj, client.Apply, &client.PatchOptions{
+		Force:        ptr.To(true),
+		FieldManager: "contour-plus",
+	})
+}
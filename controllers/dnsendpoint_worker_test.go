@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDNSEndpointApplyWorkerRequiresQueue(t *testing.T) {
+	newDNSEndpoint := func(name string, ip string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		obj.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": makeEndpoints(name+".example.com", []net.IP{net.ParseIP(ip)}, nil, 3600),
+		}
+		return obj
+	}
+
+	// the objects stored in the client should be decoded from JSON like those from apiserver
+	data, err := json.Marshal(newDNSEndpoint("existing", "10.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := &unstructured.Unstructured{}
+	if err := existing.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	c := crfake.NewClientBuilder().
+		WithScheme(runtime.NewScheme()).
+		WithObjects(existing).
+		Build()
+	w := NewDNSEndpointApplyWorker(c, nil, ReconcilerOptions{
+		DNSEndpointApplyLimit:          1,
+		DNSEndpointApplyRetryBaseDelay: time.Millisecond,
+		DNSEndpointApplyRetryMaxDelay:  time.Millisecond,
+	})
+	defer w.workqueue.ShutDown()
+
+	testCases := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "new DNSEndpoint",
+			obj:      newDNSEndpoint("new", "10.0.0.1"),
+			expected: true,
+		},
+		{
+			name:     "unchanged DNSEndpoint",
+			obj:      newDNSEndpoint("existing", "10.0.0.1"),
+			expected: false,
+		},
+		{
+			name:     "changed DNSEndpoint",
+			obj:      newDNSEndpoint("existing", "10.0.0.2"),
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := w.RequiresQueue(context.Background(), client.ObjectKeyFromObject(tc.obj), tc.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("RequiresQueue() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDNSEndpointApplyWorkerQueue(t *testing.T) {
+	c := crfake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	w := NewDNSEndpointApplyWorker(c, nil, ReconcilerOptions{
+		DNSEndpointApplyLimit:          1,
+		DNSEndpointApplyRetryBaseDelay: time.Millisecond,
+		DNSEndpointApplyRetryMaxDelay:  time.Millisecond,
+	})
+	if err := w.RegisterMetrics(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var keys []client.ObjectKey
+	for _, name := range []string{"foo", "bar"} {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		obj.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": makeEndpoints(name+".example.com", []net.IP{net.ParseIP("10.0.0.1")}, nil, 3600),
+		}
+		if err := w.Apply(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, client.ObjectKeyFromObject(obj))
+	}
+
+	// the second DNSEndpoint waits for the next token of the bucket,
+	// while the first one is delayed only by the base delay of retries
+	time.Sleep(100 * time.Millisecond)
+	if w.workqueue.Len() != 1 {
+		t.Errorf("workqueue.Len() = %d, want 1", w.workqueue.Len())
+	}
+	if got := w.pendingManifests(); got != 2 {
+		t.Errorf("pendingManifests() = %v, want 2", got)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Start(ctx)
+	}()
+
+	for _, key := range keys {
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+			err := c.Get(ctx, key, obj)
+			if err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("DNSEndpoint %s is not applied: %v", key, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if got := testutil.ToFloat64(w.appliedTotal.WithLabelValues(dnsEndpointApplierName, string(viaQueueYes), string(applyResultSuccess))); got != 2 {
+		t.Errorf("applied via queue = %v, want 2", got)
+	}
+	if got := w.pendingManifests(); got != 0 {
+		t.Errorf("pendingManifests() after apply = %v, want 0", got)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDNSEndpointApplyWorkerRetryHTTPRoute(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+	route := newDummyHTTPRoute(client.ObjectKey{Namespace: "default", Name: "foo"}, dnsName)
+	c := &failingPatchClient{Client: crfake.NewClientBuilder().WithScheme(scheme).WithObjects(route).Build()}
+	recorder := events.NewFakeRecorder(10)
+	w := NewDNSEndpointApplyWorker(c, recorder, ReconcilerOptions{
+		DNSEndpointApplyLimit:          10,
+		DNSEndpointApplyRetryBaseDelay: time.Millisecond,
+		DNSEndpointApplyRetryMaxDelay:  time.Millisecond,
+		WatchHTTPRoute:                 true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Start(ctx)
+	}()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	obj.SetNamespace("default")
+	obj.SetName("foo")
+	obj.SetAnnotations(map[string]string{ownerAnnotation: getOwnerKey(route)})
+	if err := w.Apply(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-w.GetRouteRetryChannel():
+		if evt.Object.Namespace != "default" || evt.Object.Name != "foo" {
+			t.Errorf("retried HTTPRoute = %s/%s, want default/foo", evt.Object.Namespace, evt.Object.Name)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("HTTPRoute is not sent to the retry channel")
+	}
+	select {
+	case evt := <-w.GetRetryChannel():
+		t.Errorf("HTTPProxy %s/%s is sent to the retry channel", evt.Object.Namespace, evt.Object.Name)
+	default:
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.HasPrefix(e, "Warning ApplyFailed failed to apply DNSEndpoint default/foo") {
+			t.Errorf("unexpected event %q", e)
+		}
+	default:
+		t.Error("no event is recorded on the HTTPRoute")
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	// HTTPProxies are the diagnoses of the HTTPProxies in the order of namespace and name
+	HTTPProxies []HTTPProxyDiagnosis
+	// Orphans describe the child resources whose owners do not exist
+	Orphans []string
+}
+
+// HTTPProxyDiagnosis explains how contour-plus handles an HTTPProxy.
+type HTTPProxyDiagnosis struct {
+	client.ObjectKey
+	// Children are the child resources that would be applied for the HTTPProxy
+	Children []string
+	// Notes explain which branches of the reconciliation apply to the HTTPProxy
+	Notes []string
+	// Problems are FQDN conflicts and the differences between the desired and the existing child resources
+	Problems []string
+}
+
+// NumProblems returns the number of the problems and the orphans in the report.
+func (report *DoctorReport) NumProblems() int {
+	n := len(report.Orphans)
+	for _, diag := range report.HTTPProxies {
+		n += len(diag.Problems)
+	}
+	return n
+}
+
+// Doctor inspects the HTTPProxies and the child resources in the cluster, and explains how contour-plus handles them.
+// c should read from the API server directly because the field indexes of the informer cache are not used.
+func Doctor(ctx context.Context, c client.Client, opts ReconcilerOptions, scheme *runtime.Scheme, log logr.Logger) (*DoctorReport, error) {
+	r := &HTTPProxyReconciler{
+		Client:            c,
+		ReconcilerOptions: opts,
+		Log:               log,
+		Scheme:            scheme,
+		ChildReader:       c,
+		APIReader:         c,
+	}
+
+	var hpList projectcontourv1.HTTPProxyList
+	if err := r.List(ctx, &hpList); err != nil {
+		return nil, err
+	}
+	hps := make([]*projectcontourv1.HTTPProxy, 0, len(hpList.Items))
+	for i := range hpList.Items {
+		hps = append(hps, &hpList.Items[i])
+	}
+	slices.SortFunc(hps, func(a, b *projectcontourv1.HTTPProxy) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+
+	children, err := r.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orphans, err := r.listOrphans(ctx, children)
+	if err != nil {
+		return nil, err
+	}
+
+	// the HTTPProxies sharing hostnames; the first one wins with FQDNConflictPolicyOldest
+	claimedHostnames := r.claimedHostnamesFunc(ctx)
+	claimed := make(map[*projectcontourv1.HTTPProxy][]string)
+	byFQDN := make(map[string][]*projectcontourv1.HTTPProxy)
+	for _, hp := range hps {
+		if !r.isReconcileTarget(hp) {
+			continue
+		}
+		hostnames, err := claimedHostnames(hp)
+		if err != nil {
+			return nil, err
+		}
+		claimed[hp] = hostnames
+		for _, hostname := range hostnames {
+			byFQDN[hostname] = append(byFQDN[hostname], hp)
+		}
+	}
+	for _, sharing := range byFQDN {
+		slices.SortFunc(sharing, func(a, b *projectcontourv1.HTTPProxy) int { return compareOwners(a, b) })
+	}
+
+	report := &DoctorReport{}
+	for _, hp := range hps {
+		diag, err := r.diagnose(ctx, hp, children, claimed[hp], byFQDN, log)
+		if err != nil {
+			return nil, err
+		}
+		report.HTTPProxies = append(report.HTTPProxies, *diag)
+	}
+
+	for _, key := range orphans {
+		report.Orphans = append(report.Orphans, fmt.Sprintf("%s: owner %s does not exist", describeChild(key), indexOwner(children[key])[0]))
+	}
+	return report, nil
+}
+
+// diagnose explains how hp is reconciled, and compares the desired child resources with the existing ones in children.
+// hostnames are the hostnames claimed by hp, and byFQDN maps them to the HTTPProxies sharing them in the order of precedence.
+func (r *HTTPProxyReconciler) diagnose(ctx context.Context, hp *projectcontourv1.HTTPProxy, children map[childKey]client.Object, hostnames []string, byFQDN map[string][]*projectcontourv1.HTTPProxy, log logr.Logger) (*HTTPProxyDiagnosis, error) {
+	diag := &HTTPProxyDiagnosis{ObjectKey: client.ObjectKeyFromObject(hp)}
+	log = log.WithValues("httpproxy", diag.ObjectKey)
+	// the namespaces are evaluated once for hp as Reconcile does
+	ctx = withAllowedNamespaces(ctx)
+
+	if hp.DeletionTimestamp != nil {
+		diag.Notes = append(diag.Notes, "being deleted")
+		return diag, nil
+	}
+
+	result := &renderResult{}
+	if winner := r.diagnoseFQDNConflict(hp, hostnames, byFQDN, diag); winner == nil {
+		rules, err := r.getDomainPolicyRules(ctx, hp.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		serviceIPs, serviceHostnames := r.diagnoseLoadBalancer(ctx, hp, diag)
+		result, err = r.renderHTTPProxy(ctx, hp, rules, serviceIPs, serviceHostnames, log)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.adoptSecret(ctx, hp, result.objects); err != nil {
+			return nil, err
+		}
+	}
+	diag.Notes = append(diag.Notes, result.notes...)
+
+	for _, obj := range result.objects {
+		key := childKey{Kind: obj.GetObjectKind().GroupVersionKind().Kind, ObjectKey: client.ObjectKeyFromObject(obj)}
+		diag.Children = append(diag.Children, describeChild(key))
+
+		live, ok := children[key]
+		if !ok {
+			diag.Problems = append(diag.Problems, describeChild(key)+" does not exist")
+			continue
+		}
+		if owner := live.GetAnnotations()[ownerAnnotation]; owner != getOwnerKey(hp) {
+			diag.Problems = append(diag.Problems, fmt.Sprintf("%s is owned by %q", describeChild(key), owner))
+		}
+		fields, err := diffSpec(obj, live)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 0 {
+			diag.Problems = append(diag.Problems, fmt.Sprintf("%s differs from the desired spec in %s", describeChild(key), strings.Join(fields, ", ")))
+		}
+	}
+
+	// the children left by the previous reconciliations are deleted by cleanupUnusedResources
+	for _, key := range sortedChildKeys(children) {
+		obj := children[key]
+		if indexOwner(obj)[0] != getOwnerKey(hp) || result.desired[key] {
+			continue
+		}
+		if !r.ownsChild(hp, obj) {
+			continue
+		}
+		diag.Problems = append(diag.Problems, describeChild(key)+" is no longer desired and will be deleted")
+	}
+	return diag, nil
+}
+
+// adoptSecret makes the Certificate and the TLSCertificateDelegation in objects refer to the Secret adopted
+// by the migration of the name prefix as Reconcile does, so that the adoption is not reported as a difference.
+func (r *HTTPProxyReconciler) adoptSecret(ctx context.Context, hp *projectcontourv1.HTTPProxy, objects []client.Object) error {
+	adoptedSecretName, err := r.getAdoptedSecretName(ctx, hp, getCertificateSecretName(r, hp))
+	if err != nil || adoptedSecretName == "" {
+		return err
+	}
+	for i, obj := range objects {
+		switch o := obj.(type) {
+		case *cmv1.Certificate:
+			setAdoptedSecret(o, adoptedSecretName)
+		case *unstructured.Unstructured:
+			if o.GetKind() != TLSCertificateDelegationKind {
+				continue
+			}
+			tcd := r.buildTLSCertificateDelegation(hp, o.GetNamespace(), adoptedSecretName)
+			if err := r.setResourceOwnership(hp, tcd); err != nil {
+				return err
+			}
+			objects[i] = tcd
+		}
+	}
+	return nil
+}
+
+// diagnoseFQDNConflict adds the problems of FQDN conflicts of hp over hostnames to diag.
+// It returns the HTTPProxy that wins a hostname if FQDNConflictPolicy refuses hp, or nil otherwise.
+func (r *HTTPProxyReconciler) diagnoseFQDNConflict(hp *projectcontourv1.HTTPProxy, hostnames []string, byFQDN map[string][]*projectcontourv1.HTTPProxy, diag *HTTPProxyDiagnosis) *projectcontourv1.HTTPProxy {
+	if len(hostnames) == 0 || !r.isReconcileTarget(hp) {
+		return nil
+	}
+
+	if r.detectsFQDNConflicts() {
+		var winner *projectcontourv1.HTTPProxy
+		var conflicting string
+		for _, hostname := range hostnames {
+			if first := byFQDN[hostname][0]; first != hp && (winner == nil || precedes(first, winner)) {
+				winner, conflicting = first, hostname
+			}
+		}
+		if winner == nil {
+			return nil
+		}
+		diag.Problems = append(diag.Problems, fmt.Sprintf("FQDN %q is claimed by HTTPProxy %s/%s", conflicting, winner.Namespace, winner.Name))
+		diag.Notes = append(diag.Notes, "no resources are generated because of the FQDN conflict")
+		return winner
+	}
+
+	for _, hostname := range hostnames {
+		var others []string
+		for _, other := range byFQDN[hostname] {
+			if other != hp {
+				others = append(others, other.Namespace+"/"+other.Name)
+			}
+		}
+		if len(others) != 0 {
+			diag.Problems = append(diag.Problems, fmt.Sprintf("FQDN %q is shared with HTTPProxy %s", hostname, strings.Join(others, ", ")))
+		}
+	}
+	return nil
+}
+
+// diagnoseLoadBalancer returns the addresses of the load balancer for hp.
+// The problems reading the Service are added to diag.
+func (r *HTTPProxyReconciler) diagnoseLoadBalancer(ctx context.Context, hp *projectcontourv1.HTTPProxy, diag *HTTPProxyDiagnosis) ([]net.IP, []string) {
+	if !r.CreateDNSEndpoint {
+		return nil, nil
+	}
+	// the load balancer is not used if the annotation specifies the targets, or if it is invalid
+	if ips, hostnames, err := r.getDNSTargets(hp); err != nil || len(ips) != 0 || len(hostnames) != 0 {
+		return nil, nil
+	}
+
+	serviceKey, err := r.getServiceKey(hp)
+	if err != nil {
+		return nil, nil
+	}
+	var svc corev1.Service
+	if err := r.Get(ctx, serviceKey, &svc); err != nil {
+		diag.Problems = append(diag.Problems, fmt.Sprintf("unable to get Service %s: %v", serviceKey, err))
+		return nil, nil
+	}
+	var ips []net.IP
+	var hostnames []string
+	for _, ing := range svc.Status.LoadBalancer.Ingress {
+		if len(ing.IP) != 0 {
+			ips = append(ips, net.ParseIP(ing.IP))
+			continue
+		}
+		if len(ing.Hostname) != 0 {
+			hostnames = append(hostnames, ing.Hostname)
+		}
+	}
+	return ips, hostnames
+}
+
+// listChildren lists the child resources generated by contour-plus in all namespaces.
+// Resources without ownerAnnotation or the controller reference to an owner are not included.
+func (r *HTTPProxyReconciler) listChildren(ctx context.Context, opts ...client.ListOption) (map[childKey]client.Object, error) {
+	children := make(map[childKey]client.Object)
+	for kind, list := range r.childLists() {
+		if err := r.ChildReader.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			if len(indexOwner(obj)) == 0 {
+				continue
+			}
+			children[childKey{Kind: kind, ObjectKey: client.ObjectKeyFromObject(obj)}] = obj
+		}
+	}
+	return children, nil
+}
+
+// sortedChildKeys returns the keys of children in the order of kind, namespace and name.
+func sortedChildKeys(children map[childKey]client.Object) []childKey {
+	keys := make([]childKey, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b childKey) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return keys
+}
+
+func describeChild(key childKey) string {
+	return key.Kind + " " + key.Namespace + "/" + key.Name
+}
+
+// diffSpec returns the names of the fields of spec that differ between desired and live.
+func diffSpec(desired, live client.Object) ([]string, error) {
+	desiredSpec, err := specOf(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveSpec, err := specOf(live)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field, value := range desiredSpec {
+		if !reflect.DeepEqual(value, liveSpec[field]) {
+			fields = append(fields, field)
+		}
+	}
+	for field := range liveSpec {
+		if _, ok := desiredSpec[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return fields, nil
+}
+
+// specOf returns the spec of obj decoded from JSON so that typed and unstructured objects can be compared.
+func specOf(obj client.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var content struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	return content.Spec, nil
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDoctor(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	serviceKey := client.ObjectKey{Namespace: "ingress", Name: "envoy"}
+	svc := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Namespace: serviceKey.Namespace, Name: serviceKey.Name},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}}},
+		},
+	}
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	foo := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	foo.CreationTimestamp = v1.NewTime(created)
+	// bar shares the FQDN with foo, but it is created later
+	bar := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "bar"})
+	bar.CreationTimestamp = v1.NewTime(created.Add(time.Minute))
+	bar.Annotations = nil
+	excluded := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "excluded"})
+	excluded.Spec.VirtualHost.Fqdn = "excluded.example.com"
+	excluded.Annotations[excludeAnnotation] = "true"
+	noIssuer := newDummyHTTPProxy(client.ObjectKey{Namespace: "other", Name: "no-issuer"})
+	noIssuer.Spec.VirtualHost.Fqdn = "no-issuer.example.com"
+	noIssuer.Annotations[issuerNameAnnotation] = ""
+
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			svc, foo, bar, excluded, noIssuer,
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "foo"}, "default/foo", "old.example.com"),
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "excluded"}, "default/excluded", "excluded.example.com"),
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "gone"}, "default/gone", "gone.example.com"),
+		).
+		Build()
+
+	opts := ReconcilerOptions{
+		ServiceKey:         serviceKey,
+		DefaultIssuerKind:  ClusterIssuerKind,
+		DefaultIssuerName:  "letsencrypt",
+		DefaultDNSTTL:      DefaultDNSTTL,
+		FQDNConflictPolicy: FQDNConflictPolicyOldest,
+		CreateCertificate:  true,
+	}
+	report, err := Doctor(context.Background(), c, opts, scheme, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnoses := make(map[client.ObjectKey]HTTPProxyDiagnosis)
+	var keys []string
+	for _, diag := range report.HTTPProxies {
+		diagnoses[diag.ObjectKey] = diag
+		keys = append(keys, diag.String())
+	}
+	expectKeys := []string{"default/bar", "default/excluded", "default/foo", "other/no-issuer"}
+	if !slices.Equal(keys, expectKeys) {
+		t.Fatalf("HTTPProxies = %v, want %v", keys, expectKeys)
+	}
+
+	testCases := []struct {
+		key      client.ObjectKey
+		children []string
+		notes    []string
+		problems []string
+	}{
+		{
+			key:      client.ObjectKey{Namespace: "default", Name: "foo"},
+			children: []string{"Certificate default/foo"},
+			problems: []string{"Certificate default/foo differs from the desired spec in commonName, dnsNames, issuerRef, usages"},
+		},
+		{
+			key:      client.ObjectKey{Namespace: "default", Name: "bar"},
+			notes:    []string{"no resources are generated because of the FQDN conflict"},
+			problems: []string{`FQDN "test.example.com" is claimed by HTTPProxy default/foo`},
+		},
+		{
+			key:      client.ObjectKey{Namespace: "default", Name: "excluded"},
+			notes:    []string{"excluded by the contour-plus.cybozu.com/exclude annotation"},
+			problems: []string{"Certificate default/excluded is no longer desired and will be deleted"},
+		},
+		{
+			key:   client.ObjectKey{Namespace: "other", Name: "no-issuer"},
+			notes: []string{"Certificate is not generated because no issuer is specified"},
+		},
+	}
+	for _, tc := range testCases {
+		diag := diagnoses[tc.key]
+		if !slices.Equal(diag.Children, tc.children) {
+			t.Errorf("%s: children = %q, want %q", tc.key, diag.Children, tc.children)
+		}
+		if !slices.Equal(diag.Notes, tc.notes) {
+			t.Errorf("%s: notes = %q, want %q", tc.key, diag.Notes, tc.notes)
+		}
+		if !slices.Equal(diag.Problems, tc.problems) {
+			t.Errorf("%s: problems = %q, want %q", tc.key, diag.Problems, tc.problems)
+		}
+	}
+
+	expectOrphans := []string{"Certificate default/gone: owner default/gone does not exist"}
+	if !slices.Equal(report.Orphans, expectOrphans) {
+		t.Errorf("orphans = %q, want %q", report.Orphans, expectOrphans)
+	}
+	if n := report.NumProblems(); n != 4 {
+		t.Errorf("NumProblems() = %d, want 4", n)
+	}
+}
+
+func TestDoctorAdoptedSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	opts := ReconcilerOptions{
+		Prefix:                  "new-",
+		PreviousPrefix:          "old-",
+		MigrateNamePrefix:       true,
+		DefaultIssuerKind:       ClusterIssuerKind,
+		DefaultIssuerName:       "letsencrypt",
+		AllowedIssuerNamespaces: []string{"certs"},
+		CreateCertificate:       true,
+	}
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[issuerNamespaceAnnotation] = "certs"
+
+	// the live children refer to the Secret adopted from the Certificate named with the previous prefix
+	objs, err := Render(hp, opts, scheme, nil, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("Render() = %v, want Certificate and TLSCertificateDelegation", objs)
+	}
+	cert := objs[0].(*cmv1.Certificate)
+	setAdoptedSecret(cert, "old-default-foo")
+	tcd := (&HTTPProxyReconciler{ReconcilerOptions: opts}).buildTLSCertificateDelegation(hp, "certs", "old-default-foo")
+	tcd.SetAnnotations(map[string]string{ownerAnnotation: "default/foo"})
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(hp, cert, tcd).
+		Build()
+
+	report, err := Doctor(context.Background(), c, opts, scheme, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.NumProblems(); n != 0 {
+		t.Errorf("NumProblems() = %d, want 0: %+v", n, report)
+	}
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=contour-plus.cybozu.com,resources=domainpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// domainPolicyRules is the set of the DomainPolicies applied to a namespace.
+// nil means that DomainPolicy is not enabled and everything is permitted.
+type domainPolicyRules struct {
+	policies []contourplusv1alpha1.DomainPolicySpec
+}
+
+// permitsHostname returns true if hostname is equal to or under one of the domains allowed by any policy.
+func (rules *domainPolicyRules) permitsHostname(hostname string) bool {
+	return rules.permitsHostnames([]string{hostname}, func(*contourplusv1alpha1.DomainPolicySpec) bool { return true })
+}
+
+// permitsIssuer returns true if the issuer can be used for a certificate of hostnames.
+// Each of hostnames should be allowed by a policy that also allows the issuer.
+func (rules *domainPolicyRules) permitsIssuer(kind, name string, hostnames []string) bool {
+	issuer := contourplusv1alpha1.IssuerReference{Kind: kind, Name: name}
+	return rules.permitsHostnames(hostnames, func(policy *contourplusv1alpha1.DomainPolicySpec) bool {
+		return len(policy.AllowedIssuers) == 0 || slices.Contains(policy.AllowedIssuers, issuer)
+	})
+}
+
+// permitsDelegatedDomain returns true if DNS-01 validation of hostnames can be delegated to domain.
+// Each of hostnames should be allowed by a policy that also allows the delegated domain.
+func (rules *domainPolicyRules) permitsDelegatedDomain(domain string, hostnames []string) bool {
+	return rules.permitsHostnames(hostnames, func(policy *contourplusv1alpha1.DomainPolicySpec) bool {
+		return len(policy.AllowedDelegatedDomains) == 0 || slices.Contains(policy.AllowedDelegatedDomains, domain)
+	})
+}
+
+// permitsHostnames returns true if each of hostnames is allowed by a policy that satisfies permits.
+// The policies are not combined, so a hostname allowed by one policy cannot be used with what only another allows.
+func (rules *domainPolicyRules) permitsHostnames(hostnames []string, permits func(*contourplusv1alpha1.DomainPolicySpec) bool) bool {
+	if rules == nil {
+		return true
+	}
+	for _, hostname := range hostnames {
+		hostname = strings.TrimPrefix(hostname, "*.")
+		permitted := slices.ContainsFunc(rules.policies, func(policy contourplusv1alpha1.DomainPolicySpec) bool {
+			return isUnderDomains(hostname, policy.AllowedDomains) && permits(&policy)
+		})
+		if !permitted {
+			return false
+		}
+	}
+	return true
+}
+
+// getDomainPolicyRules returns the rules of the DomainPolicies applied to the namespace.
+// If no DomainPolicy is applied, nothing is permitted.
+func (r *HTTPProxyReconciler) getDomainPolicyRules(ctx context.Context, namespace string) (*domainPolicyRules, error) {
+	if !r.EnableDomainPolicy {
+		return nil, nil
+	}
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		return nil, err
+	}
+	var policies contourplusv1alpha1.DomainPolicyList
+	if err := r.List(ctx, &policies); err != nil {
+		return nil, err
+	}
+
+	rules := &domainPolicyRules{}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		applied, err := domainPolicyApplies(policy, &ns)
+		if err != nil {
+			r.Log.Error(err, "invalid namespace selector of DomainPolicy", "name", policy.Name)
+			continue
+		}
+		if !applied {
+			continue
+		}
+
+		rules.policies = append(rules.policies, policy.Spec)
+	}
+	return rules, nil
+}
+
+// domainPolicyApplies returns true if the policy selects the namespace either by name or by labels.
+func domainPolicyApplies(policy *contourplusv1alpha1.DomainPolicy, ns *corev1.Namespace) (bool, error) {
+	if slices.Contains(policy.Spec.Namespaces, ns.Name) {
+		return true, nil
+	}
+	if policy.Spec.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// watchDomainPolicies adds watches for DomainPolicies and Namespaces to the controller builder
+// so that the owners are reconciled when the policies applied to them are changed.
+// newList returns an empty list of the owners.
+func (r *HTTPProxyReconciler) watchDomainPolicies(b *builder.Builder, newList func() client.ObjectList) *builder.Builder {
+	if !r.EnableDomainPolicy {
+		return b
+	}
+
+	listAll := func(ctx context.Context, _ client.Object) []reconcile.Request {
+		return r.listRequests(ctx, newList())
+	}
+	listInNamespace := func(ctx context.Context, ns client.Object) []reconcile.Request {
+		return r.listRequests(ctx, newList(), client.InNamespace(ns.GetName()))
+	}
+	return b.
+		Watches(&contourplusv1alpha1.DomainPolicy{}, handler.EnqueueRequestsFromMapFunc(listAll), builder.WithPredicates(ignoreInitialCreateEvent)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(listInNamespace), builder.WithPredicates(ignoreInitialCreateEvent, predicate.LabelChangedPredicate{}))
+}
+
+// listRequests returns requests for all the objects in list.
+func (r *HTTPProxyReconciler) listRequests(ctx context.Context, list client.ObjectList, opts ...client.ListOption) []reconcile.Request {
+	return r.listRequestsFunc(ctx, list, nil, opts...)
+}
+
+// listRequestsFunc returns requests for the objects in list that satisfy match. A nil match matches all.
+func (r *HTTPProxyReconciler) listRequestsFunc(ctx context.Context, list client.ObjectList, match func(client.Object) bool, opts ...client.ListOption) []reconcile.Request {
+	if err := r.List(ctx, list, opts...); err != nil {
+		r.Log.Error(err, "listing objects failed")
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		r.Log.Error(err, "extracting objects failed")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range items {
+		obj := item.(client.Object)
+		if match != nil && !match(obj) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	}
+	return requests
+}
+
+// filterPermittedHostnames removes the hostnames not permitted by DomainPolicy.
+// certHostnames should contain all of dnsHostnames, and an event is recorded for each of them denied.
+func (r *HTTPProxyReconciler) filterPermittedHostnames(owner client.Object, rules *domainPolicyRules, dnsHostnames, certHostnames []string, log logr.Logger) ([]string, []string) {
+	if rules == nil {
+		return dnsHostnames, certHostnames
+	}
+	for _, hostname := range certHostnames {
+		if rules.permitsHostname(hostname) {
+			continue
+		}
+		log.Info("hostname is not permitted by DomainPolicy", "hostname", hostname)
+		r.recordEvent(owner, nil, corev1.EventTypeWarning, eventReasonDeniedByPolicy, eventActionReconcile,
+			"hostname %q is not permitted by DomainPolicy", hostname)
+	}
+
+	filter := func(names []string) []string {
+		var permitted []string
+		for _, name := range names {
+			if rules.permitsHostname(name) {
+				permitted = append(permitted, name)
+			}
+		}
+		return permitted
+	}
+	return filter(dnsHostnames), filter(certHostnames)
+}
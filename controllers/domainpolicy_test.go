@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+)
+
+func TestGetDomainPolicyRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	namespaces := []client.Object{
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "team-b", Labels: map[string]string{"team": "b"}}},
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "other"}},
+	}
+	policies := []client.Object{
+		&contourplusv1alpha1.DomainPolicy{
+			ObjectMeta: v1.ObjectMeta{Name: "team-a"},
+			Spec: contourplusv1alpha1.DomainPolicySpec{
+				Namespaces:     []string{"team-a"},
+				AllowedDomains: []string{"a.example.com"},
+				AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Kind: ClusterIssuerKind, Name: "letsencrypt"}},
+			},
+		},
+		&contourplusv1alpha1.DomainPolicy{
+			ObjectMeta: v1.ObjectMeta{Name: "team-b"},
+			Spec: contourplusv1alpha1.DomainPolicySpec{
+				NamespaceSelector:       &v1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+				AllowedDomains:          []string{"b.example.com"},
+				AllowedDelegatedDomains: []string{testDelegationName},
+			},
+		},
+		&contourplusv1alpha1.DomainPolicy{
+			ObjectMeta: v1.ObjectMeta{Name: "shared"},
+			Spec: contourplusv1alpha1.DomainPolicySpec{
+				Namespaces:     []string{"team-a", "team-b"},
+				AllowedDomains: []string{"shared.example.com"},
+				AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Kind: IssuerKind, Name: "internal"}},
+			},
+		},
+	}
+
+	r := &HTTPProxyReconciler{
+		Client: crfake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(append(namespaces, policies...)...).
+			Build(),
+		ReconcilerOptions: ReconcilerOptions{EnableDomainPolicy: true},
+	}
+
+	tests := []struct {
+		namespace          string
+		permittedHostnames []string
+		deniedHostnames    []string
+		// hostname, kind and name of issuers
+		permittedIssuers [][3]string
+		deniedIssuers    [][3]string
+		// hostname and delegated domain
+		permittedDelegations [][2]string
+		deniedDelegations    [][2]string
+	}{
+		{
+			namespace:          "team-a",
+			permittedHostnames: []string{"a.example.com", "www.a.example.com", "*.a.example.com", "shared.example.com"},
+			deniedHostnames:    []string{"b.example.com", "example.com", "xa.example.com"},
+			permittedIssuers: [][3]string{
+				{"a.example.com", ClusterIssuerKind, "letsencrypt"},
+				{"shared.example.com", IssuerKind, "internal"},
+			},
+			deniedIssuers: [][3]string{
+				{"a.example.com", IssuerKind, "letsencrypt"},
+				{"a.example.com", ClusterIssuerKind, "other"},
+				// the issuer is permitted only by the other policy applied to the namespace
+				{"a.example.com", IssuerKind, "internal"},
+				{"shared.example.com", ClusterIssuerKind, "letsencrypt"},
+			},
+			permittedDelegations: [][2]string{{"a.example.com", testDelegationName}, {"shared.example.com", "other.example.com"}},
+		},
+		{
+			namespace:          "team-b",
+			permittedHostnames: []string{"b.example.com", "shared.example.com"},
+			deniedHostnames:    []string{"a.example.com"},
+			permittedIssuers:   [][3]string{{"b.example.com", ClusterIssuerKind, "other"}},
+			deniedIssuers:      [][3]string{{"shared.example.com", ClusterIssuerKind, "other"}},
+			permittedDelegations: [][2]string{
+				{"b.example.com", testDelegationName},
+				// the shared policy permits any delegated domain
+				{"shared.example.com", testDelegationName},
+				{"shared.example.com", "other.example.com"},
+			},
+			// the delegated domain is permitted only by the other policy applied to the namespace
+			deniedDelegations: [][2]string{{"b.example.com", "other.example.com"}},
+		},
+		{
+			namespace:         "other",
+			deniedHostnames:   []string{"a.example.com", "shared.example.com"},
+			deniedIssuers:     [][3]string{{"a.example.com", ClusterIssuerKind, "letsencrypt"}},
+			deniedDelegations: [][2]string{{"a.example.com", testDelegationName}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			rules, err := r.getDomainPolicyRules(context.Background(), tt.namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range tt.permittedHostnames {
+				if !rules.permitsHostname(h) {
+					t.Errorf("permitsHostname(%q) = false, want true", h)
+				}
+			}
+			for _, h := range tt.deniedHostnames {
+				if rules.permitsHostname(h) {
+					t.Errorf("permitsHostname(%q) = true, want false", h)
+				}
+			}
+			for _, i := range tt.permittedIssuers {
+				if !rules.permitsIssuer(i[1], i[2], []string{i[0]}) {
+					t.Errorf("permitsIssuer(%q, %q, %q) = false, want true", i[1], i[2], i[0])
+				}
+			}
+			for _, i := range tt.deniedIssuers {
+				if rules.permitsIssuer(i[1], i[2], []string{i[0]}) {
+					t.Errorf("permitsIssuer(%q, %q, %q) = true, want false", i[1], i[2], i[0])
+				}
+			}
+			for _, d := range tt.permittedDelegations {
+				if !rules.permitsDelegatedDomain(d[1], []string{d[0]}) {
+					t.Errorf("permitsDelegatedDomain(%q, %q) = false, want true", d[1], d[0])
+				}
+			}
+			for _, d := range tt.deniedDelegations {
+				if rules.permitsDelegatedDomain(d[1], []string{d[0]}) {
+					t.Errorf("permitsDelegatedDomain(%q, %q) = true, want false", d[1], d[0])
+				}
+			}
+		})
+	}
+}
+
+func TestNilDomainPolicyRules(t *testing.T) {
+	// nil rules are used when DomainPolicy is not enabled, and by Render
+	var rules *domainPolicyRules
+	if !rules.permitsHostname("www.example.org") || !rules.permitsHostname("*.example.org") {
+		t.Error("nil rules do not permit hostnames")
+	}
+	if !rules.permitsIssuer(ClusterIssuerKind, "letsencrypt", []string{"www.example.org"}) {
+		t.Error("nil rules do not permit issuers")
+	}
+	if !rules.permitsDelegatedDomain("acme.example.net", []string{"www.example.org"}) {
+		t.Error("nil rules do not permit delegated domains")
+	}
+}
+
+func TestDomainPolicyRulesForCertificate(t *testing.T) {
+	rules := &domainPolicyRules{policies: []contourplusv1alpha1.DomainPolicySpec{
+		{
+			AllowedDomains: []string{"a.example.com"},
+			AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Kind: ClusterIssuerKind, Name: "letsencrypt"}},
+		},
+		{
+			AllowedDomains: []string{"shared.example.com"},
+			AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Kind: IssuerKind, Name: "internal"}},
+		},
+	}}
+
+	if !rules.permitsIssuer(ClusterIssuerKind, "letsencrypt", []string{"a.example.com", "*.a.example.com"}) {
+		t.Error("issuer is not permitted for the hostnames of the same policy")
+	}
+	// every hostname of a certificate should be permitted together with the issuer by one of the policies
+	if rules.permitsIssuer(ClusterIssuerKind, "letsencrypt", []string{"a.example.com", "shared.example.com"}) {
+		t.Error("issuer is permitted for a hostname of the other policy")
+	}
+}
+
+func TestFilterPermittedHostnames(t *testing.T) {
+	r := &HTTPProxyReconciler{}
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	dnsHostnames := []string{"a.example.com", "www.example.org"}
+	certHostnames := []string{"a.example.com", "www.example.org", "*.a.example.com"}
+
+	gotDNSHostnames, gotCertHostnames := r.filterPermittedHostnames(hp, nil, dnsHostnames, certHostnames, r.Log)
+	if !slices.Equal(gotDNSHostnames, dnsHostnames) || !slices.Equal(gotCertHostnames, certHostnames) {
+		t.Errorf("filterPermittedHostnames() without rules = %v, %v", gotDNSHostnames, gotCertHostnames)
+	}
+
+	rules := &domainPolicyRules{policies: []contourplusv1alpha1.DomainPolicySpec{{AllowedDomains: []string{"example.com"}}}}
+	gotDNSHostnames, gotCertHostnames = r.filterPermittedHostnames(hp, rules, dnsHostnames, certHostnames, r.Log)
+	if !slices.Equal(gotDNSHostnames, []string{"a.example.com"}) {
+		t.Errorf("filterPermittedHostnames() dnsHostnames = %v", gotDNSHostnames)
+	}
+	if !slices.Equal(gotCertHostnames, []string{"a.example.com", "*.a.example.com"}) {
+		t.Errorf("filterPermittedHostnames() certHostnames = %v", gotCertHostnames)
+	}
+}
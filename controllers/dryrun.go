@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Constants for the operation label of dryRunChangesTotal
+const (
+	dryRunOperationCreate = "create"
+	dryRunOperationUpdate = "update"
+	dryRunOperationDelete = "delete"
+)
+
+// ignoredDiffFields are the metadata fields that the apiserver updates by itself.
+var ignoredDiffFields = []string{"managedFields", "resourceVersion", "generation", "creationTimestamp", "uid"}
+
+var _ client.Client = &dryRunClient{}
+
+// dryRunClient is a client.Client that enforces dry-run mode on all mutating calls, like client.NewDryRunClient.
+// In addition, it logs the difference between the live object and the result of each call, and counts the changes
+// in dryRunChangesTotal. Nothing is changed in dry-run mode, so the same change is made on every reconciliation;
+// it is reported only when it differs from the change last reported for the object and the operation.
+type dryRunClient struct {
+	client.Client
+	// reported remembers the diffs last reported for each object keyed by the operations
+	reported warningMemo
+}
+
+// newDryRunClient wraps c to make changes in dry-run mode only.
+func newDryRunClient(c client.Client) client.Client {
+	return &dryRunClient{Client: client.NewDryRunClient(c)}
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	live, err := c.getLive(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	return c.reportChange(ctx, live, obj)
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	live, err := c.getLive(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	return c.reportChange(ctx, live, obj)
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	live, err := c.getLive(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	return c.reportChange(ctx, live, obj)
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	kind, err := c.kindOf(obj)
+	if err != nil {
+		return err
+	}
+	if !c.isNewChange(kind, obj, dryRunOperationDelete, "") {
+		return nil
+	}
+	crlog.FromContext(ctx).Info("dry-run: would delete", "kind", kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+	dryRunChangesTotal.WithLabelValues(kind, dryRunOperationDelete).Inc()
+	return nil
+}
+
+// getLive returns the live object of obj, or nil if it does not exist.
+func (c *dryRunClient) getLive(ctx context.Context, obj client.Object) (*unstructured.Unstructured, error) {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	err = c.Get(ctx, client.ObjectKeyFromObject(obj), live)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+// reportChange logs the difference between live and the result of the dry-run call as JSON patch operations.
+func (c *dryRunClient) reportChange(ctx context.Context, live *unstructured.Unstructured, result client.Object) error {
+	kind, err := c.kindOf(result)
+	if err != nil {
+		return err
+	}
+	operation := dryRunOperationUpdate
+	before := []byte("{}")
+	if live == nil {
+		operation = dryRunOperationCreate
+	} else {
+		before, err = normalizeForDiff(live)
+		if err != nil {
+			return err
+		}
+	}
+	after, err := normalizeForDiff(result)
+	if err != nil {
+		return err
+	}
+	ops, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	diff := make([]string, 0, len(ops))
+	for _, op := range ops {
+		diff = append(diff, op.Json())
+	}
+	// the operations of the objects are generated in random order
+	sorted := slices.Clone(diff)
+	slices.Sort(sorted)
+	if !c.isNewChange(kind, result, operation, strings.Join(sorted, ",")) {
+		return nil
+	}
+	crlog.FromContext(ctx).Info("dry-run: would "+operation, "kind", kind, "namespace", result.GetNamespace(), "name", result.GetName(), "diff", diff)
+	dryRunChangesTotal.WithLabelValues(kind, operation).Inc()
+	return nil
+}
+
+// isNewChange returns true if diff differs from the one last reported for the operation on obj, and remembers it.
+func (c *dryRunClient) isNewChange(kind string, obj client.Object, operation, diff string) bool {
+	key := kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+	return len(c.reported.update(key, operation, []string{diff})) != 0
+}
+
+func (c *dryRunClient) kindOf(obj client.Object) (string, error) {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return "", err
+	}
+	return gvk.Kind, nil
+}
+
+// normalizeForDiff returns the JSON of obj without ignoredDiffFields.
+func normalizeForDiff(obj client.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	for _, field := range ignoredDiffFields {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+	// typed objects may lose their TypeMeta when decoded
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	return json.Marshal(content)
+}
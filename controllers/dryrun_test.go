@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDryRunClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := cmv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	key := client.ObjectKey{Namespace: "default", Name: "foo"}
+	live := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: cmv1.CertificateSpec{
+			DNSNames:   []string{"foo.example.com"},
+			SecretName: "foo-tls",
+		},
+	}
+	inner := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(live).
+		Build()
+	c := newDryRunClient(inner)
+	ctx := context.Background()
+
+	updates := dryRunChangesTotal.WithLabelValues(CertificateKind, dryRunOperationUpdate)
+	creates := dryRunChangesTotal.WithLabelValues(CertificateKind, dryRunOperationCreate)
+	deletes := dryRunChangesTotal.WithLabelValues(CertificateKind, dryRunOperationDelete)
+	updatesBefore, createsBefore, deletesBefore := testutil.ToFloat64(updates), testutil.ToFloat64(creates), testutil.ToFloat64(deletes)
+
+	changed := &cmv1.Certificate{}
+	changed.SetGroupVersionKind(cmv1.SchemeGroupVersion.WithKind(CertificateKind))
+	changed.Namespace = key.Namespace
+	changed.Name = key.Name
+	changed.Spec = cmv1.CertificateSpec{
+		DNSNames:   []string{"bar.example.com"},
+		SecretName: "foo-tls",
+	}
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	err := c.Patch(ctx, changed, client.Apply, &client.PatchOptions{Force: ptr.To(true), FieldManager: "contour-plus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(updates) - updatesBefore; v != 1 {
+		t.Errorf("update changes = %v, want 1", v)
+	}
+
+	created := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{Namespace: key.Namespace, Name: "bar"},
+		Spec:       cmv1.CertificateSpec{DNSNames: []string{"bar.example.com"}, SecretName: "bar-tls"},
+	}
+	if err := c.Create(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(creates) - createsBefore; v != 1 {
+		t.Errorf("create changes = %v, want 1", v)
+	}
+
+	if err := c.Delete(ctx, live); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(deletes) - deletesBefore; v != 1 {
+		t.Errorf("delete changes = %v, want 1", v)
+	}
+
+	// the same changes made by the next reconciliation are not reported again
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	err = c.Patch(ctx, changed.DeepCopy(), client.Apply, &client.PatchOptions{Force: ptr.To(true), FieldManager: "contour-plus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(ctx, created.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, live); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(updates) - updatesBefore; v != 1 {
+		t.Errorf("update changes after repeating = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(creates) - createsBefore; v != 1 {
+		t.Errorf("create changes after repeating = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(deletes) - deletesBefore; v != 1 {
+		t.Errorf("delete changes after repeating = %v, want 1", v)
+	}
+
+	// nothing is changed
+	got := &cmv1.Certificate{}
+	if err := inner.Get(ctx, key, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Spec.DNSNames) != 1 || got.Spec.DNSNames[0] != "foo.example.com" {
+		t.Errorf("dnsNames = %v, want [foo.example.com]", got.Spec.DNSNames)
+	}
+	if err := inner.Get(ctx, client.ObjectKey{Namespace: key.Namespace, Name: "bar"}, &cmv1.Certificate{}); err == nil {
+		t.Error("Certificate default/bar was created")
+	}
+
+	// applying the same spec is not counted
+	updatesBefore = testutil.ToFloat64(updates)
+	same := got.DeepCopy()
+	same.ManagedFields = nil
+	same.ResourceVersion = ""
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	err = c.Patch(ctx, same, client.Apply, &client.PatchOptions{Force: ptr.To(true), FieldManager: "contour-plus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(updates) - updatesBefore; v != 0 {
+		t.Errorf("update changes = %v, want 0", v)
+	}
+}
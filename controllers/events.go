@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// eventRecorderName is the name of the event recorder used by contour-plus
+const eventRecorderName = "contour-plus"
+
+// Constants for event reasons
+const (
+	eventReasonCreated           = "Created"
+	eventReasonUpdated           = "Updated"
+	eventReasonIgnoredAnnotation = "IgnoredAnnotation"
+	eventReasonMissingIssuer     = "MissingIssuer"
+	eventReasonReconcileFailed   = "ReconcileFailed"
+	eventReasonApplyFailed       = "ApplyFailed"
+	eventReasonApplied           = "Applied"
+	eventReasonFQDNConflict      = "FQDNConflict"
+	eventReasonDeniedByPolicy    = "DeniedByPolicy"
+	eventReasonIssuanceFailed    = "IssuanceFailed"
+)
+
+// Constants for event actions
+const (
+	eventActionReconcile = "Reconcile"
+	eventActionApply     = "Apply"
+)
+
+// recordEvent records an event on the owner. It does nothing if Recorder is not set.
+func (r *HTTPProxyReconciler) recordEvent(owner client.Object, related runtime.Object, eventtype, reason, action, note string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(owner, related, eventtype, reason, action, note, args...)
+}
+
+// warningMemo remembers the warnings last recorded on each owner for each reason, so that the same warnings are
+// not recorded on every reconciliation. The zero value is ready to use.
+type warningMemo struct {
+	mu sync.Mutex
+	// warnings maps the owner keys to the warnings keyed by the reasons
+	warnings map[string]map[string][]string
+}
+
+// update replaces the warnings of the reason for the owner, and returns those not in the previous ones.
+func (m *warningMemo) update(owner, reason string, warnings []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := m.warnings[owner][reason]
+	var added []string
+	for _, warning := range warnings {
+		if !slices.Contains(previous, warning) {
+			added = append(added, warning)
+		}
+	}
+
+	if len(warnings) == 0 {
+		delete(m.warnings[owner], reason)
+		if len(m.warnings[owner]) == 0 {
+			delete(m.warnings, owner)
+		}
+		return added
+	}
+	if m.warnings == nil {
+		m.warnings = make(map[string]map[string][]string)
+	}
+	if m.warnings[owner] == nil {
+		m.warnings[owner] = make(map[string][]string)
+	}
+	m.warnings[owner][reason] = warnings
+	return added
+}
+
+// forget drops the warnings of the owner. It should be called when the owner is deleted.
+func (m *warningMemo) forget(owner string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.warnings, owner)
+}
+
+// recordIgnoredAnnotations records warning events for the annotations of the owner that are ignored
+// because their values are invalid or not allowed. The warnings recorded by the previous reconciliation
+// of the owner are not recorded again.
+// It returns an error if the namespaces in the annotations cannot be evaluated.
+func (r *HTTPProxyReconciler) recordIgnoredAnnotations(ctx context.Context, owner client.Object) error {
+	annotations := owner.GetAnnotations()
+	var warnings []string
+	ignore := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	if ns, ok := annotations[dnsNamespaceAnnotation]; ok && ns != "" && ns != owner.GetNamespace() {
+		allowed, err := r.isAllowedDNSNamespace(ctx, ns)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			recordSkip(ctx, owner, skipReasonDisallowedNamespace)
+			ignore("%s: namespace %q is not allowed for DNSEndpoint", dnsNamespaceAnnotation, ns)
+		}
+	}
+
+	if ns, ok := annotations[issuerNamespaceAnnotation]; ok && ns != "" && ns != owner.GetNamespace() {
+		allowed, err := r.isAllowedIssuerNamespace(ctx, ns)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			recordSkip(ctx, owner, skipReasonDisallowedNamespace)
+			ignore("%s: namespace %q is not allowed for Certificate", issuerNamespaceAnnotation, ns)
+		}
+	}
+
+	if domain := annotations[delegatedDomainAnnotation]; domain != "" {
+		switch {
+		case !r.AllowCustomDelegations:
+			ignore("%s: custom delegated domains are not allowed", delegatedDomainAnnotation)
+		case !slices.Contains(r.AllowedDelegatedDomains, domain):
+			ignore("%s: delegated domain %q is not in the allowed list", delegatedDomainAnnotation, domain)
+		}
+	}
+
+	for _, name := range splitCommaSeparated(annotations[additionalDNSNamesAnnotation]) {
+		if err := r.validateAdditionalDNSName(name); err != nil {
+			ignore("%s: %v", additionalDNSNamesAnnotation, err)
+		}
+	}
+
+	if _, err := r.getTargetServiceKey(owner); err != nil {
+		ignore("%s: %v, DNSEndpoint is not updated", targetServiceAnnotation, err)
+	}
+
+	if _, _, err := r.getDNSTargets(owner); err != nil {
+		ignore("%s: %v, DNSEndpoint is not updated", dnsTargetsAnnotation, err)
+	}
+
+	if value, ok := annotations[dnsTTLAnnotation]; ok {
+		if _, err := parseDNSTTL(value); err != nil {
+			ignore("%s: invalid value %q: %v", dnsTTLAnnotation, value, err)
+		}
+	}
+
+	if value, ok := annotations[revisionHistoryLimitAnnotation]; ok {
+		if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+			ignore("%s: invalid value %q, Certificate is not reconciled", revisionHistoryLimitAnnotation, value)
+		}
+	}
+
+	for _, key := range []string{durationAnnotation, renewBeforeAnnotation} {
+		if value, ok := annotations[key]; ok {
+			if _, err := parseCertificateDuration(value); err != nil {
+				ignore("%s: invalid value %q, Certificate is not reconciled: %v", key, value, err)
+			}
+		}
+	}
+
+	if _, ok := annotations[privateKeyAlgorithmAnnotation]; ok {
+		if value, ok := annotations[privateKeySizeAnnotation]; ok {
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				ignore("%s: invalid value %q", privateKeySizeAnnotation, value)
+			}
+		}
+	}
+
+	for _, note := range r.warnings.update(getOwnerKey(owner), eventReasonIgnoredAnnotation, warnings) {
+		r.recordEvent(owner, nil, corev1.EventTypeWarning, eventReasonIgnoredAnnotation, eventActionReconcile, "%s", note)
+	}
+	return nil
+}
+
+// applyChild applies obj with server-side apply and records an event on the owner if obj is created or updated.
+// The current resourceVersion is read from the informer cache to tell whether obj is changed.
+func (r *HTTPProxyReconciler) applyChild(ctx context.Context, owner client.Object, obj client.Object) error {
+	currentVersion, err := r.currentVersion(ctx, obj)
+	if err != nil {
+		return err
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
+		Force:        ptr.To(true),
+		FieldManager: "contour-plus",
+	})
+	if err != nil {
+		return err
+	}
+
+	r.recordApplied(owner, kind, obj, currentVersion)
+	return nil
+}
+
+// applyDNSEndpoint applies obj with DNSApplier and records an event on the owner if obj is created or updated.
+// If DNSApplier queues obj, the event is recorded by the worker when obj is applied.
+func (r *HTTPProxyReconciler) applyDNSEndpoint(ctx context.Context, owner client.Object, obj *unstructured.Unstructured) error {
+	if r.DNSApplier == nil {
+		return r.applyChild(ctx, owner, obj)
+	}
+
+	currentVersion, err := r.currentVersion(ctx, obj)
+	if err != nil {
+		return err
+	}
+
+	if err := r.DNSApplier.Apply(ctx, obj); err != nil {
+		return err
+	}
+	if obj.GetResourceVersion() != "" {
+		r.recordApplied(owner, DNSEndpointKind, obj, currentVersion)
+	}
+	return nil
+}
+
+// currentVersion returns the resourceVersion of obj in the informer cache, or an empty string if obj does not exist.
+func (r *HTTPProxyReconciler) currentVersion(ctx context.Context, obj client.Object) (string, error) {
+	var current client.Object
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		// unstructured children are built from typed slices, which cannot be deep copied
+		c := &unstructured.Unstructured{}
+		c.SetGroupVersionKind(u.GroupVersionKind())
+		current = c
+	} else {
+		current = obj.DeepCopyObject().(client.Object)
+	}
+	err := r.ChildReader.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if k8serrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return current.GetResourceVersion(), nil
+}
+
+// recordApplied records an event on the owner if obj is created or updated from the resourceVersion before applied.
+// kind is passed separately because typed objects may lose their TypeMeta when decoded.
+func (r *HTTPProxyReconciler) recordApplied(owner client.Object, kind string, obj client.Object, previousVersion string) {
+	switch {
+	case previousVersion == "":
+		r.recordEvent(owner, obj, corev1.EventTypeNormal, eventReasonCreated, eventActionApply,
+			"Created %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	case previousVersion != obj.GetResourceVersion():
+		r.recordEvent(owner, obj, corev1.EventTypeNormal, eventReasonUpdated, eventActionApply,
+			"Updated %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	}
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRecordIgnoredAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        ReconcilerOptions
+		annotations map[string]string
+		expect      []string
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name: "allowed annotations",
+			opts: ReconcilerOptions{
+				AllowedDNSNamespaces:    []string{"dns"},
+				AllowedIssuerNamespaces: []string{"issuer"},
+				AllowCustomDelegations:  true,
+				AllowedDelegatedDomains: []string{testDelegationName},
+			},
+			annotations: map[string]string{
+				dnsNamespaceAnnotation:         "dns",
+				issuerNamespaceAnnotation:      "issuer",
+				delegatedDomainAnnotation:      testDelegationName,
+				dnsTTLAnnotation:               "60",
+				revisionHistoryLimitAnnotation: "1",
+				privateKeyAlgorithmAnnotation:  "RSA",
+				privateKeySizeAnnotation:       "2048",
+			},
+		},
+		{
+			name: "same namespace as the owner",
+			annotations: map[string]string{
+				dnsNamespaceAnnotation:    "default",
+				issuerNamespaceAnnotation: "default",
+			},
+		},
+		{
+			name: "disallowed namespaces",
+			annotations: map[string]string{
+				dnsNamespaceAnnotation:    "dns",
+				issuerNamespaceAnnotation: "issuer",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/dns-namespace: namespace "dns" is not allowed for DNSEndpoint`,
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/issuer-namespace: namespace "issuer" is not allowed for Certificate`,
+			},
+		},
+		{
+			name: "custom delegations not allowed",
+			annotations: map[string]string{
+				delegatedDomainAnnotation: testDelegationName,
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/delegated-domain: custom delegated domains are not allowed`,
+			},
+		},
+		{
+			name: "delegated domain not whitelisted",
+			opts: ReconcilerOptions{
+				AllowCustomDelegations:  true,
+				AllowedDelegatedDomains: []string{"other.example.com"},
+			},
+			annotations: map[string]string{
+				delegatedDomainAnnotation: testDelegationName,
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/delegated-domain: delegated domain "acme.example.com" is not in the allowed list`,
+			},
+		},
+		{
+			name: "invalid values",
+			annotations: map[string]string{
+				revisionHistoryLimitAnnotation: "-1",
+				privateKeyAlgorithmAnnotation:  "RSA",
+				privateKeySizeAnnotation:       "large",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation cert-manager.io/revision-history-limit: invalid value "-1", Certificate is not reconciled`,
+				`Warning IgnoredAnnotation cert-manager.io/private-key-size: invalid value "large"`,
+			},
+		},
+		{
+			name: "invalid durations",
+			annotations: map[string]string{
+				durationAnnotation:    "90d",
+				renewBeforeAnnotation: "0s",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation cert-manager.io/duration: invalid value "90d", Certificate is not reconciled: time: unknown unit "d" in duration "90d"`,
+				`Warning IgnoredAnnotation cert-manager.io/renew-before: invalid value "0s", Certificate is not reconciled: duration must be greater than 0: 0s`,
+			},
+		},
+		{
+			name: "disallowed additional DNS names",
+			opts: ReconcilerOptions{
+				AllowedAdditionalDNSDomains: []string{"example.com"},
+			},
+			annotations: map[string]string{
+				additionalDNSNamesAnnotation: "alias.example.com,alias.example.org",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/additional-dns-names: DNS name "alias.example.org" is not under the allowed domains`,
+			},
+		},
+		{
+			name: "disallowed target services",
+			opts: ReconcilerOptions{
+				AllowedTargetServices: []client.ObjectKey{{Namespace: "ingress", Name: "envoy-internal"}},
+			},
+			annotations: map[string]string{
+				targetServiceAnnotation: "ingress/envoy-other",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/target-service: target Service "ingress/envoy-other" is not allowed, DNSEndpoint is not updated`,
+			},
+		},
+		{
+			name: "disallowed DNS targets",
+			annotations: map[string]string{
+				dnsTargetsAnnotation: "192.0.2.1",
+			},
+			expect: []string{
+				`Warning IgnoredAnnotation contour-plus.cybozu.com/dns-targets: IP address "192.0.2.1" is not in the allowed CIDRs, DNSEndpoint is not updated`,
+			},
+		},
+		{
+			name: "private key size without algorithm",
+			annotations: map[string]string{
+				privateKeySizeAnnotation: "large",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := events.NewFakeRecorder(10)
+			r := &HTTPProxyReconciler{ReconcilerOptions: tt.opts, Recorder: recorder}
+			hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+			for k, v := range tt.annotations {
+				hp.Annotations[k] = v
+			}
+			if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+				t.Fatal(err)
+			}
+			close(recorder.Events)
+
+			var got []string
+			for e := range recorder.Events {
+				got = append(got, e)
+			}
+			if !slices.Equal(got, tt.expect) {
+				t.Errorf("recordIgnoredAnnotations() recorded %q, want %q", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRecordIgnoredAnnotationsOnChange(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+	r := &HTTPProxyReconciler{Recorder: recorder}
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[dnsTTLAnnotation] = "0"
+	hp.Annotations[revisionHistoryLimitAnnotation] = "-1"
+
+	// the same warnings are recorded only once
+	if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+		t.Fatal(err)
+	}
+	// only the new warning is recorded when the annotations change
+	hp.Annotations[dnsTTLAnnotation] = "-1"
+	if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+		t.Fatal(err)
+	}
+	// the warnings are recorded again after they are fixed once
+	delete(hp.Annotations, revisionHistoryLimitAnnotation)
+	if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+		t.Fatal(err)
+	}
+	hp.Annotations[revisionHistoryLimitAnnotation] = "-1"
+	if err := r.recordIgnoredAnnotations(context.Background(), hp); err != nil {
+		t.Fatal(err)
+	}
+	close(recorder.Events)
+
+	var got []string
+	for e := range recorder.Events {
+		got = append(got, e)
+	}
+	expect := []string{
+		`Warning IgnoredAnnotation contour-plus.cybozu.com/dns-ttl: invalid value "0": `,
+		`Warning IgnoredAnnotation cert-manager.io/revision-history-limit: invalid value "-1", Certificate is not reconciled`,
+		`Warning IgnoredAnnotation contour-plus.cybozu.com/dns-ttl: invalid value "-1": `,
+		`Warning IgnoredAnnotation cert-manager.io/revision-history-limit: invalid value "-1", Certificate is not reconciled`,
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("recordIgnoredAnnotations() recorded %q, want %q", got, expect)
+	}
+	for i := range expect {
+		if !strings.HasPrefix(got[i], expect[i]) {
+			t.Errorf("recordIgnoredAnnotations() recorded %q, want %q", got[i], expect[i])
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// CollectGarbage deletes the child resources whose owners do not exist, and returns the descriptions of the deleted ones.
+// c should read from the API server directly because the field indexes of the informer cache are not used.
+// If opts.DryRun is true, the deletions are made in dry-run mode only.
+func CollectGarbage(ctx context.Context, c client.Client, opts ReconcilerOptions, scheme *runtime.Scheme, log logr.Logger) ([]string, error) {
+	if opts.DryRun {
+		c = newDryRunClient(c)
+	}
+	r := &HTTPProxyReconciler{
+		Client:            c,
+		ReconcilerOptions: opts,
+		Log:               log,
+		Scheme:            scheme,
+		ChildReader:       c,
+		APIReader:         c,
+	}
+
+	deleted, err := r.collectGarbage(ctx, c, log)
+	descs := make([]string, 0, len(deleted))
+	for _, key := range deleted {
+		descs = append(descs, describeChild(key))
+	}
+	return descs, err
+}
+
+// listOrphans returns the keys of children whose owners do not exist, in the order of kind, namespace and name.
+// The children of unwatched ingress classes, and those of HTTPRoutes unless WatchHTTPRoute is true, are not included.
+func (r *HTTPProxyReconciler) listOrphans(ctx context.Context, children map[childKey]client.Object) ([]childKey, error) {
+	owners := make(map[string]bool)
+	var hpList projectcontourv1.HTTPProxyList
+	if err := r.List(ctx, &hpList); err != nil {
+		return nil, err
+	}
+	for i := range hpList.Items {
+		owners[getOwnerKey(&hpList.Items[i])] = true
+	}
+	if r.WatchHTTPRoute {
+		var routeList gatewayv1.HTTPRouteList
+		if err := r.List(ctx, &routeList); err != nil {
+			return nil, err
+		}
+		for i := range routeList.Items {
+			owners[getOwnerKey(&routeList.Items[i])] = true
+		}
+	}
+
+	var orphans []childKey
+	for _, key := range sortedChildKeys(children) {
+		obj := children[key]
+		if !r.watchesAllIngressClasses() && !r.isWatchedIngressClass(obj.GetAnnotations()[ingressClassOwnerAnnotation]) {
+			continue
+		}
+		ownerKey := indexOwner(obj)[0]
+		if owners[ownerKey] {
+			continue
+		}
+		if !r.WatchHTTPRoute && strings.HasPrefix(ownerKey, HTTPRouteKind+"/") {
+			continue
+		}
+		orphans = append(orphans, key)
+	}
+	return orphans, nil
+}
+
+// collectGarbage deletes the child resources whose owners do not exist, and returns the keys of the deleted ones.
+// Only the children in the namespace of their owners or in the namespaces allowed for the kind are deleted.
+// The absence of the owners is confirmed by apiReader because the informer cache may be stale.
+func (r *HTTPProxyReconciler) collectGarbage(ctx context.Context, apiReader client.Reader, log logr.Logger) ([]childKey, error) {
+	ctx = withAllowedNamespaces(ctx)
+	children, err := r.listChildren(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orphans, err := r.listOrphans(ctx, children)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []childKey
+	for _, key := range orphans {
+		obj := children[key]
+		ownerKey := indexOwner(obj)[0]
+		log := log.WithValues("kind", key.Kind, "namespace", key.Namespace, "name", key.Name, "owner", ownerKey)
+
+		owner, err := ownerFromKey(ownerKey)
+		if err != nil {
+			log.Error(err, "invalid owner")
+			continue
+		}
+		collectable, err := r.isCollectableNamespace(ctx, key, owner.GetNamespace())
+		if err != nil {
+			return deleted, err
+		}
+		if !collectable {
+			log.Info("orphan is kept because its namespace is not allowed")
+			continue
+		}
+		err = apiReader.Get(ctx, client.ObjectKeyFromObject(owner), owner)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return deleted, err
+		}
+
+		// the precondition prevents deleting the resource re-created after it was listed
+		err = r.Delete(ctx, obj, client.Preconditions{UID: ptr.To(obj.GetUID())})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		log.Info("deleted orphan")
+		garbageCollectedTotal.WithLabelValues(key.Kind).Inc()
+		deleted = append(deleted, key)
+	}
+	return deleted, nil
+}
+
+// isCollectableNamespace returns true if contour-plus can generate the child of key for an owner in ownerNamespace.
+func (r *HTTPProxyReconciler) isCollectableNamespace(ctx context.Context, key childKey, ownerNamespace string) (bool, error) {
+	if key.Namespace == ownerNamespace {
+		return true, nil
+	}
+	if key.Kind == DNSEndpointKind {
+		return r.isAllowedDNSNamespace(ctx, key.Namespace)
+	}
+	return r.isAllowedIssuerNamespace(ctx, key.Namespace)
+}
+
+// garbageCollector runs collectGarbage periodically.
+type garbageCollector struct {
+	r         *HTTPProxyReconciler
+	apiReader client.Reader
+	interval  time.Duration
+}
+
+// Start implements manager.Runnable.
+func (gc *garbageCollector) Start(ctx context.Context) error {
+	log := gc.r.Log.WithName("gc")
+	ticker := time.NewTicker(gc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if _, err := gc.r.collectGarbage(ctx, gc.apiReader, log); err != nil {
+			log.Error(err, "failed to collect garbage")
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (gc *garbageCollector) NeedLeaderElection() bool {
+	return true
+}
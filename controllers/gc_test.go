@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	foo := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			foo,
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "foo"}, "default/foo", dnsName),
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "gone"}, "default/gone", dnsName),
+			newDummyCertificate(client.ObjectKey{Namespace: "certs", Name: "default-gone"}, "default/gone", dnsName),
+			newDummyCertificate(client.ObjectKey{Namespace: "other", Name: "default-gone"}, "default/gone", dnsName),
+			newDummyCertificate(client.ObjectKey{Namespace: "default", Name: "route"}, HTTPRouteKind+"/default/gone", dnsName),
+		).
+		Build()
+	opts := ReconcilerOptions{
+		AllowedIssuerNamespaces: []string{"certs"},
+		CreateCertificate:       true,
+	}
+	expect := []string{"Certificate certs/default-gone", "Certificate default/gone"}
+
+	// nothing is deleted in dry-run mode
+	opts.DryRun = true
+	deleted, err := CollectGarbage(context.Background(), c, opts, scheme, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(deleted, expect) {
+		t.Errorf("CollectGarbage() in dry-run mode = %v, want %v", deleted, expect)
+	}
+	var certList cmv1.CertificateList
+	if err := c.List(context.Background(), &certList); err != nil {
+		t.Fatal(err)
+	}
+	if len(certList.Items) != 5 {
+		t.Errorf("%d Certificates are left in dry-run mode, want 5", len(certList.Items))
+	}
+
+	opts.DryRun = false
+	deleted, err = CollectGarbage(context.Background(), c, opts, scheme, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(deleted, expect) {
+		t.Errorf("CollectGarbage() = %v, want %v", deleted, expect)
+	}
+	for _, key := range []client.ObjectKey{{Namespace: "default", Name: "gone"}, {Namespace: "certs", Name: "default-gone"}} {
+		if err := c.Get(context.Background(), key, &cmv1.Certificate{}); !apierrors.IsNotFound(err) {
+			t.Errorf("Certificate %s is not deleted: %v", key, err)
+		}
+	}
+	// the Certificate of the existing HTTPProxy, the one in the disallowed namespace,
+	// and the one of the unwatched HTTPRoute are kept
+	for _, key := range []client.ObjectKey{{Namespace: "default", Name: "foo"}, {Namespace: "other", Name: "default-gone"}, {Namespace: "default", Name: "route"}} {
+		if err := c.Get(context.Background(), key, &cmv1.Certificate{}); err != nil {
+			t.Errorf("Certificate %s is deleted: %v", key, err)
+		}
+	}
+
+	deleted, err = CollectGarbage(context.Background(), c, opts, scheme, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("CollectGarbage() after collection = %v, want none", deleted)
+	}
+}
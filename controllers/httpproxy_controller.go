@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
@@ -14,9 +16,13 @@ import (
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -29,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 const (
@@ -39,12 +46,23 @@ const (
 	revisionHistoryLimitAnnotation    = "cert-manager.io/revision-history-limit"
 	privateKeyAlgorithmAnnotation     = "cert-manager.io/private-key-algorithm"
 	privateKeySizeAnnotation          = "cert-manager.io/private-key-size"
+	durationAnnotation                = "cert-manager.io/duration"
+	renewBeforeAnnotation             = "cert-manager.io/renew-before"
 	ingressClassNameAnnotation        = "kubernetes.io/ingress.class"
 	contourIngressClassNameAnnotation = "projectcontour.io/ingress.class"
 	delegatedDomainAnnotation         = "contour-plus.cybozu.com/delegated-domain"
 	dnsNamespaceAnnotation            = "contour-plus.cybozu.com/dns-namespace"
 	issuerNamespaceAnnotation         = "contour-plus.cybozu.com/issuer-namespace"
+	dnsTTLAnnotation                  = "contour-plus.cybozu.com/dns-ttl"
+	additionalDNSNamesAnnotation      = "contour-plus.cybozu.com/additional-dns-names"
+	additionalDNSRecordsAnnotation    = "contour-plus.cybozu.com/additional-dns-records"
+	targetServiceAnnotation           = "contour-plus.cybozu.com/target-service"
+	dnsTargetsAnnotation              = "contour-plus.cybozu.com/dns-targets"
+	criticalAnnotation                = "contour-plus.cybozu.com/critical"
+	statusAnnotation                  = "contour-plus.cybozu.com/status"
 	ownerAnnotation                   = "contour-plus.cybozu.com/owned-by"
+	ingressClassOwnerAnnotation       = "contour-plus.cybozu.com/ingress-class-name"
+	adoptedSecretAnnotation           = "contour-plus.cybozu.com/adopted-secret"
 	finalizerName                     = "contour-plus.cybozu.com/finalizer"
 )
 
@@ -54,8 +72,19 @@ type HTTPProxyReconciler struct {
 	ReconcilerOptions
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// ChildReader reads child resources from the informer cache to look them up by owner.
+	ChildReader client.Reader
+	// APIReader reads the resources that are not cached, i.e. the Secrets handed over by the migration of the name prefix.
+	APIReader client.Reader
+	// Recorder records events on HTTPProxy and HTTPRoute. Events are not recorded if nil.
+	Recorder events.EventRecorder
 
 	CertApplier Applier[*cmv1.Certificate]
+	// DNSApplier applies DNSEndpoints. DNSEndpoints are applied directly if nil.
+	DNSApplier Applier[*unstructured.Unstructured]
+
+	// warnings remembers the warning events recorded on the owners so that they are recorded only when the state changes
+	warnings warningMemo
 }
 
 // +kubebuilder:rbac:groups=projectcontour.io,resources=httpproxies,verbs=get;list;watch;update;patch
@@ -65,10 +94,12 @@ type HTTPProxyReconciler struct {
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services/status,verbs=get
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 
 // Reconcile creates/updates CRDs from given HTTPProxy
-func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, retErr error) {
 	log := crlog.FromContext(ctx)
+	ctx = withAllowedNamespaces(ctx)
 
 	// Get HTTPProxy
 	hp := new(projectcontourv1.HTTPProxy)
@@ -76,8 +107,24 @@ func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		Namespace: req.Namespace,
 		Name:      req.Name,
 	}
+
+	// conflictingFQDN is set when the FQDN of the HTTPProxy is claimed by another HTTPProxy
+	var conflictingFQDN string
+	if r.detectsFQDNConflicts() {
+		defer func() { setFQDNConflictMetric(objKey, conflictingFQDN) }()
+	}
+	// cert is set when the HTTPProxy has a Certificate
+	var cert *cmv1.Certificate
+	if r.CreateCertificate {
+		defer func() { setCertificateStatusMetrics(objKey, cert) }()
+	}
+
 	err := r.Get(ctx, objKey, hp)
 	if k8serrors.IsNotFound(err) {
+		// the children still queued for the deleted HTTPProxy must not be created
+		hp.Namespace, hp.Name = objKey.Namespace, objKey.Name
+		r.forgetQueuedResources(hp, nil, log)
+		r.warnings.forget(getOwnerKey(hp))
 		return ctrl.Result{}, nil
 	}
 	if err != nil {
@@ -93,130 +140,295 @@ func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, r.cleanupCrossNamespaceResources(ctx, hp, log)
 	}
 
+	// status is filled during the reconciliation and reported by statusAnnotation.
+	// HTTPProxies of other ingress classes are left to the other instances of contour-plus.
+	ctx, status := withOwnerStatus(ctx)
+	if r.watchesAllIngressClasses() || r.isClassNameMatched(hp) {
+		defer func() { r.applyStatusAnnotation(ctx, hp, status, retErr, log) }()
+	}
+
 	if hp.Annotations[excludeAnnotation] == "true" {
-		return ctrl.Result{}, nil
+		recordSkip(ctx, hp, skipReasonExcluded)
+		return ctrl.Result{}, r.cleanupAllResources(ctx, hp, log)
 	}
 
-	if r.IngressClassName != "" {
+	if !r.watchesAllIngressClasses() {
 		if !r.isClassNameMatched(hp) {
-			return ctrl.Result{}, nil
+			recordSkip(ctx, hp, skipReasonClassMismatch)
+			return ctrl.Result{}, r.cleanupAllResources(ctx, hp, log)
 		}
 	}
 
-	if err := r.reconcileDNSEndpoint(ctx, hp, log); err != nil {
+	winner, fqdn, err := r.findFQDNConflict(ctx, hp)
+	if err != nil {
+		log.Error(err, "unable to check FQDN conflicts")
+		return ctrl.Result{}, err
+	}
+	if winner != nil {
+		conflictingFQDN = fqdn
+		recordSkip(ctx, hp, skipReasonFQDNConflict)
+		log.Info("FQDN is claimed by another HTTPProxy", "fqdn", conflictingFQDN, "winner", client.ObjectKeyFromObject(winner))
+		r.recordEvent(hp, winner, corev1.EventTypeWarning, eventReasonFQDNConflict, eventActionReconcile,
+			"FQDN %q is claimed by HTTPProxy %s/%s", conflictingFQDN, winner.Namespace, winner.Name)
+		return ctrl.Result{}, r.cleanupAllResources(ctx, hp, log)
+	}
+
+	if err := r.recordIgnoredAnnotations(ctx, hp); err != nil {
+		log.Error(err, "unable to check annotations")
+		return ctrl.Result{}, err
+	}
+	hostnames := proxyHostnames(hp)
+	// the secretName is computed before reconcileSecretName rewrites it in hp
+	secretName := getCertificateSecretName(r, hp)
+	dnsHostnames, certHostnames := r.expandHostnames(hp, hostnames, log)
+
+	rules, err := r.getDomainPolicyRules(ctx, hp.Namespace)
+	if err != nil {
+		log.Error(err, "unable to get DomainPolicy")
+		return ctrl.Result{}, err
+	}
+	dnsHostnames, certHostnames = r.filterPermittedHostnames(hp, rules, dnsHostnames, certHostnames, log)
+
+	if err := r.reconcileDNSEndpoint(ctx, hp, dnsHostnames, log); err != nil {
 		log.Error(err, "unable to reconcile DNSEndpoint")
+		r.recordEvent(hp, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile DNSEndpoint: %v", err)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileDelegationDNSEndpoint(ctx, hp, log); err != nil {
+	if err := r.reconcileDelegationDNSEndpoint(ctx, hp, certHostnames, rules, log); err != nil {
 		log.Error(err, "unable to reconcile delegation DNSEndpoint")
+		r.recordEvent(hp, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile delegation DNSEndpoint: %v", err)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileCertificate(ctx, hp, log); err != nil {
+	if err := r.reconcileCertificate(ctx, hp, certHostnames, secretName, rules, log); err != nil {
 		log.Error(err, "unable to reconcile Certificate")
+		r.recordEvent(hp, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile Certificate: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	cert, err = r.reportCertificateStatus(ctx, hp, log)
+	if err != nil {
+		log.Error(err, "unable to get Certificate status")
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileTLSCertificateDelegation(ctx, hp, log); err != nil {
+	// the Secret adopted from the Certificate named with the previous prefix is delegated instead, if any
+	delegatedSecretName := getCertificateName(r, hp)
+	adoptedSecretName, err := r.getAdoptedSecretName(ctx, hp, secretName)
+	if err != nil {
+		log.Error(err, "unable to get adopted Secret")
+		return ctrl.Result{}, err
+	}
+	if adoptedSecretName != "" {
+		delegatedSecretName = adoptedSecretName
+	}
+
+	if err := r.reconcileTLSCertificateDelegation(ctx, hp, delegatedSecretName, log); err != nil {
 		log.Error(err, "unable to reconcile TLSCertificateDelegation")
+		r.recordEvent(hp, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile TLSCertificateDelegation: %v", err)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileSecretName(ctx, hp, log); err != nil {
+	if err := r.reconcileSecretName(ctx, hp, delegatedSecretName, log); err != nil {
 		log.Error(err, "unable to reconcile HTTPProxy SecretName")
+		r.recordEvent(hp, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile HTTPProxy SecretName: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	desired, err := r.desiredChildren(ctx, hp, hostnames, secretName)
+	if err != nil {
+		log.Error(err, "unable to get desired child resources")
+		return ctrl.Result{}, err
+	}
+	status.setChildren(desired)
+	desired, migrating, err := r.keepPreviousChildren(ctx, desired)
+	if err != nil {
+		log.Error(err, "unable to check the migration of name prefix")
 		return ctrl.Result{}, err
 	}
+	if err := r.cleanupUnusedResources(ctx, hp, desired, log); err != nil {
+		log.Error(err, "unable to clean up unused resources")
+		return ctrl.Result{}, err
+	}
+	if migrating {
+		return ctrl.Result{RequeueAfter: migrationRequeueInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 func (r *HTTPProxyReconciler) isClassNameMatched(hp *projectcontourv1.HTTPProxy) bool {
-	ingressClassName := hp.Annotations[ingressClassNameAnnotation]
-	if ingressClassName != "" {
-		if ingressClassName != r.IngressClassName {
-			return false
+	return r.matchIngressClassName(hp.Annotations, hp.Spec.IngressClassName)
+}
+
+// matchIngressClassName checks the ingress class annotations and the class name in the spec, if any,
+// against the watched ingress class names.
+func (r *HTTPProxyReconciler) matchIngressClassName(annotations map[string]string, specIngressClassName string) bool {
+	_, ok := r.getIngressClassName(annotations, specIngressClassName)
+	return ok
+}
+
+// getIngressClassName returns the ingress class name specified by the ingress class annotations and the class name
+// in the spec, if any. It returns false if they are not specified, they differ from each other, or the class is not watched.
+func (r *HTTPProxyReconciler) getIngressClassName(annotations map[string]string, specIngressClassName string) (string, bool) {
+	var className string
+	for _, name := range []string{annotations[ingressClassNameAnnotation], annotations[contourIngressClassNameAnnotation], specIngressClassName} {
+		if name == "" {
+			continue
+		}
+		if className != "" && name != className {
+			return "", false
 		}
+		className = name
 	}
 
-	contourIngressClassName := hp.Annotations[contourIngressClassNameAnnotation]
-	if contourIngressClassName != "" {
-		if contourIngressClassName != r.IngressClassName {
-			return false
-		}
+	if className == "" || !r.isWatchedIngressClass(className) {
+		return "", false
 	}
+	return className, true
+}
 
-	specIngressClassName := hp.Spec.IngressClassName
-	if specIngressClassName != "" {
-		if specIngressClassName != r.IngressClassName {
-			return false
-		}
+// getOwnerIngressClassName returns the watched ingress class name of the owner, or an empty string if none.
+func (r *HTTPProxyReconciler) getOwnerIngressClassName(owner client.Object) string {
+	var specIngressClassName string
+	if hp, ok := owner.(*projectcontourv1.HTTPProxy); ok {
+		specIngressClassName = hp.Spec.IngressClassName
 	}
+	className, _ := r.getIngressClassName(owner.GetAnnotations(), specIngressClassName)
+	return className
+}
 
-	if contourIngressClassName == "" && ingressClassName == "" && specIngressClassName == "" {
+// watchesAllIngressClasses returns true if no ingress class names are specified.
+func (r *HTTPProxyReconciler) watchesAllIngressClasses() bool {
+	return r.IngressClassName == "" && len(r.IngressClassServiceKeys) == 0
+}
+
+// isWatchedIngressClass returns true if className is one of IngressClassName and the keys of IngressClassServiceKeys.
+func (r *HTTPProxyReconciler) isWatchedIngressClass(className string) bool {
+	if className == "" {
 		return false
 	}
+	if className == r.IngressClassName {
+		return true
+	}
+	_, ok := r.IngressClassServiceKeys[className]
+	return ok
+}
 
-	return true
+// getServiceKey returns the key of the Service whose load balancer addresses are used for the owner.
+// The Service specified by targetServiceAnnotation takes precedence over the Envoy Service of the ingress class.
+// It returns an error if the annotation is invalid or not allowed instead of falling back to the Envoy Service.
+func (r *HTTPProxyReconciler) getServiceKey(owner client.Object) (client.ObjectKey, error) {
+	key, err := r.getTargetServiceKey(owner)
+	if err != nil {
+		return client.ObjectKey{}, err
+	}
+	if key != nil {
+		return *key, nil
+	}
+	if key, ok := r.IngressClassServiceKeys[r.getOwnerIngressClassName(owner)]; ok {
+		return key, nil
+	}
+	return r.ServiceKey, nil
 }
 
-func (r *HTTPProxyReconciler) reconcileDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
-	if !r.CreateDNSEndpoint {
-		return nil
+// getTargetServiceKey returns the key of the Service specified by targetServiceAnnotation.
+// It returns nil if the annotation is not specified, or an error if the value is invalid or not allowed.
+func (r *HTTPProxyReconciler) getTargetServiceKey(owner client.Object) (*client.ObjectKey, error) {
+	value, ok := owner.GetAnnotations()[targetServiceAnnotation]
+	if !ok {
+		return nil, nil
 	}
+	ns, name, err := cache.SplitMetaNamespaceKey(value)
+	if err != nil {
+		return nil, err
+	}
+	if ns == "" || name == "" {
+		return nil, fmt.Errorf("target Service should be in the form of <namespace>/<name>: %q", value)
+	}
+	key := client.ObjectKey{Namespace: ns, Name: name}
+	if !slices.Contains(r.AllowedTargetServices, key) {
+		return nil, fmt.Errorf("target Service %q is not allowed", value)
+	}
+	return &key, nil
+}
 
-	if hp.Spec.VirtualHost == nil {
+// isWatchedService returns true if obj is one of the Envoy Services or the allowed target Services.
+func (r *HTTPProxyReconciler) isWatchedService(obj client.Object) bool {
+	key := client.ObjectKeyFromObject(obj)
+	if key == r.ServiceKey {
+		return true
+	}
+	for _, serviceKey := range r.IngressClassServiceKeys {
+		if key == serviceKey {
+			return true
+		}
+	}
+	return slices.Contains(r.AllowedTargetServices, key)
+}
+
+// reconcileDNSEndpoint creates/updates a DNSEndpoint that has A/AAAA records of the given hostnames.
+// The owner is either HTTPProxy or HTTPRoute.
+func (r *HTTPProxyReconciler) reconcileDNSEndpoint(ctx context.Context, owner client.Object, hostnames []string, log logr.Logger) error {
+	if !r.CreateDNSEndpoint {
 		return nil
 	}
-	fqdn := hp.Spec.VirtualHost.Fqdn
-	if len(fqdn) == 0 {
+
+	if len(hostnames) == 0 {
 		return nil
 	}
 
-	// Get IP list of loadbalancer Service
-	var serviceIPs []net.IP
-	var svc corev1.Service
-	err := r.Get(ctx, r.ServiceKey, &svc)
+	// The targets specified by annotation replace the addresses of the load balancer.
+	// If the annotation is invalid, the existing DNSEndpoint is kept as it is instead of pointing to the load balancer;
+	// the warning event is recorded by recordIgnoredAnnotations.
+	serviceIPs, serviceHostnames, err := r.getDNSTargets(owner)
 	if err != nil {
-		return err
+		log.Error(err, "invalid DNS targets, DNSEndpoint is not updated", "value", owner.GetAnnotations()[dnsTargetsAnnotation])
+		return nil
 	}
 
-	for _, ing := range svc.Status.LoadBalancer.Ingress {
-		if len(ing.IP) == 0 {
-			continue
+	if len(serviceIPs) == 0 && len(serviceHostnames) == 0 {
+		// Get IP and hostname list of loadbalancer Service.
+		// If the target Service is invalid, the existing DNSEndpoint is kept as it is instead of pointing to
+		// the Envoy Service; the warning event is recorded by recordIgnoredAnnotations.
+		serviceKey, err := r.getServiceKey(owner)
+		if err != nil {
+			log.Error(err, "invalid target Service, DNSEndpoint is not updated", "value", owner.GetAnnotations()[targetServiceAnnotation])
+			return nil
+		}
+		var svc corev1.Service
+		err = r.Get(ctx, serviceKey, &svc)
+		if err != nil {
+			return err
 		}
-		serviceIPs = append(serviceIPs, net.ParseIP(ing.IP))
-	}
-	if len(serviceIPs) == 0 {
-		log.Info("no IP address for service " + r.ServiceKey.String())
-		// we can return nil here because the controller will be notified
-		// as soon as a new IP address is assigned to the service.
-		return nil
-	}
 
-	dnsEndpointName := getDNSEndpointName(r, hp)
-	targetNamespace := hp.Namespace
-	if ns, ok := hp.Annotations[dnsNamespaceAnnotation]; ok && slices.Contains(r.AllowedDNSNamespaces, ns) {
-		targetNamespace = ns
+		for _, ing := range svc.Status.LoadBalancer.Ingress {
+			if len(ing.IP) != 0 {
+				serviceIPs = append(serviceIPs, net.ParseIP(ing.IP))
+				continue
+			}
+			if len(ing.Hostname) != 0 {
+				serviceHostnames = append(serviceHostnames, ing.Hostname)
+			}
+		}
+		if len(serviceIPs) == 0 && len(serviceHostnames) == 0 {
+			log.Info("no IP address or hostname for service " + serviceKey.String())
+			recordSkip(ctx, owner, skipReasonNoLoadBalancerIP)
+			// we can return nil here because the controller will be notified
+			// as soon as a new IP address is assigned to the service.
+			return nil
+		}
 	}
 
-	obj := &unstructured.Unstructured{}
-	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
-	obj.SetName(dnsEndpointName)
-	obj.SetNamespace(targetNamespace)
-	obj.SetAnnotations(r.generateObjectAnnotations(hp))
-	obj.SetLabels(r.generateObjectLabels(hp))
-	obj.UnstructuredContent()["spec"] = map[string]interface{}{
-		"endpoints": makeEndpoints(fqdn, serviceIPs),
+	obj, err := r.buildDNSEndpoint(ctx, owner, hostnames, serviceIPs, serviceHostnames, log)
+	if err != nil {
+		return err
 	}
-	err = r.trackResourceOwnership(hp, obj)
+	err = r.trackResourceOwnership(owner, obj)
 	if err != nil {
 		return err
 	}
-	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
-	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
-		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
-	})
+	err = r.applyDNSEndpoint(ctx, owner, obj)
 	if err != nil {
 		return err
 	}
@@ -225,54 +437,63 @@ func (r *HTTPProxyReconciler) reconcileDNSEndpoint(ctx context.Context, hp *proj
 	return nil
 }
 
-func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
-	if !r.CreateDNSEndpoint {
-		return nil
+// buildDNSEndpoint builds a DNSEndpoint that has the records of the given hostnames pointing to the load balancer
+// at serviceIPs or serviceHostnames. The ownership is not set.
+func (r *HTTPProxyReconciler) buildDNSEndpoint(ctx context.Context, owner client.Object, hostnames []string, serviceIPs []net.IP, serviceHostnames []string, log logr.Logger) (*unstructured.Unstructured, error) {
+	namespace, err := r.getDNSEndpointNamespace(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	ttl := r.getDNSTTL(owner, log)
+	var endpoints []map[string]interface{}
+	for _, hostname := range hostnames {
+		endpoints = append(endpoints, makeEndpoints(hostname, serviceIPs, serviceHostnames, ttl)...)
 	}
 
-	delegatedDomain := r.DefaultDelegatedDomain
-	userDelegatedDomain := hp.Annotations[delegatedDomainAnnotation]
-	if userDelegatedDomain != "" && r.AllowCustomDelegations && slices.Contains(r.AllowedDelegatedDomains, userDelegatedDomain) {
-		delegatedDomain = userDelegatedDomain
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	obj.SetName(getDNSEndpointName(r, owner))
+	obj.SetNamespace(namespace)
+	obj.SetAnnotations(r.generateObjectAnnotations(owner))
+	obj.SetLabels(r.generateObjectLabels(owner))
+	obj.UnstructuredContent()["spec"] = map[string]interface{}{
+		"endpoints": endpoints,
 	}
+	return obj, nil
+}
 
-	if delegatedDomain == "" {
+// reconcileDelegationDNSEndpoint creates/updates a DNSEndpoint that delegates DNS-01 validation
+// of the given hostnames to the delegated domain. rules are the DomainPolicy rules for the namespace of the owner.
+func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context, owner client.Object, hostnames []string, rules *domainPolicyRules, log logr.Logger) error {
+	if !r.CreateDNSEndpoint {
 		return nil
 	}
 
-	if hp.Spec.VirtualHost == nil {
+	delegatedDomain := r.getDelegatedDomain(owner)
+	if delegatedDomain == "" {
 		return nil
 	}
-	fqdn := hp.Spec.VirtualHost.Fqdn
-	if len(fqdn) == 0 {
+
+	if len(hostnames) == 0 {
 		return nil
 	}
 
-	dnsEndpointName := getDNSEndpointName(r, hp)
-	targetNamespace := hp.Namespace
-	if ns, ok := hp.Annotations[dnsNamespaceAnnotation]; ok && slices.Contains(r.AllowedDNSNamespaces, ns) {
-		targetNamespace = ns
+	if !rules.permitsDelegatedDomain(delegatedDomain, hostnames) {
+		log.Info("delegated domain is not permitted by DomainPolicy", "domain", delegatedDomain)
+		r.recordEvent(owner, nil, corev1.EventTypeWarning, eventReasonDeniedByPolicy, eventActionReconcile,
+			"delegated domain %q is not permitted by DomainPolicy", delegatedDomain)
+		return nil
 	}
 
-	obj := &unstructured.Unstructured{}
-	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
-	obj.SetName(dnsEndpointName + "-delegation")
-	obj.SetNamespace(targetNamespace)
-	obj.SetAnnotations(r.generateObjectAnnotations(hp))
-	obj.SetLabels(r.generateObjectLabels(hp))
-	obj.UnstructuredContent()["spec"] = map[string]interface{}{
-		"endpoints": makeDelegationEndpoint(fqdn, delegatedDomain),
+	obj, err := r.buildDelegationDNSEndpoint(ctx, owner, hostnames, delegatedDomain, log)
+	if err != nil {
+		return err
 	}
-
-	if err := r.trackResourceOwnership(hp, obj); err != nil {
+	if err := r.trackResourceOwnership(owner, obj); err != nil {
 		return err
 	}
 
-	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
-	if err := r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
-		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
-	}); err != nil {
+	if err := r.applyDNSEndpoint(ctx, owner, obj); err != nil {
 		return err
 	}
 
@@ -280,46 +501,132 @@ func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context
 	return nil
 }
 
-func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+// buildDelegationDNSEndpoint builds a DNSEndpoint that delegates DNS-01 validation of the given hostnames
+// to delegatedDomain. The ownership is not set.
+func (r *HTTPProxyReconciler) buildDelegationDNSEndpoint(ctx context.Context, owner client.Object, hostnames []string, delegatedDomain string, log logr.Logger) (*unstructured.Unstructured, error) {
+	namespace, err := r.getDNSEndpointNamespace(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	// hostnames such as "example.com" and "*.example.com" share the same challenge record
+	ttl := r.getDNSTTL(owner, log)
+	var endpoints []map[string]interface{}
+	seen := make(map[string]bool)
+	for _, hostname := range hostnames {
+		for _, ep := range makeDelegationEndpoint(hostname, delegatedDomain, ttl) {
+			dnsName := ep["dnsName"].(string)
+			if seen[dnsName] {
+				continue
+			}
+			seen[dnsName] = true
+			endpoints = append(endpoints, ep)
+		}
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	obj.SetName(getDNSEndpointName(r, owner) + "-delegation")
+	obj.SetNamespace(namespace)
+	obj.SetAnnotations(r.generateObjectAnnotations(owner))
+	obj.SetLabels(r.generateObjectLabels(owner))
+	obj.UnstructuredContent()["spec"] = map[string]interface{}{
+		"endpoints": endpoints,
+	}
+	return obj, nil
+}
+
+// reconcileCertificate creates/updates a Certificate for the given hostnames to be stored in secretName.
+// The first hostname is used as the common name. rules are the DomainPolicy rules for the namespace of the owner.
+func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, owner client.Object, hostnames []string, secretName string, rules *domainPolicyRules, log logr.Logger) error {
 	if !r.CreateCertificate {
 		return nil
 	}
-	if hp.Annotations[testACMETLSAnnotation] != "true" {
+	ownerAnnotations := owner.GetAnnotations()
+	if ownerAnnotations[testACMETLSAnnotation] != "true" {
 		return nil
 	}
 
-	vh := hp.Spec.VirtualHost
-	switch {
-	case vh == nil:
-		return nil
-	case vh.Fqdn == "":
+	if len(hostnames) == 0 {
 		return nil
 	}
-	secretName := getCertificateSecretName(r, hp)
 	if secretName == "" {
 		return nil
 	}
 
-	issuerName := r.DefaultIssuerName
-	issuerKind := r.DefaultIssuerKind
-	if name, ok := hp.Annotations[issuerNameAnnotation]; ok {
-		issuerName = name
-		issuerKind = IssuerKind
+	issuerKind, issuerName := r.getIssuer(owner)
+	if issuerName == "" {
+		log.Info("no issuer name")
+		recordSkip(ctx, owner, skipReasonNoIssuer)
+		r.recordEvent(owner, nil, corev1.EventTypeWarning, eventReasonMissingIssuer, eventActionReconcile,
+			"Certificate is not created because no issuer is specified")
+		return nil
 	}
-	if name, ok := hp.Annotations[clusterIssuerNameAnnotation]; ok {
-		issuerName = name
-		issuerKind = ClusterIssuerKind
+
+	if !rules.permitsIssuer(issuerKind, issuerName, hostnames) {
+		log.Info("issuer is not permitted by DomainPolicy", "kind", issuerKind, "name", issuerName)
+		r.recordEvent(owner, nil, corev1.EventTypeWarning, eventReasonDeniedByPolicy, eventActionReconcile,
+			"Certificate is not created because %s %q is not permitted by DomainPolicy", issuerKind, issuerName)
+		return nil
 	}
 
-	if issuerName == "" {
-		log.Info("no issuer name")
+	obj, err := r.desiredCertificate(ctx, owner, hostnames, secretName, issuerKind, issuerName, log)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
 		return nil
 	}
+	err = r.trackResourceOwnership(owner, obj)
+	if err != nil {
+		return err
+	}
+	current := new(cmv1.Certificate)
+	err = r.ChildReader.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	err = r.CertApplier.Apply(ctx, obj)
+	if err != nil {
+		return err
+	}
+	// The Certificate has no resourceVersion if it is queued; CertificateApplyWorker records the event in that case.
+	if obj.GetResourceVersion() != "" {
+		r.recordApplied(owner, CertificateKind, obj, current.GetResourceVersion())
+	}
+	return r.handOverSecret(ctx, owner, obj, log)
+}
 
+// desiredCertificate builds the Certificate of the owner with the Secret adopted from the Certificate
+// named with PreviousPrefix, if any. The ownership is not set.
+// It returns nil if the owner has an invalid annotation for the Certificate.
+func (r *HTTPProxyReconciler) desiredCertificate(ctx context.Context, owner client.Object, hostnames []string, secretName, issuerKind, issuerName string, log logr.Logger) (*cmv1.Certificate, error) {
+	obj, err := r.buildCertificate(ctx, owner, hostnames, secretName, issuerKind, issuerName, log)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	adoptedSecretName, err := r.getAdoptedSecretName(ctx, owner, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if adoptedSecretName != "" {
+		setAdoptedSecret(obj, adoptedSecretName)
+	}
+	return obj, nil
+}
+
+// buildCertificate builds a Certificate for the given hostnames to be stored in secretName and issued by the issuer.
+// The first hostname is used as the common name. The ownership is not set.
+// It returns nil if the owner has an invalid annotation for the Certificate.
+func (r *HTTPProxyReconciler) buildCertificate(ctx context.Context, owner client.Object, hostnames []string, secretName, issuerKind, issuerName string, log logr.Logger) (*cmv1.Certificate, error) {
+	namespace, err := r.getCertificateNamespace(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	ownerAnnotations := owner.GetAnnotations()
 	certificateSpec := cmv1.CertificateSpec{
-		DNSNames:   []string{vh.Fqdn},
+		DNSNames:   hostnames,
 		SecretName: secretName,
-		CommonName: vh.Fqdn,
+		CommonName: hostnames[0],
 		IssuerRef: cmmeta.IssuerReference{
 			Kind: issuerKind,
 			Name: issuerName,
@@ -334,20 +641,42 @@ func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *proj
 	if r.CSRRevisionLimit > 0 {
 		certificateSpec.RevisionHistoryLimit = ptr.To(int32(r.CSRRevisionLimit))
 	}
-	if value, ok := hp.Annotations[revisionHistoryLimitAnnotation]; ok {
+	if value, ok := ownerAnnotations[revisionHistoryLimitAnnotation]; ok {
 		limit, err := strconv.ParseUint(value, 10, 32)
 		if err != nil {
 			log.Error(err, "invalid revisionHistoryLimit", "value", value)
-			return nil
+			return nil, nil
 		}
 		certificateSpec.RevisionHistoryLimit = ptr.To(int32(limit))
 	}
+	if r.DefaultCertificateDuration > 0 {
+		certificateSpec.Duration = &metav1.Duration{Duration: r.DefaultCertificateDuration}
+	}
+	if value, ok := ownerAnnotations[durationAnnotation]; ok {
+		duration, err := parseCertificateDuration(value)
+		if err != nil {
+			log.Error(err, "invalid duration", "value", value)
+			return nil, nil
+		}
+		certificateSpec.Duration = duration
+	}
+	if r.DefaultCertificateRenewBefore > 0 {
+		certificateSpec.RenewBefore = &metav1.Duration{Duration: r.DefaultCertificateRenewBefore}
+	}
+	if value, ok := ownerAnnotations[renewBeforeAnnotation]; ok {
+		renewBefore, err := parseCertificateDuration(value)
+		if err != nil {
+			log.Error(err, "invalid renewBefore", "value", value)
+			return nil, nil
+		}
+		certificateSpec.RenewBefore = renewBefore
+	}
 	secretTemplate := &cmv1.CertificateSecretTemplate{}
-	annotations := r.generateObjectAnnotations(hp)
+	annotations := r.generateObjectAnnotations(owner)
 	if annotations != nil {
 		secretTemplate.Annotations = annotations
 	}
-	labels := r.generateObjectLabels(hp)
+	labels := r.generateObjectLabels(owner)
 	if labels != nil {
 		secretTemplate.Labels = labels
 	}
@@ -355,11 +684,11 @@ func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *proj
 		certificateSpec.SecretTemplate = secretTemplate
 	}
 
-	if algorithm, ok := hp.Annotations[privateKeyAlgorithmAnnotation]; ok {
+	if algorithm, ok := ownerAnnotations[privateKeyAlgorithmAnnotation]; ok {
 		privateKeySpec := &cmv1.CertificatePrivateKey{
 			Algorithm: cmv1.PrivateKeyAlgorithm(algorithm),
 		}
-		if value, ok := hp.Annotations[privateKeySizeAnnotation]; ok {
+		if value, ok := ownerAnnotations[privateKeySizeAnnotation]; ok {
 			size, err := strconv.ParseUint(value, 10, 32)
 			if err == nil {
 				privateKeySpec.Size = int(size)
@@ -370,35 +699,24 @@ func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *proj
 		certificateSpec.PrivateKey = privateKeySpec
 	}
 
-	certificateName := getCertificateName(r, hp)
-	targetNamespace := hp.Namespace
-	if ns, ok := hp.Annotations[issuerNamespaceAnnotation]; ok && slices.Contains(r.AllowedIssuerNamespaces, ns) {
-		targetNamespace = ns
-	}
-
 	obj := &cmv1.Certificate{}
 	obj.SetGroupVersionKind(certManagerGroupVersion.WithKind(CertificateKind))
-	obj.SetName(certificateName)
-	obj.SetNamespace(targetNamespace)
+	obj.SetName(getCertificateName(r, owner))
+	obj.SetNamespace(namespace)
 	obj.Spec = certificateSpec
 
 	obj.SetAnnotations(annotations)
 	obj.SetLabels(labels)
-
-	err := r.trackResourceOwnership(hp, obj)
-	if err != nil {
-		return err
-	}
-	return r.CertApplier.Apply(ctx, obj)
+	return obj, nil
 }
 
-// generateObjectAnnotations creates a map that contains annotations that should be propagated to child resources from HTTPProxy.
+// generateObjectAnnotations creates a map that contains annotations that should be propagated to child resources from HTTPProxy or HTTPRoute.
 // The map can be used to set annotations on unstructured.Unstructured.
 // Returns uninitizalied map (nil) when the map is empty to avoid SSA patching with empty map.
-func (r *HTTPProxyReconciler) generateObjectAnnotations(hp *projectcontourv1.HTTPProxy) map[string]string {
+func (r *HTTPProxyReconciler) generateObjectAnnotations(owner client.Object) map[string]string {
 	annotations := map[string]string{}
 	for _, key := range r.PropagatedAnnotations {
-		if annotation, ok := hp.Annotations[key]; ok {
+		if annotation, ok := owner.GetAnnotations()[key]; ok {
 			annotations[key] = annotation
 		}
 	}
@@ -408,13 +726,13 @@ func (r *HTTPProxyReconciler) generateObjectAnnotations(hp *projectcontourv1.HTT
 	return annotations
 }
 
-// generateObjectLabels creates a map that contains labels that should be propagated to child resources from HTTPProxy.
+// generateObjectLabels creates a map that contains labels that should be propagated to child resources from HTTPProxy or HTTPRoute.
 // The map can be used to set labels on unstructured.Unstructured.
 // Returns uninitizalied map (nil) when the map is empty to avoid SSA patching with empty map.
-func (r *HTTPProxyReconciler) generateObjectLabels(hp *projectcontourv1.HTTPProxy) map[string]string {
+func (r *HTTPProxyReconciler) generateObjectLabels(owner client.Object) map[string]string {
 	labels := map[string]string{}
 	for _, key := range r.PropagatedLabels {
-		if label, ok := hp.Labels[key]; ok {
+		if label, ok := owner.GetLabels()[key]; ok {
 			labels[key] = label
 		}
 	}
@@ -424,16 +742,33 @@ func (r *HTTPProxyReconciler) generateObjectLabels(hp *projectcontourv1.HTTPProx
 	return labels
 }
 
-func (r *HTTPProxyReconciler) reconcileTLSCertificateDelegation(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
-	namespace, ok := hp.Annotations[issuerNamespaceAnnotation]
-	if !ok || !slices.Contains(r.AllowedIssuerNamespaces, namespace) {
-		return nil
+func (r *HTTPProxyReconciler) reconcileTLSCertificateDelegation(ctx context.Context, hp *projectcontourv1.HTTPProxy, secretName string, log logr.Logger) error {
+	namespace, err := r.getDelegationNamespace(ctx, hp)
+	if err != nil || namespace == "" {
+		return err
+	}
+	obj := r.buildTLSCertificateDelegation(hp, namespace, secretName)
+	err = r.trackResourceOwnership(hp, obj)
+	if err != nil {
+		return err
+	}
+	err = r.applyChild(ctx, hp, obj)
+	if err != nil {
+		return err
 	}
+
+	log.Info("TLSCertificateDelegation successfully reconciled")
+	return nil
+}
+
+// buildTLSCertificateDelegation builds a TLSCertificateDelegation in namespace that allows the HTTPProxy
+// to refer to secretName, the Secret of its Certificate. The ownership is not set.
+func (r *HTTPProxyReconciler) buildTLSCertificateDelegation(hp *projectcontourv1.HTTPProxy, namespace, secretName string) *unstructured.Unstructured {
 	certificateName := getCertificateName(r, hp)
 	delegationSpec := map[string]interface{}{
 		"delegations": []map[string]interface{}{
 			{
-				"secretName": certificateName,
+				"secretName": secretName,
 				"targetNamespaces": []string{
 					hp.Namespace,
 				},
@@ -448,68 +783,298 @@ func (r *HTTPProxyReconciler) reconcileTLSCertificateDelegation(ctx context.Cont
 	obj.SetAnnotations(r.generateObjectAnnotations(hp))
 	obj.SetLabels(r.generateObjectLabels(hp))
 	obj.UnstructuredContent()["spec"] = delegationSpec
-	err := r.trackResourceOwnership(hp, obj)
-	if err != nil {
+	return obj
+}
+
+func (r *HTTPProxyReconciler) reconcileSecretName(ctx context.Context, hp *projectcontourv1.HTTPProxy, secretName string, log logr.Logger) error {
+	certNamespace, err := r.getDelegationNamespace(ctx, hp)
+	if err != nil || certNamespace == "" {
 		return err
 	}
-	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
-	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
-		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
-	})
+	if hp.Spec.VirtualHost.TLS == nil {
+		hp.Spec.VirtualHost.TLS = &projectcontourv1.TLS{}
+	}
+	hp.Spec.VirtualHost.TLS.SecretName = certNamespace + "/" + secretName
+
+	err = r.Patch(ctx, hp, client.Merge)
 	if err != nil {
 		return err
 	}
 
-	log.Info("TLSCertificateDelegation successfully reconciled")
+	log.Info("HTTPProxy SecretName successfully reconciled")
 	return nil
 }
 
-func (r *HTTPProxyReconciler) reconcileSecretName(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
-	certNamespace, ok := hp.Annotations[issuerNamespaceAnnotation]
-	if !ok || !slices.Contains(r.AllowedIssuerNamespaces, certNamespace) {
+// getDelegatedDomain returns the domain to which DNS-01 validation is delegated, or an empty string if not delegated.
+func (r *HTTPProxyReconciler) getDelegatedDomain(owner client.Object) string {
+	userDelegatedDomain := owner.GetAnnotations()[delegatedDomainAnnotation]
+	if userDelegatedDomain != "" && r.AllowCustomDelegations && slices.Contains(r.AllowedDelegatedDomains, userDelegatedDomain) {
+		return userDelegatedDomain
+	}
+	return r.DefaultDelegatedDomain
+}
+
+// getIssuer returns the kind and the name of the issuer for the Certificate of the owner.
+// The cluster-issuer annotation takes precedence over the issuer annotation, and both take precedence over the defaults.
+func (r *HTTPProxyReconciler) getIssuer(owner client.Object) (string, string) {
+	annotations := owner.GetAnnotations()
+	if name, ok := annotations[clusterIssuerNameAnnotation]; ok {
+		return ClusterIssuerKind, name
+	}
+	if name, ok := annotations[issuerNameAnnotation]; ok {
+		return IssuerKind, name
+	}
+	return r.DefaultIssuerKind, r.DefaultIssuerName
+}
+
+// getDNSEndpointNamespace returns the namespace in which DNSEndpoints for the owner are placed.
+func (r *HTTPProxyReconciler) getDNSEndpointNamespace(ctx context.Context, owner client.Object) (string, error) {
+	ns, ok := owner.GetAnnotations()[dnsNamespaceAnnotation]
+	if !ok {
+		return owner.GetNamespace(), nil
+	}
+	allowed, err := r.isAllowedDNSNamespace(ctx, ns)
+	if err != nil || !allowed {
+		return owner.GetNamespace(), err
+	}
+	return ns, nil
+}
+
+// getCertificateNamespace returns the namespace in which the Certificate for the owner is placed.
+func (r *HTTPProxyReconciler) getCertificateNamespace(ctx context.Context, owner client.Object) (string, error) {
+	ns, ok := owner.GetAnnotations()[issuerNamespaceAnnotation]
+	if !ok {
+		return owner.GetNamespace(), nil
+	}
+	allowed, err := r.isAllowedIssuerNamespace(ctx, ns)
+	if err != nil || !allowed {
+		return owner.GetNamespace(), err
+	}
+	return ns, nil
+}
+
+// getDelegationNamespace returns the namespace of the TLSCertificateDelegation for hp,
+// or an empty string if hp does not specify an allowed issuer namespace.
+func (r *HTTPProxyReconciler) getDelegationNamespace(ctx context.Context, hp *projectcontourv1.HTTPProxy) (string, error) {
+	ns, ok := hp.Annotations[issuerNamespaceAnnotation]
+	if !ok {
+		return "", nil
+	}
+	allowed, err := r.isAllowedIssuerNamespace(ctx, ns)
+	if err != nil || !allowed {
+		return "", err
+	}
+	return ns, nil
+}
+
+// expandHostnames returns the hostnames for the DNSEndpoint and for the Certificate.
+// The hostnames for the Certificate include the additional DNS names specified by the annotation.
+// The hostnames for the DNSEndpoint include them only if the owner requests DNS records for them.
+// Additional DNS names are ignored if hostnames is empty.
+func (r *HTTPProxyReconciler) expandHostnames(owner client.Object, hostnames []string, log logr.Logger) (dnsHostnames, certHostnames []string) {
+	if len(hostnames) == 0 {
+		return hostnames, hostnames
+	}
+
+	certHostnames = slices.Clone(hostnames)
+	for _, name := range r.getAdditionalDNSNames(owner, log) {
+		if !slices.Contains(certHostnames, name) {
+			certHostnames = append(certHostnames, name)
+		}
+	}
+
+	if owner.GetAnnotations()[additionalDNSRecordsAnnotation] == "true" {
+		return certHostnames, certHostnames
+	}
+	return hostnames, certHostnames
+}
+
+// getAdditionalDNSNames returns the additional DNS names specified by the annotation.
+// Invalid or disallowed names are ignored.
+func (r *HTTPProxyReconciler) getAdditionalDNSNames(owner client.Object, log logr.Logger) []string {
+	value, ok := owner.GetAnnotations()[additionalDNSNamesAnnotation]
+	if !ok {
 		return nil
 	}
-	certificateName := getCertificateName(r, hp)
-	if hp.Spec.VirtualHost.TLS == nil {
-		hp.Spec.VirtualHost.TLS = &projectcontourv1.TLS{}
+	var names []string
+	for _, name := range splitCommaSeparated(value) {
+		if err := r.validateAdditionalDNSName(name); err != nil {
+			log.Error(err, "ignored additional DNS name", "name", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// validateAdditionalDNSName checks that name is a valid DNS name, optionally prefixed with "*.",
+// and that it belongs to one of AllowedAdditionalDNSDomains.
+func (r *HTTPProxyReconciler) validateAdditionalDNSName(name string) error {
+	if strings.HasPrefix(name, "*.") {
+		if errs := validation.IsWildcardDNS1123Subdomain(name); len(errs) != 0 {
+			return fmt.Errorf("invalid DNS name %q: %s", name, strings.Join(errs, ", "))
+		}
+	} else {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			return fmt.Errorf("invalid DNS name %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+
+	if !isUnderDomains(strings.TrimPrefix(name, "*."), r.AllowedAdditionalDNSDomains) {
+		return fmt.Errorf("DNS name %q is not under the allowed domains", name)
+	}
+	return nil
+}
+
+// isUnderDomains returns true if name is equal to or under one of domains.
+func isUnderDomains(name string, domains []string) bool {
+	for _, domain := range domains {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommaSeparated splits a comma-separated annotation value, dropping empty items.
+func splitCommaSeparated(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// parseCertificateDuration parses the value of durationAnnotation or renewBeforeAnnotation
+// in the same format as cert-manager's ingress-shim, e.g. "2160h".
+func parseCertificateDuration(value string) (*metav1.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("duration must be greater than 0: %s", value)
+	}
+	return &metav1.Duration{Duration: d}, nil
+}
+
+// getDNSTTL returns the TTL of DNS records in seconds for the owner.
+// An invalid annotation value is ignored and the default TTL is used instead.
+func (r *HTTPProxyReconciler) getDNSTTL(owner client.Object, log logr.Logger) int64 {
+	ttl := r.DefaultDNSTTL
+	if ttl == 0 {
+		ttl = DefaultDNSTTL
+	}
+	if value, ok := owner.GetAnnotations()[dnsTTLAnnotation]; ok {
+		annotationTTL, err := parseDNSTTL(value)
+		if err != nil {
+			log.Error(err, "invalid DNS TTL", "value", value)
+			return ttl
+		}
+		ttl = annotationTTL
+	}
+	return ttl
+}
+
+// getDNSTargets returns the IP addresses or the hostname specified by dnsTargetsAnnotation.
+// It returns nil if the annotation is not specified, or an error if the value is invalid or not allowed.
+// IP addresses must be in AllowedDNSTargetCIDRs, and a hostname must be under AllowedDNSTargetDomains.
+func (r *HTTPProxyReconciler) getDNSTargets(owner client.Object) ([]net.IP, []string, error) {
+	value, ok := owner.GetAnnotations()[dnsTargetsAnnotation]
+	if !ok {
+		return nil, nil, nil
+	}
+	targets := splitCommaSeparated(value)
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no DNS targets: %q", value)
+	}
+
+	if len(targets) == 1 && net.ParseIP(targets[0]) == nil {
+		hostname := targets[0]
+		if errs := validation.IsDNS1123Subdomain(hostname); len(errs) != 0 {
+			return nil, nil, fmt.Errorf("invalid DNS target %q: %s", hostname, strings.Join(errs, ", "))
+		}
+		if !isUnderDomains(hostname, r.AllowedDNSTargetDomains) {
+			return nil, nil, fmt.Errorf("DNS target %q is not under the allowed domains", hostname)
+		}
+		return nil, []string{hostname}, nil
 	}
-	hp.Spec.VirtualHost.TLS.SecretName = certNamespace + "/" + certificateName
 
-	err := r.Patch(ctx, hp, client.Merge)
+	ips := make([]net.IP, 0, len(targets))
+	for _, target := range targets {
+		ip := net.ParseIP(target)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid IP address %q: only one hostname or IP addresses can be specified", target)
+		}
+		if !slices.ContainsFunc(r.AllowedDNSTargetCIDRs, func(cidr *net.IPNet) bool { return cidr.Contains(ip) }) {
+			return nil, nil, fmt.Errorf("IP address %q is not in the allowed CIDRs", target)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil, nil
+}
+
+// parseDNSTTL parses a TTL in seconds. The TTL must be a positive 32-bit signed integer (RFC 2181).
+func parseDNSTTL(value string) (int64, error) {
+	ttl, err := strconv.ParseInt(value, 10, 32)
 	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("DNS TTL must be greater than 0: %d", ttl)
+	}
+	return ttl, nil
+}
+
+// trackResourceOwnership sets the ownership of obj by setResourceOwnership.
+// If obj is in another namespace than the owner, it adds the finalizer to the owner to clean obj up.
+func (r *HTTPProxyReconciler) trackResourceOwnership(owner client.Object, obj client.Object) error {
+	if err := r.setResourceOwnership(owner, obj); err != nil {
 		return err
 	}
+	if obj.GetNamespace() == owner.GetNamespace() {
+		return nil
+	}
 
-	log.Info("HTTPProxy SecretName successfully reconciled")
+	if !controllerutil.ContainsFinalizer(owner, finalizerName) {
+		controllerutil.AddFinalizer(owner, finalizerName)
+		err := r.Update(context.Background(), owner)
+		return err
+	}
 	return nil
 }
 
-func (r *HTTPProxyReconciler) trackResourceOwnership(hp *projectcontourv1.HTTPProxy, obj client.Object) error {
+// setResourceOwnership sets ownerAnnotation and ingressClassOwnerAnnotation on obj,
+// and the controller reference to the owner if obj is in the same namespace.
+func (r *HTTPProxyReconciler) setResourceOwnership(owner client.Object, obj client.Object) error {
 	annotations := obj.GetAnnotations()
 	if annotations == nil {
 		annotations = make(map[string]string)
 	}
-	annotations[ownerAnnotation] = hp.Namespace + "/" + hp.Name
-	obj.SetAnnotations(annotations)
-
-	if obj.GetNamespace() == hp.Namespace {
-		return ctrl.SetControllerReference(hp, obj, r.Scheme)
+	annotations[ownerAnnotation] = getOwnerKey(owner)
+	if !r.watchesAllIngressClasses() {
+		annotations[ingressClassOwnerAnnotation] = r.getOwnerIngressClassName(owner)
 	}
+	obj.SetAnnotations(annotations)
 
-	if !controllerutil.ContainsFinalizer(hp, finalizerName) {
-		controllerutil.AddFinalizer(hp, finalizerName)
-		err := r.Update(context.Background(), hp)
-		return err
+	if obj.GetNamespace() == owner.GetNamespace() {
+		return ctrl.SetControllerReference(owner, obj, r.Scheme)
 	}
 	return nil
 }
 
-func (r *HTTPProxyReconciler) cleanupCrossNamespaceResources(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+func (r *HTTPProxyReconciler) cleanupCrossNamespaceResources(ctx context.Context, hp client.Object, log logr.Logger) error {
 	if !controllerutil.ContainsFinalizer(hp, finalizerName) {
 		return nil
 	}
 
+	// the queued resources would be leaked if they were created after the finalizer is removed
+	r.forgetQueuedResources(hp, nil, log)
+
 	if err := r.cleanupCrossNamespaceDNSEndpoints(ctx, hp, log); err != nil {
 		return err
 	}
@@ -526,19 +1091,23 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceResources(ctx context.Context
 	return r.Update(ctx, hp)
 }
 
-func (r *HTTPProxyReconciler) cleanupCrossNamespaceDNSEndpoints(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+func (r *HTTPProxyReconciler) cleanupCrossNamespaceDNSEndpoints(ctx context.Context, hp client.Object, log logr.Logger) error {
 	if !r.CreateDNSEndpoint {
 		return nil
 	}
 
-	deNs, ok := hp.Annotations[dnsNamespaceAnnotation]
-	if !ok || !slices.Contains(r.AllowedDNSNamespaces, deNs) {
+	deNs, ok := hp.GetAnnotations()[dnsNamespaceAnnotation]
+	if !ok {
 		return nil
 	}
+	allowed, err := r.isAllowedDNSNamespace(ctx, deNs)
+	if err != nil || !allowed {
+		return err
+	}
 
 	del := &unstructured.UnstructuredList{}
 	del.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
-	err := r.List(ctx, del, &client.ListOptions{Namespace: deNs})
+	err = r.List(ctx, del, &client.ListOptions{Namespace: deNs})
 	if err != nil {
 		return err
 	}
@@ -546,7 +1115,7 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceDNSEndpoints(ctx context.Cont
 	for _, de := range del.Items {
 		annotations := de.GetAnnotations()
 		owner, ok := annotations[ownerAnnotation]
-		if !ok || owner != hp.Namespace+"/"+hp.Name {
+		if !ok || owner != getOwnerKey(hp) {
 			continue
 		}
 
@@ -560,19 +1129,23 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceDNSEndpoints(ctx context.Cont
 	return nil
 }
 
-func (r *HTTPProxyReconciler) cleanupCrossNamespaceCertificates(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+func (r *HTTPProxyReconciler) cleanupCrossNamespaceCertificates(ctx context.Context, hp client.Object, log logr.Logger) error {
 	if !r.CreateCertificate {
 		return nil
 	}
 
-	issuerNs, ok := hp.Annotations[issuerNamespaceAnnotation]
-	if !ok || !slices.Contains(r.AllowedIssuerNamespaces, issuerNs) {
+	issuerNs, ok := hp.GetAnnotations()[issuerNamespaceAnnotation]
+	if !ok {
 		return nil
 	}
+	allowed, err := r.isAllowedIssuerNamespace(ctx, issuerNs)
+	if err != nil || !allowed {
+		return err
+	}
 
 	certList := &unstructured.UnstructuredList{}
 	certList.SetGroupVersionKind(certManagerGroupVersion.WithKind(CertificateKind))
-	err := r.List(ctx, certList, &client.ListOptions{Namespace: issuerNs})
+	err = r.List(ctx, certList, &client.ListOptions{Namespace: issuerNs})
 	if err != nil {
 		return err
 	}
@@ -580,7 +1153,7 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceCertificates(ctx context.Cont
 	for _, cert := range certList.Items {
 		annotations := cert.GetAnnotations()
 		owner, ok := annotations[ownerAnnotation]
-		if !ok || owner != hp.Namespace+"/"+hp.Name {
+		if !ok || owner != getOwnerKey(hp) {
 			continue
 		}
 
@@ -594,19 +1167,23 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceCertificates(ctx context.Cont
 	return nil
 }
 
-func (r *HTTPProxyReconciler) cleanupCrossNamespaceTLSCertificateDelegations(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+func (r *HTTPProxyReconciler) cleanupCrossNamespaceTLSCertificateDelegations(ctx context.Context, hp client.Object, log logr.Logger) error {
 	if !r.CreateCertificate {
 		return nil
 	}
 
-	issuerNs, ok := hp.Annotations[issuerNamespaceAnnotation]
-	if !ok || !slices.Contains(r.AllowedIssuerNamespaces, issuerNs) {
+	issuerNs, ok := hp.GetAnnotations()[issuerNamespaceAnnotation]
+	if !ok {
 		return nil
 	}
+	allowed, err := r.isAllowedIssuerNamespace(ctx, issuerNs)
+	if err != nil || !allowed {
+		return err
+	}
 
 	tcdList := &unstructured.UnstructuredList{}
 	tcdList.SetGroupVersionKind(contourGroupVersion.WithKind(TLSCertificateDelegationListKind))
-	err := r.List(ctx, tcdList, &client.ListOptions{Namespace: issuerNs})
+	err = r.List(ctx, tcdList, &client.ListOptions{Namespace: issuerNs})
 	if err != nil {
 		return err
 	}
@@ -614,7 +1191,7 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceTLSCertificateDelegations(ctx
 	for _, tcd := range tcdList.Items {
 		annotations := tcd.GetAnnotations()
 		owner, ok := annotations[ownerAnnotation]
-		if !ok || owner != hp.Namespace+"/"+hp.Name {
+		if !ok || owner != getOwnerKey(hp) {
 			continue
 		}
 
@@ -628,45 +1205,10 @@ func (r *HTTPProxyReconciler) cleanupCrossNamespaceTLSCertificateDelegations(ctx
 	return nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// start worker if CertApplier requires one
-	if certWorker, ok := r.CertApplier.(ApplyWorker[*cmv1.Certificate]); ok {
-		if err := mgr.Add(certWorker); err != nil {
-			return err
-		}
-		if err := certWorker.RegisterMetrics(metrics.Registry); err != nil {
-			return err
-		}
-	}
-	listHPs := func(ctx context.Context, a client.Object) []reconcile.Request {
-		if a.GetNamespace() != r.ServiceKey.Namespace {
-			return nil
-		}
-		if a.GetName() != r.ServiceKey.Name {
-			return nil
-		}
-
-		var hpList projectcontourv1.HTTPProxyList
-		err := r.List(ctx, &hpList)
-		if err != nil {
-			r.Log.Error(err, "listing HTTPProxy failed")
-			return nil
-		}
-
-		requests := make([]reconcile.Request, len(hpList.Items))
-		for i, hp := range hpList.Items {
-			requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{
-				Name:      hp.Name,
-				Namespace: hp.Namespace,
-			}}
-		}
-		return requests
-	}
-
+var (
 	// Spec OR metadata changed => reconcile.
 	// Status-only (or no-op) update => ignore.
-	specOrMetadataChanged := predicate.Funcs{
+	specOrMetadataChanged = predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			oldObj := e.ObjectOld
 			newObj := e.ObjectNew
@@ -680,7 +1222,8 @@ func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			if !reflect.DeepEqual(oldObj.GetLabels(), newObj.GetLabels()) {
 				return true
 			}
-			if !reflect.DeepEqual(oldObj.GetAnnotations(), newObj.GetAnnotations()) {
+			// statusAnnotation is written by contour-plus itself as a result of reconciliation
+			if !reflect.DeepEqual(withoutStatusAnnotation(oldObj.GetAnnotations()), withoutStatusAnnotation(newObj.GetAnnotations())) {
 				return true
 			}
 
@@ -690,17 +1233,95 @@ func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	// should only be used in .Owns
-	ignoreChildCreateEvent := predicate.Funcs{
+	ignoreChildCreateEvent = predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
 			return false
 		},
 	}
 
-	ignoreInitialCreateEvent := predicate.Funcs{
+	ignoreInitialCreateEvent = predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
 			return !e.IsInInitialList
 		},
 	}
+)
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the index is shared with HTTPRouteReconciler
+	if err := r.setupOwnerIndex(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	if err := r.registerManagedResourcesCollector(metrics.Registry); err != nil {
+		return err
+	}
+	if r.CreateCertificate {
+		informer, err := mgr.GetCache().GetInformer(context.Background(), &cmv1.Certificate{})
+		if err != nil {
+			return err
+		}
+		if _, err := informer.AddEventHandler(observeCertificateReady(mgr.GetCache(), r.Log)); err != nil {
+			return err
+		}
+	}
+	if r.detectsFQDNConflicts() {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &projectcontourv1.HTTPProxy{}, fqdnIndexField, indexFQDN); err != nil {
+			return err
+		}
+	}
+	// start workers if the appliers require ones
+	if dnsWorker, ok := r.DNSApplier.(ApplyWorker[*unstructured.Unstructured]); ok {
+		if err := mgr.Add(dnsWorker); err != nil {
+			return err
+		}
+		if err := dnsWorker.RegisterMetrics(metrics.Registry); err != nil {
+			return err
+		}
+	}
+	if certWorker, ok := r.CertApplier.(ApplyWorker[*cmv1.Certificate]); ok {
+		// the pending Certificates are re-derived from the owners because the queue is lost on restart
+		if recoverer, ok := certWorker.(Recoverer[*cmv1.Certificate]); ok {
+			recoverer.SetRecoverFunc(r.pendingCertificates)
+		}
+		if err := mgr.Add(certWorker); err != nil {
+			return err
+		}
+		if err := certWorker.RegisterMetrics(metrics.Registry); err != nil {
+			return err
+		}
+	}
+	if r.GarbageCollectionInterval > 0 {
+		gc := &garbageCollector{r: r, apiReader: mgr.GetAPIReader(), interval: r.GarbageCollectionInterval}
+		if err := mgr.Add(gc); err != nil {
+			return err
+		}
+	}
+	listHPs := func(ctx context.Context, a client.Object) []reconcile.Request {
+		if !r.isWatchedService(a) {
+			return nil
+		}
+
+		var hpList projectcontourv1.HTTPProxyList
+		err := r.List(ctx, &hpList)
+		if err != nil {
+			r.Log.Error(err, "listing HTTPProxy failed")
+			return nil
+		}
+
+		// enqueue only HTTPProxies that use the changed Service
+		var requests []reconcile.Request
+		for i := range hpList.Items {
+			hp := &hpList.Items[i]
+			if key, err := r.getServiceKey(hp); err != nil || key != client.ObjectKeyFromObject(a) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      hp.Name,
+				Namespace: hp.Namespace,
+			}})
+		}
+		return requests
+	}
 
 	// specOrMetadataChanged predicate is added so that only spec and metadata changes result in a workqueue event.
 	// ignoreInitialCreateEvent is added to guarantee that only one workqueue event is queued for each HTTPProxy at controller startup.
@@ -716,13 +1337,35 @@ func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if certWorker, ok := r.CertApplier.(ApplyWorker[*cmv1.Certificate]); ok {
 		b = b.WatchesRawSource(source.Channel(certWorker.GetRetryChannel(), &handler.TypedEnqueueRequestForObject[*projectcontourv1.HTTPProxy]{}))
 	}
+	if dnsWorker, ok := r.DNSApplier.(ApplyWorker[*unstructured.Unstructured]); ok {
+		b = b.WatchesRawSource(source.Channel(dnsWorker.GetRetryChannel(), &handler.TypedEnqueueRequestForObject[*projectcontourv1.HTTPProxy]{}))
+	}
+
+	b = r.watchDomainPolicies(b, func() client.ObjectList { return &projectcontourv1.HTTPProxyList{} })
+	b = r.watchNamespaceSelectors(b, func() client.ObjectList { return &projectcontourv1.HTTPProxyList{} })
 
-	// DNSEndpoint, Certificate, & TLSCertificateDelegation resource should emit HTTPProxy workqueue event only when their specs have changed.
-	// predicate.GenerationChangedPredicate ignores any status or metadata updates made by other controllers and
-	// ignoreChildCreateEvent ignores events emitted when contour-plus creates these resources.
-	// This leaves only spec update and object delete events capable of emitting HTTPProxy workqueue event.
-	// WARNING: spec change mady by contour-plus will still emit HTTPPRoxy event.
-	// If the new release of contour-plus is expected to update the spec of child component, bear in mind that it will trigger another reconciliation for each resource with spec update.
+	// the status of Certificates is reported on HTTPProxy; ownChildren ignores status updates
+	if r.CreateCertificate {
+		b = b.Watches(&cmv1.Certificate{}, handler.EnqueueRequestsFromMapFunc(listCertificateOwner), builder.WithPredicates(certificateStatusChanged))
+	}
+
+	// the loser of an FQDN conflict should be reconciled when the winner is changed or deleted
+	if r.detectsFQDNConflicts() {
+		b = b.Watches(&projectcontourv1.HTTPProxy{}, handler.EnqueueRequestsFromMapFunc(r.listConflictingHPs), builder.WithPredicates(specOrMetadataChanged))
+	}
+
+	return r.ownChildren(b).Complete(r)
+}
+
+// ownChildren adds watches for the child resources to the controller builder.
+//
+// DNSEndpoint, Certificate, & TLSCertificateDelegation resource should emit HTTPProxy workqueue event only when their specs have changed.
+// predicate.GenerationChangedPredicate ignores any status or metadata updates made by other controllers and
+// ignoreChildCreateEvent ignores events emitted when contour-plus creates these resources.
+// This leaves only spec update and object delete events capable of emitting HTTPProxy workqueue event.
+// WARNING: spec change mady by contour-plus will still emit HTTPPRoxy event.
+// If the new release of contour-plus is expected to update the spec of child component, bear in mind that it will trigger another reconciliation for each resource with spec update.
+func (r *HTTPProxyReconciler) ownChildren(b *builder.Builder) *builder.Builder {
 	if r.CreateDNSEndpoint {
 		obj := &unstructured.Unstructured{}
 		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
@@ -734,10 +1377,28 @@ func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		tcdObj.SetGroupVersionKind(contourGroupVersion.WithKind(TLSCertificateDelegationKind))
 		b = b.Owns(tcdObj, builder.WithPredicates(ignoreChildCreateEvent, predicate.GenerationChangedPredicate{}))
 	}
-	return b.Complete(r)
+	return b
 }
 
-func makeEndpoints(hostname string, ips []net.IP) []map[string]interface{} {
+// makeEndpoints makes A/AAAA records of hostname pointing to ips.
+// If ips is empty, it makes a CNAME record pointing to the first of lbHostnames instead.
+// IP addresses take precedence over hostnames because a CNAME record cannot coexist with other records
+// and cannot have multiple targets.
+func makeEndpoints(hostname string, ips []net.IP, lbHostnames []string, ttl int64) []map[string]interface{} {
+	if len(ips) == 0 {
+		if len(lbHostnames) == 0 {
+			return nil
+		}
+		return []map[string]interface{}{
+			{
+				"dnsName":    hostname,
+				"targets":    []string{lbHostnames[0]},
+				"recordType": "CNAME",
+				"recordTTL":  ttl,
+			},
+		}
+	}
+
 	ipv4Targets, ipv6Targets := ipsToTargets(ips)
 	var endpoints []map[string]interface{}
 	if len(ipv4Targets) != 0 {
@@ -745,7 +1406,7 @@ func makeEndpoints(hostname string, ips []net.IP) []map[string]interface{} {
 			"dnsName":    hostname,
 			"targets":    ipv4Targets,
 			"recordType": "A",
-			"recordTTL":  3600,
+			"recordTTL":  ttl,
 		})
 	}
 	if len(ipv6Targets) != 0 {
@@ -753,7 +1414,7 @@ func makeEndpoints(hostname string, ips []net.IP) []map[string]interface{} {
 			"dnsName":    hostname,
 			"targets":    ipv6Targets,
 			"recordType": "AAAA",
-			"recordTTL":  3600,
+			"recordTTL":  ttl,
 		})
 	}
 	return endpoints
@@ -772,7 +1433,7 @@ func ipsToTargets(ips []net.IP) ([]string, []string) {
 	return ipv4Targets, ipv6Targets
 }
 
-func makeDelegationEndpoint(hostname, delegatedDomain string) []map[string]interface{} {
+func makeDelegationEndpoint(hostname, delegatedDomain string, ttl int64) []map[string]interface{} {
 	fqdn := strings.Trim(hostname, ".")
 	fqdn = strings.TrimPrefix(fqdn, "*.")
 	return []map[string]interface{}{
@@ -780,23 +1441,21 @@ func makeDelegationEndpoint(hostname, delegatedDomain string) []map[string]inter
 			"dnsName":    "_acme-challenge." + fqdn,
 			"targets":    []string{"_acme-challenge." + fqdn + "." + delegatedDomain},
 			"recordType": "CNAME",
-			"recordTTL":  3600,
+			"recordTTL":  ttl,
 		},
 	}
 }
 
-func getCertificateName(r *HTTPProxyReconciler, hp *projectcontourv1.HTTPProxy) string {
-	certNamespace, ok := hp.Annotations[issuerNamespaceAnnotation]
-	if !ok || certNamespace == "" || certNamespace == hp.Namespace {
-		return r.Prefix + hp.Name
-	}
-	return r.Prefix + hp.Namespace + "-" + hp.Name
+func getCertificateName(r *HTTPProxyReconciler, owner client.Object) string {
+	return getChildName(r, owner, owner.GetAnnotations()[issuerNamespaceAnnotation])
 }
 
+// getCertificateSecretName returns the name of the Secret of the Certificate for hp, or an empty string if none.
+// It should be called before reconcileSecretName rewrites the secretName of hp.
 func getCertificateSecretName(r *HTTPProxyReconciler, hp *projectcontourv1.HTTPProxy) string {
 	certNamespace, ok := hp.Annotations[issuerNamespaceAnnotation]
 	if !ok || certNamespace == "" || certNamespace == hp.Namespace {
-		if hp.Spec.VirtualHost.TLS == nil {
+		if hp.Spec.VirtualHost == nil || hp.Spec.VirtualHost.TLS == nil {
 			return ""
 		}
 		return hp.Spec.VirtualHost.TLS.SecretName
@@ -804,10 +1463,29 @@ func getCertificateSecretName(r *HTTPProxyReconciler, hp *projectcontourv1.HTTPP
 	return r.Prefix + hp.Namespace + "-" + hp.Name
 }
 
-func getDNSEndpointName(r *HTTPProxyReconciler, hp *projectcontourv1.HTTPProxy) string {
-	deNamespace, ok := hp.Annotations[dnsNamespaceAnnotation]
-	if !ok || deNamespace == "" || deNamespace == hp.Namespace {
-		return r.Prefix + hp.Name
+func getDNSEndpointName(r *HTTPProxyReconciler, owner client.Object) string {
+	return getChildName(r, owner, owner.GetAnnotations()[dnsNamespaceAnnotation])
+}
+
+// getChildName returns the name of a child resource of the owner generated in namespace.
+// The name includes the namespace of the owner if the child is in another namespace.
+// The names for HTTPRoute are prefixed with httpRouteNamePrefix so that they do not collide with those for
+// HTTPProxy of the same name.
+func getChildName(r *HTTPProxyReconciler, owner client.Object, namespace string) string {
+	name := owner.GetName()
+	if namespace != "" && namespace != owner.GetNamespace() {
+		name = owner.GetNamespace() + "-" + name
 	}
-	return r.Prefix + hp.Namespace + "-" + hp.Name
+	if _, ok := owner.(*gatewayv1.HTTPRoute); ok {
+		name = httpRouteNamePrefix + name
+	}
+	return r.Prefix + name
+}
+
+// proxyHostnames returns the hostnames for which child resources are generated from HTTPProxy.
+func proxyHostnames(hp *projectcontourv1.HTTPProxy) []string {
+	if hp.Spec.VirtualHost == nil || hp.Spec.VirtualHost.Fqdn == "" {
+		return nil
+	}
+	return []string{hp.Spec.VirtualHost.Fqdn}
 }
@@ -3,18 +3,26 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net"
+	"slices"
 	"testing"
 	"time"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
 )
 
 const (
@@ -149,6 +157,40 @@ func testHTTPProxyReconcile() {
 		Expect(crtSpec["revisionHistoryLimit"]).Should(BeNil())
 	})
 
+	It("should record events on HTTPProxy", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with a namespace annotation that is not allowed")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[dnsNamespaceAnnotation] = "not-allowed"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting events on the HTTPProxy")
+		Eventually(func(g Gomega) {
+			evList := &eventsv1.EventList{}
+			g.Expect(k8sClient.List(context.Background(), evList, client.InNamespace(ns))).Should(Succeed())
+			var reasons []string
+			for _, ev := range evList.Items {
+				if ev.Regarding.Kind == HTTPProxyKind && ev.Regarding.Name == hpKey.Name {
+					reasons = append(reasons, ev.Reason)
+				}
+			}
+			g.Expect(reasons).Should(ContainElements(eventReasonCreated, eventReasonIgnoredAnnotation))
+		}, 10*time.Second).Should(Succeed())
+	})
+
 	It(`should not create DNSEndpoint and Certificate if "contour-plus.cybozu.com/exclude"" is "true"`, func() {
 		scm, mgr := setupManager()
 
@@ -182,6 +224,120 @@ func testHTTPProxyReconcile() {
 		Expect(crtList.Items).Should(BeEmpty())
 	})
 
+	It("should delete DNSEndpoint and Certificate when HTTPProxy gets excluded", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		objKey := client.ObjectKey{Name: hpKey.Name, Namespace: hpKey.Namespace}
+		dObjKey := client.ObjectKey{Name: hpKey.Name + "-delegation", Namespace: hpKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("adding the annotation to exclude from contour-plus's targets")
+		Eventually(func() error {
+			hp := &projectcontourv1.HTTPProxy{}
+			if err := k8sClient.Get(context.Background(), hpKey, hp); err != nil {
+				return err
+			}
+			hp.Annotations[excludeAnnotation] = "true"
+			return k8sClient.Update(context.Background(), hp)
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that DNSEndpoints and Certificate are deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), objKey, dnsEndpoint())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), objKey, certificate())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+	})
+
+	It("should delete resources that are no longer requested by annotations", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       IssuerKind,
+			AllowCustomDelegations:  true,
+			AllowedDelegatedDomains: []string{testDelegationName},
+			CreateDNSEndpoint:       true,
+			CreateCertificate:       true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with the delegated domain annotation")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[delegatedDomainAnnotation] = testDelegationName
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		objKey := client.ObjectKey{Name: hpKey.Name, Namespace: hpKey.Namespace}
+		dObjKey := client.ObjectKey{Name: hpKey.Name + "-delegation", Namespace: hpKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("removing the delegated domain and tls-acme annotations")
+		Eventually(func() error {
+			hp := &projectcontourv1.HTTPProxy{}
+			if err := k8sClient.Get(context.Background(), hpKey, hp); err != nil {
+				return err
+			}
+			delete(hp.Annotations, delegatedDomainAnnotation)
+			delete(hp.Annotations, testACMETLSAnnotation)
+			return k8sClient.Update(context.Background(), hp)
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that delegation DNSEndpoint and Certificate are deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), objKey, certificate())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+
+		By("confirming that DNSEndpoint is kept")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), objKey, dnsEndpoint())
+		}, 3*time.Second).Should(Succeed())
+	})
+
 	It("should create delegation DNSEndpoint if requested", func() {
 		scm, mgr := setupManager()
 
@@ -406,6 +562,134 @@ func testHTTPProxyReconcile() {
 		Expect(dEndPoint["recordType"]).Should(Equal("CNAME"))
 	})
 
+	It("should create DNSEndpoints with TTL specified by annotation", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultDelegatedDomain: testDelegationName,
+			DefaultDNSTTL:          300,
+			CreateDNSEndpoint:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with TTL annotation")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[dnsTTLAnnotation] = "60"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		de := dnsEndpoint()
+		objKey := client.ObjectKey{Name: hpKey.Name, Namespace: hpKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["recordTTL"]).Should(BeEquivalentTo(60))
+
+		By("getting delegation DNSEndpoint")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{Name: hpKey.Name + "-delegation", Namespace: hpKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		dEndPoint := dde.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(dEndPoint["recordTTL"]).Should(BeEquivalentTo(60))
+
+		By("removing TTL annotation")
+		latest := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), hpKey, latest)).To(Succeed())
+		base := latest.DeepCopy()
+		delete(latest.Annotations, dnsTTLAnnotation)
+		Expect(k8sClient.Patch(context.Background(), latest, client.MergeFrom(base))).To(Succeed())
+
+		By("confirming that the default TTL is used")
+		Eventually(func() interface{} {
+			if err := k8sClient.Get(context.Background(), objKey, de); err != nil {
+				return nil
+			}
+			return de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})["recordTTL"]
+		}, 5*time.Second).Should(BeEquivalentTo(300))
+	})
+
+	It("should create Certificate and delegation DNSEndpoint with additional DNS names", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:                  testServiceKey,
+			DefaultIssuerName:           "test-issuer",
+			DefaultIssuerKind:           IssuerKind,
+			DefaultDelegatedDomain:      testDelegationName,
+			AllowedAdditionalDNSDomains: []string{"example.com"},
+			CreateDNSEndpoint:           true,
+			CreateCertificate:           true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with additional DNS names")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[additionalDNSNamesAnnotation] = "alias.example.com, *.example.com, other.example.org"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate with the additional DNS names")
+		objKey := client.ObjectKey{Name: hpKey.Name, Namespace: hpKey.Namespace}
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}, 5*time.Second).Should(Succeed())
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName, "alias.example.com", "*.example.com"}))
+		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+
+		By("getting delegation DNSEndpoint for the additional DNS names")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{Name: hpKey.Name + "-delegation", Namespace: hpKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		var delegated []interface{}
+		for _, ep := range dde.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{}) {
+			delegated = append(delegated, ep.(map[string]interface{})["dnsName"])
+		}
+		Expect(delegated).Should(Equal([]interface{}{
+			"_acme-challenge." + dnsName,
+			"_acme-challenge.alias.example.com",
+			"_acme-challenge.example.com",
+		}))
+
+		By("confirming that DNSEndpoint does not have records for the additional DNS names")
+		de := dnsEndpoint()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		endPoints := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})
+		Expect(endPoints).Should(HaveLen(1))
+
+		By("requesting DNS records for the additional DNS names")
+		latest := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), hpKey, latest)).To(Succeed())
+		base := latest.DeepCopy()
+		latest.Annotations[additionalDNSRecordsAnnotation] = "true"
+		Expect(k8sClient.Patch(context.Background(), latest, client.MergeFrom(base))).To(Succeed())
+
+		Eventually(func() []interface{} {
+			if err := k8sClient.Get(context.Background(), objKey, de); err != nil {
+				return nil
+			}
+			var names []interface{}
+			for _, ep := range de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{}) {
+				names = append(names, ep.(map[string]interface{})["dnsName"])
+			}
+			return names
+		}, 5*time.Second).Should(Equal([]interface{}{dnsName, "alias.example.com", "*.example.com"}))
+	})
+
 	It("should create Certificate with specified IssuerKind", func() {
 		By("setup manager with ClusterIssuer")
 		scm, mgr := setupManager()
@@ -751,103 +1035,406 @@ func testHTTPProxyReconcile() {
 		}, 5*time.Second).Should(Succeed())
 	})
 
-	It(`should create Certificate with revisionHistoryLimit set if specified`, func() {
+	It("should create DNSEndpoints pointing to the Service of each ingress class", func() {
+		By("creating another load balancer service")
+		externalIP := "10.0.0.1"
+		externalSvcKey := client.ObjectKey{Namespace: testServiceKey.Namespace, Name: "test-svc-external"}
+		svc := &corev1.Service{
+			ObjectMeta: ctrl.ObjectMeta{
+				Namespace: externalSvcKey.Namespace,
+				Name:      externalSvcKey.Name,
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: 8080}},
+				Type:  corev1.ServiceTypeLoadBalancer,
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), svc)).ShouldNot(HaveOccurred())
+		DeferCleanup(func() {
+			_ = k8sClient.Delete(ctx, svc)
+		})
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: externalIP}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+
 		scm, mgr := setupManager()
 
 		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
 			ServiceKey:        testServiceKey,
-			DefaultIssuerName: "test-issuer",
-			DefaultIssuerKind: IssuerKind,
-			CreateCertificate: true,
-			CSRRevisionLimit:  1,
+			IngressClassName:  "internal",
+			CreateDNSEndpoint: true,
+			IngressClassServiceKeys: map[string]client.ObjectKey{
+				"external": externalSvcKey,
+			},
 		})).ShouldNot(HaveOccurred())
 
 		stopMgr := startTestManager(mgr)
 		defer stopMgr()
 
-		By("creating HTTPProxy")
-		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
-		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+		By("creating HTTPProxies of each class")
+		internalKey := client.ObjectKey{Name: "internal", Namespace: ns}
+		hp := newDummyHTTPProxy(internalKey)
+		hp.Spec.IngressClassName = "internal"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
 
-		By("getting Certificate")
-		crt := certificate()
-		objKey := client.ObjectKey{
-			Name:      hpKey.Name,
-			Namespace: hpKey.Namespace,
+		externalKey := client.ObjectKey{Name: "external", Namespace: ns}
+		hp = newDummyHTTPProxy(externalKey)
+		hp.Annotations[contourIngressClassNameAnnotation] = "external"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		otherKey := client.ObjectKey{Name: "other", Namespace: ns}
+		hp = newDummyHTTPProxy(otherKey)
+		hp.Annotations[contourIngressClassNameAnnotation] = "other"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoints with the addresses of each Service")
+		for key, ip := range map[client.ObjectKey]string{internalKey: dummyLoadBalancerIP, externalKey: externalIP} {
+			de := dnsEndpoint()
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, de)
+			}, 5*time.Second).Should(Succeed())
+			endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+			Expect(endPoint["targets"]).Should(Equal([]interface{}{ip}))
+			Expect(de.GetAnnotations()).Should(HaveKeyWithValue(ingressClassOwnerAnnotation, key.Name))
 		}
-		Eventually(func() error {
-			return k8sClient.Get(context.Background(), objKey, crt)
-		}).Should(Succeed())
 
-		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
-		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
-		Expect(crtSpec["secretName"]).Should(Equal(testSecretName))
-		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
-		Expect(crtSpec["usages"]).Should(Equal([]interface{}{
-			usageDigitalSignature,
-			usageKeyEncipherment,
-			usageServerAuth,
-		}))
-		Expect(crtSpec["revisionHistoryLimit"]).Should(Equal(int64(1)))
+		By("confirming that DNSEndpoint for the other class does not exist")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), otherKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
 	})
 
-	It(`should create Certificate with revisionHistoryLimit set if cert-manager.io/revision-history-limit is specified`, func() {
+	It("should create DNSEndpoint pointing to the target Service specified by annotation", func() {
+		By("creating another load balancer service")
+		targetIP := "10.0.0.2"
+		targetSvcKey := client.ObjectKey{Namespace: testServiceKey.Namespace, Name: "test-svc-target"}
+		svc := &corev1.Service{
+			ObjectMeta: ctrl.ObjectMeta{
+				Namespace: targetSvcKey.Namespace,
+				Name:      targetSvcKey.Name,
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: 8080}},
+				Type:  corev1.ServiceTypeLoadBalancer,
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), svc)).ShouldNot(HaveOccurred())
+		DeferCleanup(func() {
+			_ = k8sClient.Delete(ctx, svc)
+		})
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: targetIP}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+
 		scm, mgr := setupManager()
 
 		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
-			ServiceKey:        testServiceKey,
-			DefaultIssuerName: "test-issuer",
-			DefaultIssuerKind: IssuerKind,
-			CreateCertificate: true,
+			ServiceKey:            testServiceKey,
+			CreateDNSEndpoint:     true,
+			AllowedTargetServices: []client.ObjectKey{targetSvcKey},
 		})).ShouldNot(HaveOccurred())
 
 		stopMgr := startTestManager(mgr)
 		defer stopMgr()
 
-		By("creating HTTPProxy")
-		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
-		hp := newDummyHTTPProxy(hpKey)
-		hp.Annotations[revisionHistoryLimitAnnotation] = "2"
+		By("creating HTTPProxies with allowed and disallowed target Services")
+		targetKey := client.ObjectKey{Name: "target", Namespace: ns}
+		hp := newDummyHTTPProxy(targetKey)
+		hp.Annotations[targetServiceAnnotation] = targetSvcKey.String()
 		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
 
-		By("getting Certificate")
-		crt := certificate()
-		objKey := client.ObjectKey{
-			Name:      hpKey.Name,
-			Namespace: hpKey.Namespace,
-		}
+		disallowedKey := client.ObjectKey{Name: "disallowed", Namespace: ns}
+		hp = newDummyHTTPProxy(disallowedKey)
+		hp.Annotations[targetServiceAnnotation] = testServiceKey.Namespace + "/test-svc-other"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint with the addresses of the target Service")
+		de := dnsEndpoint()
 		Eventually(func() error {
-			return k8sClient.Get(context.Background(), objKey, crt)
-		}).Should(Succeed())
+			return k8sClient.Get(context.Background(), targetKey, de)
+		}, 5*time.Second).Should(Succeed())
+		endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["targets"]).Should(Equal([]interface{}{targetIP}))
 
-		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
-		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
-		Expect(crtSpec["secretName"]).Should(Equal(testSecretName))
-		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
-		Expect(crtSpec["usages"]).Should(Equal([]interface{}{
-			usageDigitalSignature,
-			usageKeyEncipherment,
-			usageServerAuth,
-		}))
-		Expect(crtSpec["revisionHistoryLimit"]).Should(Equal(int64(2)))
+		By("confirming that DNSEndpoint is not created with the addresses of the Envoy Service for the disallowed target Service")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), disallowedKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
+
+		By("updating the address of the target Service")
+		newTargetIP := "10.0.0.3"
+		Expect(k8sClient.Get(context.Background(), targetSvcKey, svc)).ShouldNot(HaveOccurred())
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: newTargetIP}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			de := dnsEndpoint()
+			g.Expect(k8sClient.Get(context.Background(), targetKey, de)).Should(Succeed())
+			endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+			g.Expect(endPoint["targets"]).Should(Equal([]interface{}{newTargetIP}))
+		}, 5*time.Second).Should(Succeed())
 	})
 
-	It(`should prioritize revisionHistoryLimit set by cert-manager.io/revision-history-limit is specified`, func() {
+	It("should create DNSEndpoint with the targets specified by annotation", func() {
 		scm, mgr := setupManager()
 
+		_, cidr, err := net.ParseCIDR("192.0.2.0/24")
+		Expect(err).ShouldNot(HaveOccurred())
 		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
-			ServiceKey:        testServiceKey,
-			DefaultIssuerName: "test-issuer",
-			DefaultIssuerKind: IssuerKind,
-			CreateCertificate: true,
-			CSRRevisionLimit:  1,
+			ServiceKey:            testServiceKey,
+			CreateDNSEndpoint:     true,
+			AllowedDNSTargetCIDRs: []*net.IPNet{cidr},
 		})).ShouldNot(HaveOccurred())
 
 		stopMgr := startTestManager(mgr)
 		defer stopMgr()
 
-		By("creating HTTPProxy")
-		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		By("creating HTTPProxies with allowed and disallowed DNS targets")
+		allowedKey := client.ObjectKey{Name: "allowed-targets", Namespace: ns}
+		hp := newDummyHTTPProxy(allowedKey)
+		hp.Annotations[dnsTargetsAnnotation] = "192.0.2.1,192.0.2.2"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		disallowedKey := client.ObjectKey{Name: "disallowed-targets", Namespace: ns}
+		hp = newDummyHTTPProxy(disallowedKey)
+		hp.Annotations[dnsTargetsAnnotation] = "198.51.100.1"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint with the specified targets")
+		de := dnsEndpoint()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), allowedKey, de)
+		}, 5*time.Second).Should(Succeed())
+		endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["recordType"]).Should(Equal("A"))
+		Expect(endPoint["targets"]).Should(Equal([]interface{}{"192.0.2.1", "192.0.2.2"}))
+
+		By("confirming that DNSEndpoint is not created with the addresses of the Envoy Service for disallowed targets")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), disallowedKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
+
+		By("confirming that the existing DNSEndpoint is kept when the targets become disallowed")
+		hp = &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), allowedKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[dnsTargetsAnnotation] = "198.51.100.1"
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Consistently(func(g Gomega) {
+			de := dnsEndpoint()
+			g.Expect(k8sClient.Get(context.Background(), allowedKey, de)).Should(Succeed())
+			endPoint := de.UnstructuredContent()["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+			g.Expect(endPoint["targets"]).Should(Equal([]interface{}{"192.0.2.1", "192.0.2.2"}))
+		}, 3*time.Second).Should(Succeed())
+	})
+
+	It("should not create resources for HTTPProxy whose FQDN is claimed by another HTTPProxy", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:         testServiceKey,
+			CreateDNSEndpoint:  true,
+			FQDNConflictPolicy: FQDNConflictPolicyOldest,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating the first HTTPProxy")
+		winnerKey := client.ObjectKey{Name: "fqdn-winner", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(winnerKey))).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), winnerKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("creating the second HTTPProxy with the same FQDN")
+		time.Sleep(time.Second) // make the creation timestamps differ
+		loserKey := client.ObjectKey{Name: "fqdn-loser", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(loserKey))).ShouldNot(HaveOccurred())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), loserKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
+
+		By("recording an event on the second HTTPProxy")
+		Eventually(func(g Gomega) {
+			var eventList eventsv1.EventList
+			g.Expect(k8sClient.List(context.Background(), &eventList, client.InNamespace(ns))).Should(Succeed())
+			var reasons []string
+			for _, ev := range eventList.Items {
+				if ev.Regarding.Name == loserKey.Name {
+					reasons = append(reasons, ev.Reason)
+				}
+			}
+			g.Expect(reasons).Should(ContainElement(eventReasonFQDNConflict))
+		}, 5*time.Second).Should(Succeed())
+
+		By("excluding the first HTTPProxy")
+		hp := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), winnerKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[excludeAnnotation] = "true"
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("creating DNSEndpoint for the second HTTPProxy")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), loserKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() bool {
+			return k8serrors.IsNotFound(k8sClient.Get(context.Background(), winnerKey, dnsEndpoint()))
+		}, 5*time.Second).Should(BeTrue())
+	})
+
+	It("should create DNSEndpoint and Certificate only for hostnames permitted by DomainPolicy and keep them once denied", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:         testServiceKey,
+			DefaultIssuerName:  "test-issuer",
+			DefaultIssuerKind:  ClusterIssuerKind,
+			CreateDNSEndpoint:  true,
+			CreateCertificate:  true,
+			EnableDomainPolicy: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy without DomainPolicy")
+		hpKey := client.ObjectKey{Name: "domain-policy", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
+
+		By("creating DomainPolicy for the namespace")
+		policy := &contourplusv1alpha1.DomainPolicy{
+			ObjectMeta: ctrl.ObjectMeta{Name: "test-" + ns},
+			Spec: contourplusv1alpha1.DomainPolicySpec{
+				Namespaces:     []string{ns},
+				AllowedDomains: []string{"example.com"},
+				AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Kind: ClusterIssuerKind, Name: "test-issuer"}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), policy)).ShouldNot(HaveOccurred())
+		DeferCleanup(func() {
+			_ = k8sClient.Delete(ctx, policy)
+		})
+
+		By("getting DNSEndpoint and Certificate")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("changing DomainPolicy not to permit the hostname")
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(policy), policy)).ShouldNot(HaveOccurred())
+		policy.Spec.AllowedDomains = []string{"example.org"}
+		Expect(k8sClient.Update(context.Background(), policy)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint and Certificate are kept")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 3*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 3*time.Second).Should(Succeed())
+	})
+
+	It(`should create Certificate with revisionHistoryLimit set if specified`, func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateCertificate: true,
+			CSRRevisionLimit:  1,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		By("getting Certificate")
+		crt := certificate()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
+		Expect(crtSpec["secretName"]).Should(Equal(testSecretName))
+		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+		Expect(crtSpec["usages"]).Should(Equal([]interface{}{
+			usageDigitalSignature,
+			usageKeyEncipherment,
+			usageServerAuth,
+		}))
+		Expect(crtSpec["revisionHistoryLimit"]).Should(Equal(int64(1)))
+	})
+
+	It(`should create Certificate with revisionHistoryLimit set if cert-manager.io/revision-history-limit is specified`, func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[revisionHistoryLimitAnnotation] = "2"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate")
+		crt := certificate()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
+		Expect(crtSpec["secretName"]).Should(Equal(testSecretName))
+		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+		Expect(crtSpec["usages"]).Should(Equal([]interface{}{
+			usageDigitalSignature,
+			usageKeyEncipherment,
+			usageServerAuth,
+		}))
+		Expect(crtSpec["revisionHistoryLimit"]).Should(Equal(int64(2)))
+	})
+
+	It(`should prioritize revisionHistoryLimit set by cert-manager.io/revision-history-limit is specified`, func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateCertificate: true,
+			CSRRevisionLimit:  1,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
 		hp := newDummyHTTPProxy(hpKey)
 		hp.Annotations[revisionHistoryLimitAnnotation] = "2"
 		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
@@ -981,6 +1568,115 @@ func testHTTPProxyReconcile() {
 		Expect(keySpec["size"]).Should(BeNil())
 	})
 
+	It("should create a Certificate with the default duration and renewBefore", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:                    testServiceKey,
+			DefaultIssuerName:             "test-issuer",
+			DefaultIssuerKind:             IssuerKind,
+			DefaultCertificateDuration:    2160 * time.Hour,
+			DefaultCertificateRenewBefore: 360 * time.Hour,
+			CreateCertificate:             true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		By("getting Certificate")
+		crt := certificate()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["duration"]).Should(Equal("2160h0m0s"))
+		Expect(crtSpec["renewBefore"]).Should(Equal("360h0m0s"))
+	})
+
+	It("should create a Certificate with the duration and renewBefore specified by annotations", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:                    testServiceKey,
+			DefaultIssuerName:             "test-issuer",
+			DefaultIssuerKind:             IssuerKind,
+			DefaultCertificateDuration:    2160 * time.Hour,
+			DefaultCertificateRenewBefore: 360 * time.Hour,
+			CreateCertificate:             true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with duration annotations")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[durationAnnotation] = "24h"
+		hp.Annotations[renewBeforeAnnotation] = "8h"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate")
+		crt := certificate()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["duration"]).Should(Equal("24h0m0s"))
+		Expect(crtSpec["renewBefore"]).Should(Equal("8h0m0s"))
+	})
+
+	It("should not create a Certificate if the duration annotation is invalid", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with an invalid duration annotation")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[durationAnnotation] = "90 days"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting a warning event on the HTTPProxy")
+		Eventually(func(g Gomega) {
+			evList := &eventsv1.EventList{}
+			g.Expect(k8sClient.List(context.Background(), evList, client.InNamespace(ns))).Should(Succeed())
+			var notes []string
+			for _, ev := range evList.Items {
+				if ev.Regarding.Name == hpKey.Name && ev.Reason == eventReasonIgnoredAnnotation {
+					notes = append(notes, ev.Note)
+				}
+			}
+			g.Expect(notes).Should(ContainElement(ContainSubstring(durationAnnotation)))
+		}, 10*time.Second).Should(Succeed())
+
+		By("confirming that Certificate does not exist")
+		crtList := certificateList()
+		Expect(k8sClient.List(context.Background(), crtList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(crtList.Items).Should(BeEmpty())
+	})
+
 	It("should propagate annotations to the generated resources", func() {
 		scm, mgr := setupManager()
 
@@ -1156,6 +1852,63 @@ func testHTTPProxyReconcile() {
 		}, 5*time.Second).ShouldNot(Succeed())
 	})
 
+	It("should create a DNSEndpoint in the namespace selected by labels", func() {
+		deNsObj := &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{GenerateName: testNamespacePrefix},
+		}
+		Expect(k8sClient.Create(context.Background(), deNsObj)).ShouldNot(HaveOccurred())
+		deNs := deNsObj.Name
+		DeferCleanup(func() {
+			_ = k8sClient.Delete(ctx, deNsObj)
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKey{Name: deNs}, &corev1.Namespace{})
+				return client.IgnoreNotFound(err) == nil
+			}, 10*time.Second).Should(BeTrue())
+		})
+
+		selector, err := labels.Parse("contour-plus.cybozu.com/shared-dns=true")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:                  testServiceKey,
+			CreateDNSEndpoint:           true,
+			AllowedDNSNamespaceSelector: selector,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with DNSEndpoint namespace annotation")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[dnsNamespaceAnnotation] = deNs
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint in the HTTPProxy namespace because the namespace is not selected")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("labeling the namespace")
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Name: deNs}, deNsObj)).ShouldNot(HaveOccurred())
+		deNsObj.Labels = map[string]string{"contour-plus.cybozu.com/shared-dns": "true"}
+		Expect(k8sClient.Update(context.Background(), deNsObj)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint in the selected namespace")
+		objKey := client.ObjectKey{
+			Name:      hpKey.Namespace + "-" + hpKey.Name,
+			Namespace: deNs,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() bool {
+			return k8serrors.IsNotFound(k8sClient.Get(context.Background(), hpKey, dnsEndpoint()))
+		}, 5*time.Second).Should(BeTrue())
+	})
+
 	It("should create DNSEndpoint and delegation DNSEndpoint in the specified namespace", func() {
 		deNsObj := &corev1.Namespace{
 			ObjectMeta: ctrl.ObjectMeta{GenerateName: testNamespacePrefix},
@@ -1283,24 +2036,83 @@ func testHTTPProxyReconcile() {
 		Expect(len(targetNamespaces)).Should(Equal(1))
 		Expect(targetNamespaces[0].(string)).Should(Equal(hpKey.Namespace))
 
-		By("ensuring Certificate is not created in the HTTPProxy namespace")
-		crtList := certificateList()
-		Expect(k8sClient.List(context.Background(), crtList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
-		Expect(crtList.Items).Should(BeEmpty())
+		By("ensuring Certificate is not created in the HTTPProxy namespace")
+		crtList := certificateList()
+		Expect(k8sClient.List(context.Background(), crtList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(crtList.Items).Should(BeEmpty())
+
+		By("ensuring HTTPProxy references the namespaced Certificate")
+		hpObj := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), hpKey, hpObj)).ShouldNot(HaveOccurred())
+		Expect(hpObj.Spec.VirtualHost.TLS.SecretName).Should(Equal(certNs + "/" + certName))
+
+		By("ensuring HTTPProxy deletion deletes the Certificate and TLSCertificateDelegation")
+		Expect(k8sClient.Delete(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, tcd)
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should delete Certificate and TLSCertificateDelegation in the specified namespace when HTTPProxy gets excluded", func() {
+		certNsObj := &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{GenerateName: testNamespacePrefix},
+		}
+		Expect(k8sClient.Create(context.Background(), certNsObj)).ShouldNot(HaveOccurred())
+		certNs := certNsObj.Name
+		DeferCleanup(func() {
+			_ = k8sClient.Delete(ctx, certNsObj)
+		})
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			CreateCertificate:       true,
+			DefaultIssuerKind:       IssuerKind,
+			DefaultIssuerName:       "test-issuer",
+			AllowedIssuerNamespaces: []string{certNs},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
 
-		By("ensuring HTTPProxy references the namespaced Certificate")
-		hpObj := &projectcontourv1.HTTPProxy{}
-		Expect(k8sClient.Get(context.Background(), hpKey, hpObj)).ShouldNot(HaveOccurred())
-		Expect(hpObj.Spec.VirtualHost.TLS.SecretName).Should(Equal(certNs + "/" + certName))
+		By("creating HTTPProxy with Certificate namespace annotation")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.VirtualHost.TLS = nil
+		hp.Annotations[issuerNamespaceAnnotation] = certNs
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
 
-		By("ensuring HTTPProxy deletion deletes the Certificate and TLSCertificateDelegation")
-		Expect(k8sClient.Delete(context.Background(), hp)).ShouldNot(HaveOccurred())
+		objKey := client.ObjectKey{Name: hpKey.Namespace + "-" + hpKey.Name, Namespace: certNs}
 		Eventually(func() error {
-			return k8sClient.Get(context.Background(), objKey, crt)
-		}, 5*time.Second).ShouldNot(Succeed())
+			return k8sClient.Get(context.Background(), objKey, certificate())
+		}, 5*time.Second).Should(Succeed())
 		Eventually(func() error {
-			return k8sClient.Get(context.Background(), objKey, tcd)
-		}, 5*time.Second).ShouldNot(Succeed())
+			return k8sClient.Get(context.Background(), objKey, tlsCertificateDelegation())
+		}, 5*time.Second).Should(Succeed())
+
+		By("adding the annotation to exclude from contour-plus's targets")
+		Eventually(func() error {
+			hp := &projectcontourv1.HTTPProxy{}
+			if err := k8sClient.Get(context.Background(), hpKey, hp); err != nil {
+				return err
+			}
+			hp.Annotations[excludeAnnotation] = "true"
+			return k8sClient.Update(context.Background(), hp)
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that Certificate and TLSCertificateDelegation are deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), objKey, certificate())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), objKey, tlsCertificateDelegation())
+			return k8serrors.IsNotFound(err)
+		}, 5*time.Second).Should(BeTrue())
 	})
 
 	It("should not create DNSEndpoint if the namespace is not allowed", func() {
@@ -1386,6 +2198,49 @@ func testHTTPProxyReconcile() {
 		Expect(tcdList.Items).Should(BeEmpty())
 	})
 
+	It("should keep Certificate if the issuer namespace is the namespace of HTTPProxy", func() {
+		scm, mgr := setupManager()
+
+		prefix := "test-"
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			Prefix:                  prefix,
+			CreateCertificate:       true,
+			DefaultIssuerKind:       IssuerKind,
+			DefaultIssuerName:       "test-issuer",
+			AllowedIssuerNamespaces: []string{ns},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with the issuer namespace annotation of its own namespace")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[issuerNamespaceAnnotation] = ns
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate in the namespace of HTTPProxy")
+		objKey := client.ObjectKey{Name: prefix + hpKey.Name, Namespace: ns}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("ensuring HTTPProxy references the namespaced Secret")
+		Eventually(func() string {
+			hpObj := &projectcontourv1.HTTPProxy{}
+			if err := k8sClient.Get(context.Background(), hpKey, hpObj); err != nil {
+				return ""
+			}
+			return hpObj.Spec.VirtualHost.TLS.SecretName
+		}, 5*time.Second).Should(Equal(ns + "/" + prefix + hpKey.Name))
+
+		By("confirming that Certificate is not deleted by the following reconciliations")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), objKey, certificate())
+		}, 3*time.Second).Should(Succeed())
+	})
+
 	It("should reconcile Certificate with CertificateApplyWorker", func() {
 		scm, mgr := setupManager()
 
@@ -1485,6 +2340,17 @@ func newDummyHTTPProxy(hpKey client.ObjectKey) *projectcontourv1.HTTPProxy {
 	}
 }
 
+func newDummyCertificate(certKey client.ObjectKey, owner string, dnsNames ...string) *cmv1.Certificate {
+	return &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   certKey.Namespace,
+			Name:        certKey.Name,
+			Annotations: map[string]string{ownerAnnotation: owner},
+		},
+		Spec: cmv1.CertificateSpec{DNSNames: dnsNames, SecretName: testSecretName},
+	}
+}
+
 func TestIsClassNameMatched(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -1660,6 +2526,283 @@ func TestIsClassNameMatched(t *testing.T) {
 	}
 }
 
+func TestGetServiceKey(t *testing.T) {
+	internalKey := client.ObjectKey{Namespace: "ingress", Name: "envoy-internal"}
+	externalKey := client.ObjectKey{Namespace: "ingress", Name: "envoy-external"}
+	r := &HTTPProxyReconciler{
+		ReconcilerOptions: ReconcilerOptions{
+			ServiceKey:       internalKey,
+			IngressClassName: "internal",
+			IngressClassServiceKeys: map[string]client.ObjectKey{
+				"external": externalKey,
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		specClass    string
+		expectMatch  bool
+		expectClass  string
+		expectSvcKey client.ObjectKey
+	}{
+		{
+			name:         "class of ingress-class-name",
+			specClass:    "internal",
+			expectMatch:  true,
+			expectClass:  "internal",
+			expectSvcKey: internalKey,
+		},
+		{
+			name:         "class of ingress-class-services",
+			annotations:  map[string]string{contourIngressClassNameAnnotation: "external"},
+			specClass:    "external",
+			expectMatch:  true,
+			expectClass:  "external",
+			expectSvcKey: externalKey,
+		},
+		{
+			name:         "conflicting classes",
+			annotations:  map[string]string{ingressClassNameAnnotation: "internal"},
+			specClass:    "external",
+			expectSvcKey: internalKey,
+		},
+		{
+			name:         "unknown class",
+			annotations:  map[string]string{ingressClassNameAnnotation: "other"},
+			expectSvcKey: internalKey,
+		},
+		{
+			name:         "no class",
+			expectSvcKey: internalKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       projectcontourv1.HTTPProxySpec{IngressClassName: tt.specClass},
+			}
+			if got := r.isClassNameMatched(hp); got != tt.expectMatch {
+				t.Errorf("isClassNameMatched() = %v, want %v", got, tt.expectMatch)
+			}
+			if got := r.getOwnerIngressClassName(hp); got != tt.expectClass {
+				t.Errorf("getOwnerIngressClassName() = %q, want %q", got, tt.expectClass)
+			}
+			got, err := r.getServiceKey(hp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expectSvcKey {
+				t.Errorf("getServiceKey() = %v, want %v", got, tt.expectSvcKey)
+			}
+		})
+	}
+}
+
+func TestGetTargetServiceKey(t *testing.T) {
+	envoyKey := client.ObjectKey{Namespace: "ingress", Name: "envoy"}
+	internalKey := client.ObjectKey{Namespace: "ingress", Name: "envoy-internal"}
+	r := &HTTPProxyReconciler{
+		ReconcilerOptions: ReconcilerOptions{
+			ServiceKey:            envoyKey,
+			AllowedTargetServices: []client.ObjectKey{internalKey},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		expectErr    bool
+		expectSvcKey client.ObjectKey
+	}{
+		{
+			name:         "no annotation",
+			expectSvcKey: envoyKey,
+		},
+		{
+			name:         "allowed Service",
+			annotations:  map[string]string{targetServiceAnnotation: "ingress/envoy-internal"},
+			expectSvcKey: internalKey,
+		},
+		{
+			name:        "disallowed Service",
+			annotations: map[string]string{targetServiceAnnotation: "ingress/envoy-other"},
+			expectErr:   true,
+		},
+		{
+			name:        "without namespace",
+			annotations: map[string]string{targetServiceAnnotation: "envoy-internal"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid format",
+			annotations: map[string]string{targetServiceAnnotation: "ingress/envoy/internal"},
+			expectErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{Namespace: "default", Annotations: tt.annotations},
+			}
+			_, err := r.getTargetServiceKey(hp)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("getTargetServiceKey() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			// the Envoy Service is not used in place of the invalid target Service
+			got, err := r.getServiceKey(hp)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("getServiceKey() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if got != tt.expectSvcKey {
+				t.Errorf("getServiceKey() = %v, want %v", got, tt.expectSvcKey)
+			}
+		})
+	}
+}
+
+func TestGetDNSTargets(t *testing.T) {
+	_, cidr4, _ := net.ParseCIDR("192.0.2.0/24")
+	_, cidr6, _ := net.ParseCIDR("2001:db8::/32")
+	r := &HTTPProxyReconciler{
+		ReconcilerOptions: ReconcilerOptions{
+			AllowedDNSTargetCIDRs:   []*net.IPNet{cidr4, cidr6},
+			AllowedDNSTargetDomains: []string{"example.net"},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		expectErr       bool
+		expectIPs       []string
+		expectHostnames []string
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "allowed IP addresses",
+			annotations: map[string]string{dnsTargetsAnnotation: "192.0.2.1, 2001:db8::1"},
+			expectIPs:   []string{"192.0.2.1", "2001:db8::1"},
+		},
+		{
+			name:            "allowed hostname",
+			annotations:     map[string]string{dnsTargetsAnnotation: "anycast.example.net"},
+			expectHostnames: []string{"anycast.example.net"},
+		},
+		{
+			name:        "IP address out of the allowed CIDRs",
+			annotations: map[string]string{dnsTargetsAnnotation: "192.0.2.1,198.51.100.1"},
+			expectErr:   true,
+		},
+		{
+			name:        "hostname out of the allowed domains",
+			annotations: map[string]string{dnsTargetsAnnotation: "anycast.example.org"},
+			expectErr:   true,
+		},
+		{
+			name:        "hostname with IP addresses",
+			annotations: map[string]string{dnsTargetsAnnotation: "192.0.2.1,anycast.example.net"},
+			expectErr:   true,
+		},
+		{
+			name:        "multiple hostnames",
+			annotations: map[string]string{dnsTargetsAnnotation: "a.example.net,b.example.net"},
+			expectErr:   true,
+		},
+		{
+			name:        "empty",
+			annotations: map[string]string{dnsTargetsAnnotation: ""},
+			expectErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{Namespace: "default", Annotations: tt.annotations},
+			}
+			ips, hostnames, err := r.getDNSTargets(hp)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("getDNSTargets() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			var gotIPs []string
+			for _, ip := range ips {
+				gotIPs = append(gotIPs, ip.String())
+			}
+			if !slices.Equal(gotIPs, tt.expectIPs) {
+				t.Errorf("getDNSTargets() IPs = %v, want %v", gotIPs, tt.expectIPs)
+			}
+			if !slices.Equal(hostnames, tt.expectHostnames) {
+				t.Errorf("getDNSTargets() hostnames = %v, want %v", hostnames, tt.expectHostnames)
+			}
+		})
+	}
+}
+
+func TestMakeEndpoints(t *testing.T) {
+	tests := []struct {
+		name              string
+		ips               []net.IP
+		lbHostnames       []string
+		expectRecordTypes []string
+		expectTargets     [][]string
+	}{
+		{
+			name:              "IPv4 and IPv6 addresses",
+			ips:               []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+			expectRecordTypes: []string{"A", "AAAA"},
+			expectTargets:     [][]string{{"10.0.0.1"}, {"fd00::1"}},
+		},
+		{
+			name:              "Hostname only",
+			lbHostnames:       []string{"lb.example.net"},
+			expectRecordTypes: []string{"CNAME"},
+			expectTargets:     [][]string{{"lb.example.net"}},
+		},
+		{
+			name:              "Multiple hostnames",
+			lbHostnames:       []string{"lb1.example.net", "lb2.example.net"},
+			expectRecordTypes: []string{"CNAME"},
+			expectTargets:     [][]string{{"lb1.example.net"}},
+		},
+		{
+			name:              "IP addresses take precedence over hostnames",
+			ips:               []net.IP{net.ParseIP("10.0.0.1")},
+			lbHostnames:       []string{"lb.example.net"},
+			expectRecordTypes: []string{"A"},
+			expectTargets:     [][]string{{"10.0.0.1"}},
+		},
+		{
+			name: "Neither IP addresses nor hostnames",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actuals := makeEndpoints("example.com", tc.ips, tc.lbHostnames, DefaultDNSTTL)
+			if len(actuals) != len(tc.expectRecordTypes) {
+				t.Fatalf("makeEndpoints() = %v, want %d items", actuals, len(tc.expectRecordTypes))
+			}
+			for i, actual := range actuals {
+				if actual["dnsName"] != "example.com" {
+					t.Errorf("makeEndpoints() dnsName = %v, want %v", actual["dnsName"], "example.com")
+				}
+				if actual["recordType"] != tc.expectRecordTypes[i] {
+					t.Errorf("makeEndpoints() recordType = %v, want %v", actual["recordType"], tc.expectRecordTypes[i])
+				}
+				if !slices.Equal(actual["targets"].([]string), tc.expectTargets[i]) {
+					t.Errorf("makeEndpoints() targets = %v, want %v", actual["targets"], tc.expectTargets[i])
+				}
+				if actual["recordTTL"] != int64(DefaultDNSTTL) {
+					t.Errorf("makeEndpoints() recordTTL = %v, want %v", actual["recordTTL"], DefaultDNSTTL)
+				}
+			}
+		})
+	}
+}
+
 func TestMakeDelegationEndpoint(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -1699,7 +2842,7 @@ func TestMakeDelegationEndpoint(t *testing.T) {
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			actuals := makeDelegationEndpoint(tc.hostname, tc.delegatedDomain)
+			actuals := makeDelegationEndpoint(tc.hostname, tc.delegatedDomain, DefaultDNSTTL)
 			if len(actuals) != 1 {
 				t.Errorf("HTTPProxyReconciler.makeDelegationEndpoint() = %v, want 1 item", len(actuals))
 			}
@@ -1718,6 +2861,197 @@ func TestMakeDelegationEndpoint(t *testing.T) {
 	}
 }
 
+func TestGetDNSTTL(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultDNSTTL int64
+		annotations   map[string]string
+		expectTTL     int64
+	}{
+		{
+			name:      "Default TTL",
+			expectTTL: DefaultDNSTTL,
+		},
+		{
+			name:          "Default TTL specified by option",
+			defaultDNSTTL: 300,
+			expectTTL:     300,
+		},
+		{
+			name:          "TTL specified by annotation",
+			defaultDNSTTL: 300,
+			annotations:   map[string]string{dnsTTLAnnotation: "60"},
+			expectTTL:     60,
+		},
+		{
+			name:          "Invalid TTL specified by annotation",
+			defaultDNSTTL: 300,
+			annotations:   map[string]string{dnsTTLAnnotation: "1m"},
+			expectTTL:     300,
+		},
+		{
+			name:          "Zero TTL specified by annotation",
+			defaultDNSTTL: 300,
+			annotations:   map[string]string{dnsTTLAnnotation: "0"},
+			expectTTL:     300,
+		},
+		{
+			name:          "Too large TTL specified by annotation",
+			defaultDNSTTL: 300,
+			annotations:   map[string]string{dnsTTLAnnotation: "2147483648"},
+			expectTTL:     300,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &HTTPProxyReconciler{
+				ReconcilerOptions: ReconcilerOptions{
+					DefaultDNSTTL: tc.defaultDNSTTL,
+				},
+			}
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			actual := r.getDNSTTL(hp, logr.Discard())
+			if actual != tc.expectTTL {
+				t.Errorf("HTTPProxyReconciler.getDNSTTL() = %v, want %v", actual, tc.expectTTL)
+			}
+		})
+	}
+}
+
+func TestParseCertificateDuration(t *testing.T) {
+	tests := []struct {
+		value     string
+		expect    time.Duration
+		expectErr bool
+	}{
+		{value: "2160h", expect: 2160 * time.Hour},
+		{value: "1h30m", expect: 90 * time.Minute},
+		{value: "0s", expectErr: true},
+		{value: "-1h", expectErr: true},
+		{value: "90d", expectErr: true},
+		{value: "", expectErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := parseCertificateDuration(tc.value)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("parseCertificateDuration(%q) error = %v, expectErr %v", tc.value, err, tc.expectErr)
+			}
+			if err == nil && actual.Duration != tc.expect {
+				t.Errorf("parseCertificateDuration(%q) = %v, want %v", tc.value, actual.Duration, tc.expect)
+			}
+		})
+	}
+}
+
+func TestExpandHostnames(t *testing.T) {
+	tests := []struct {
+		name        string
+		hostnames   []string
+		annotations map[string]string
+		expectDNS   []string
+		expectCert  []string
+	}{
+		{
+			name:       "no additional DNS names",
+			hostnames:  []string{dnsName},
+			expectDNS:  []string{dnsName},
+			expectCert: []string{dnsName},
+		},
+		{
+			name:      "additional DNS names",
+			hostnames: []string{dnsName},
+			annotations: map[string]string{
+				additionalDNSNamesAnnotation: "alias.example.com,*.example.com",
+			},
+			expectDNS:  []string{dnsName},
+			expectCert: []string{dnsName, "alias.example.com", "*.example.com"},
+		},
+		{
+			name:      "additional DNS names with DNS records",
+			hostnames: []string{dnsName},
+			annotations: map[string]string{
+				additionalDNSNamesAnnotation:   "alias.example.com,*.example.com",
+				additionalDNSRecordsAnnotation: "true",
+			},
+			expectDNS:  []string{dnsName, "alias.example.com", "*.example.com"},
+			expectCert: []string{dnsName, "alias.example.com", "*.example.com"},
+		},
+		{
+			name:      "duplicated and disallowed DNS names",
+			hostnames: []string{dnsName},
+			annotations: map[string]string{
+				additionalDNSNamesAnnotation: " test.example.com , , foo.example.org,Invalid.example.com",
+			},
+			expectDNS:  []string{dnsName},
+			expectCert: []string{dnsName},
+		},
+		{
+			name: "no hostnames",
+			annotations: map[string]string{
+				additionalDNSNamesAnnotation: "alias.example.com",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &HTTPProxyReconciler{
+				ReconcilerOptions: ReconcilerOptions{
+					AllowedAdditionalDNSDomains: []string{"example.com"},
+				},
+			}
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			dnsHostnames, certHostnames := r.expandHostnames(hp, tc.hostnames, logr.Discard())
+			if !slices.Equal(dnsHostnames, tc.expectDNS) {
+				t.Errorf("DNS hostnames = %v, want %v", dnsHostnames, tc.expectDNS)
+			}
+			if !slices.Equal(certHostnames, tc.expectCert) {
+				t.Errorf("Certificate hostnames = %v, want %v", certHostnames, tc.expectCert)
+			}
+		})
+	}
+}
+
+func TestValidateAdditionalDNSName(t *testing.T) {
+	r := &HTTPProxyReconciler{
+		ReconcilerOptions: ReconcilerOptions{
+			AllowedAdditionalDNSDomains: []string{"example.com", "example.net"},
+		},
+	}
+	tests := []struct {
+		name      string
+		expectErr bool
+	}{
+		{name: "example.com"},
+		{name: "foo.example.com"},
+		{name: "foo.bar.example.net"},
+		{name: "*.example.com"},
+		{name: "*.foo.example.com"},
+		{name: "example.org", expectErr: true},
+		{name: "badexample.com", expectErr: true},
+		{name: "*.com", expectErr: true},
+		{name: "foo.*.example.com", expectErr: true},
+		{name: "Foo.example.com", expectErr: true},
+		{name: "foo_bar.example.com", expectErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.validateAdditionalDNSName(tc.name)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("validateAdditionalDNSName(%q) error = %v, expectErr %v", tc.name, err, tc.expectErr)
+			}
+		})
+	}
+}
+
 func TestGetCertificateName(t *testing.T) {
 	tests := []struct {
 		name       string
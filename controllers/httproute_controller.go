@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// HTTPRouteReconciler reconciles a Gateway API HTTPRoute object.
+// It embeds HTTPProxyReconciler to generate the same child resources as HTTPProxy does.
+// FQDN conflicts, the status annotation and the status of Certificates are handled only for HTTPProxy.
+type HTTPRouteReconciler struct {
+	*HTTPProxyReconciler
+}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;update;patch
+
+// Reconcile creates/updates CRDs from given HTTPRoute
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := crlog.FromContext(ctx)
+	ctx = withAllowedNamespaces(ctx)
+
+	// Get HTTPRoute
+	route := new(gatewayv1.HTTPRoute)
+	err := r.Get(ctx, req.NamespacedName, route)
+	if k8serrors.IsNotFound(err) {
+		// the children still queued for the deleted HTTPRoute must not be created
+		route.Namespace, route.Name = req.Namespace, req.Name
+		r.forgetQueuedResources(route, nil, log)
+		r.warnings.forget(getOwnerKey(route))
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Error(err, "unable to get HTTPRoute resources")
+		return ctrl.Result{}, err
+	}
+
+	if route.DeletionTimestamp != nil {
+		if !controllerutil.ContainsFinalizer(route, finalizerName) {
+			return ctrl.Result{}, nil
+		}
+		// Clean up owned resources in other namespaces
+		return ctrl.Result{}, r.cleanupCrossNamespaceResources(ctx, route, log)
+	}
+
+	if route.Annotations[excludeAnnotation] == "true" {
+		recordSkip(ctx, route, skipReasonExcluded)
+		return ctrl.Result{}, r.cleanupAllResources(ctx, route, log)
+	}
+
+	// HTTPRoute has no ingress class field; only the annotations are checked.
+	if !r.watchesAllIngressClasses() {
+		if !r.matchIngressClassName(route.Annotations, "") {
+			recordSkip(ctx, route, skipReasonClassMismatch)
+			return ctrl.Result{}, r.cleanupAllResources(ctx, route, log)
+		}
+	}
+
+	if err := r.recordIgnoredAnnotations(ctx, route); err != nil {
+		log.Error(err, "unable to check annotations")
+		return ctrl.Result{}, err
+	}
+	hostnames := routeHostnames(route)
+	dnsHostnames, certHostnames := r.expandHostnames(route, hostnames, log)
+
+	rules, err := r.getDomainPolicyRules(ctx, route.Namespace)
+	if err != nil {
+		log.Error(err, "unable to get DomainPolicy")
+		return ctrl.Result{}, err
+	}
+	dnsHostnames, certHostnames = r.filterPermittedHostnames(route, rules, dnsHostnames, certHostnames, log)
+
+	if err := r.reconcileDNSEndpoint(ctx, route, dnsHostnames, log); err != nil {
+		log.Error(err, "unable to reconcile DNSEndpoint")
+		r.recordEvent(route, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile DNSEndpoint: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDelegationDNSEndpoint(ctx, route, certHostnames, rules, log); err != nil {
+		log.Error(err, "unable to reconcile delegation DNSEndpoint")
+		r.recordEvent(route, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile delegation DNSEndpoint: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	// TLS is configured on Gateway, not on HTTPRoute. The Secret is named after the Certificate
+	// so that Gateway listeners can refer to it.
+	secretName := getCertificateName(r.HTTPProxyReconciler, route)
+	if err := r.reconcileCertificate(ctx, route, certHostnames, secretName, rules, log); err != nil {
+		log.Error(err, "unable to reconcile Certificate")
+		r.recordEvent(route, nil, corev1.EventTypeWarning, eventReasonReconcileFailed, eventActionReconcile, "unable to reconcile Certificate: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	desired, err := r.desiredChildren(ctx, route, hostnames, secretName)
+	if err != nil {
+		log.Error(err, "unable to get desired child resources")
+		return ctrl.Result{}, err
+	}
+	desired, migrating, err := r.keepPreviousChildren(ctx, desired)
+	if err != nil {
+		log.Error(err, "unable to check the migration of name prefix")
+		return ctrl.Result{}, err
+	}
+	if err := r.cleanupUnusedResources(ctx, route, desired, log); err != nil {
+		log.Error(err, "unable to clean up unused resources")
+		return ctrl.Result{}, err
+	}
+	if migrating {
+		return ctrl.Result{RequeueAfter: migrationRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// The certificate apply worker, if any, is started by HTTPProxyReconciler.SetupWithManager.
+func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	listRoutes := func(ctx context.Context, a client.Object) []reconcile.Request {
+		if !r.isWatchedService(a) {
+			return nil
+		}
+
+		var routeList gatewayv1.HTTPRouteList
+		err := r.List(ctx, &routeList)
+		if err != nil {
+			r.Log.Error(err, "listing HTTPRoute failed")
+			return nil
+		}
+
+		// enqueue only HTTPRoutes that use the changed Service
+		var requests []reconcile.Request
+		for i := range routeList.Items {
+			route := &routeList.Items[i]
+			if key, err := r.getServiceKey(route); err != nil || key != client.ObjectKeyFromObject(a) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      route.Name,
+				Namespace: route.Namespace,
+			}})
+		}
+		return requests
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1.HTTPRoute{}, builder.WithPredicates(specOrMetadataChanged)).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(listRoutes), builder.WithPredicates(ignoreInitialCreateEvent))
+
+	// requeue HTTPRoute when applying its children from the queues of the workers fails
+	if certWorker, ok := r.CertApplier.(ApplyWorker[*cmv1.Certificate]); ok {
+		b = b.WatchesRawSource(source.Channel(certWorker.GetRouteRetryChannel(), &handler.TypedEnqueueRequestForObject[*gatewayv1.HTTPRoute]{}))
+	}
+	if dnsWorker, ok := r.DNSApplier.(ApplyWorker[*unstructured.Unstructured]); ok {
+		b = b.WatchesRawSource(source.Channel(dnsWorker.GetRouteRetryChannel(), &handler.TypedEnqueueRequestForObject[*gatewayv1.HTTPRoute]{}))
+	}
+
+	b = r.watchDomainPolicies(b, func() client.ObjectList { return &gatewayv1.HTTPRouteList{} })
+	b = r.watchNamespaceSelectors(b, func() client.ObjectList { return &gatewayv1.HTTPRouteList{} })
+
+	return r.ownChildren(b).Complete(r)
+}
+
+// routeHostnames returns the hostnames for which child resources are generated from HTTPRoute.
+func routeHostnames(route *gatewayv1.HTTPRoute) []string {
+	hostnames := make([]string, 0, len(route.Spec.Hostnames))
+	for _, h := range route.Spec.Hostnames {
+		if h == "" {
+			continue
+		}
+		hostnames = append(hostnames, string(h))
+	}
+	return hostnames
+}
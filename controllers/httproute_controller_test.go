@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	wildcardDNSName = "*.example.com"
+)
+
+func testHTTPRouteReconcile() {
+	var ns string
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		n := &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{
+				GenerateName: testNamespacePrefix,
+			},
+		}
+		Expect(k8sClient.Create(ctx, n)).To(Succeed())
+		ns = n.Name
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.DeleteAllOf(ctx, &gatewayv1.HTTPRoute{}, client.InNamespace(ns))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, certificate(), client.InNamespace(ns))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, dnsEndpoint(), client.InNamespace(ns))).To(Succeed())
+
+		n := &corev1.Namespace{ObjectMeta: ctrl.ObjectMeta{Name: ns}}
+		_ = k8sClient.Delete(ctx, n)
+	})
+
+	It("should create DNSEndpoint, delegation DNSEndpoint and Certificate", func() {
+		scm, mgr := setupManager()
+
+		prefix := "test-"
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			Prefix:                 prefix,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+			WatchHTTPRoute:         true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPRoute")
+		routeKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(ctx, newDummyHTTPRoute(routeKey, dnsName, wildcardDNSName))).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint with prefixed name")
+		de := dnsEndpoint()
+		objKey := client.ObjectKey{
+			Name:      prefix + httpRouteNamePrefix + routeKey.Name,
+			Namespace: routeKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoints := deSpec["endpoints"].([]interface{})
+		Expect(endPoints).Should(HaveLen(2))
+		Expect(endPoints[0].(map[string]interface{})["dnsName"]).Should(Equal(dnsName))
+		Expect(endPoints[0].(map[string]interface{})["targets"]).Should(Equal([]interface{}{dummyLoadBalancerIP}))
+		Expect(endPoints[1].(map[string]interface{})["dnsName"]).Should(Equal(wildcardDNSName))
+
+		By("getting delegation DNSEndpoint")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{
+			Name:      prefix + httpRouteNamePrefix + routeKey.Name + "-delegation",
+			Namespace: routeKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		ddeSpec := dde.UnstructuredContent()["spec"].(map[string]interface{})
+		dEndPoints := ddeSpec["endpoints"].([]interface{})
+		Expect(dEndPoints).Should(HaveLen(2))
+		Expect(dEndPoints[0].(map[string]interface{})["dnsName"]).Should(Equal("_acme-challenge." + dnsName))
+		Expect(dEndPoints[1].(map[string]interface{})["dnsName"]).Should(Equal("_acme-challenge.example.com"))
+
+		By("getting Certificate with prefixed name")
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(ctx, objKey, crt)
+		}).Should(Succeed())
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName, wildcardDNSName}))
+		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+		Expect(crtSpec["secretName"]).Should(Equal(prefix + httpRouteNamePrefix + routeKey.Name))
+	})
+
+	It("should not create Certificate if \"kubernetes.io/tls-acme\" is not \"true\"", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+			WatchHTTPRoute:    true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPRoute without tls-acme annotation")
+		routeKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		route := newDummyHTTPRoute(routeKey, dnsName)
+		delete(route.Annotations, testACMETLSAnnotation)
+		Expect(k8sClient.Create(ctx, route)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		objKey := client.ObjectKey{Name: httpRouteNamePrefix + routeKey.Name, Namespace: routeKey.Namespace}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, objKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that Certificate does not exist")
+		crtList := certificateList()
+		Expect(k8sClient.List(ctx, crtList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(crtList.Items).Should(BeEmpty())
+	})
+
+	It("should not watch HTTPRoute unless enabled", func() {
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPRoute")
+		routeKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(ctx, newDummyHTTPRoute(routeKey, dnsName))).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint does not exist")
+		objKey := client.ObjectKey{Name: httpRouteNamePrefix + routeKey.Name, Namespace: routeKey.Namespace}
+		Consistently(func() error {
+			return k8sClient.Get(ctx, objKey, dnsEndpoint())
+		}, 3*time.Second).ShouldNot(Succeed())
+	})
+}
+
+func newDummyHTTPRoute(routeKey client.ObjectKey, hostnames ...string) *gatewayv1.HTTPRoute {
+	route := &gatewayv1.HTTPRoute{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: routeKey.Namespace,
+			Name:      routeKey.Name,
+			Annotations: map[string]string{
+				testACMETLSAnnotation: "true",
+			},
+		},
+	}
+	for _, h := range hostnames {
+		route.Spec.Hostnames = append(route.Spec.Hostnames, gatewayv1.Hostname(h))
+	}
+	return route
+}
+
+func TestRouteChildNames(t *testing.T) {
+	r := &HTTPProxyReconciler{ReconcilerOptions: ReconcilerOptions{Prefix: "prefix-"}}
+	key := client.ObjectKey{Namespace: "default", Name: "foo"}
+
+	// HTTPRoute and HTTPProxy of the same name must not share the child resources
+	route := newDummyHTTPRoute(key, dnsName)
+	hp := newDummyHTTPProxy(key)
+	if got := getCertificateName(r, route); got != "prefix-httproute-foo" {
+		t.Errorf("getCertificateName() = %q, want prefix-httproute-foo", got)
+	}
+	if got := getDNSEndpointName(r, route); got != "prefix-httproute-foo" {
+		t.Errorf("getDNSEndpointName() = %q, want prefix-httproute-foo", got)
+	}
+	if getCertificateName(r, route) == getCertificateName(r, hp) || getDNSEndpointName(r, route) == getDNSEndpointName(r, hp) {
+		t.Error("HTTPRoute and HTTPProxy of the same name have the same child resources")
+	}
+
+	route.Annotations[issuerNamespaceAnnotation] = "issuer"
+	route.Annotations[dnsNamespaceAnnotation] = "dns"
+	if got := getCertificateName(r, route); got != "prefix-httproute-default-foo" {
+		t.Errorf("getCertificateName() in another namespace = %q, want prefix-httproute-default-foo", got)
+	}
+	if got := getDNSEndpointName(r, route); got != "prefix-httproute-default-foo" {
+		t.Errorf("getDNSEndpointName() in another namespace = %q, want prefix-httproute-default-foo", got)
+	}
+}
+
+func TestRouteHostnames(t *testing.T) {
+	route := &gatewayv1.HTTPRoute{
+		Spec: gatewayv1.HTTPRouteSpec{
+			Hostnames: []gatewayv1.Hostname{"foo.example.com", "", "*.example.com"},
+		},
+	}
+	got := routeHostnames(route)
+	want := []string{"foo.example.com", "*.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("routeHostnames() = %v, want %v", got, want)
+	}
+}
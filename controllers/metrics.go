@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// skipReason is the reason why Reconcile does not generate some or all of the child resources of an owner.
+type skipReason string
+
+const (
+	// skipReasonExcluded is for owners annotated with excludeAnnotation
+	skipReasonExcluded skipReason = "excluded"
+	// skipReasonClassMismatch is for owners of unwatched ingress classes
+	skipReasonClassMismatch skipReason = "class_mismatch"
+	// skipReasonNoIssuer is for owners requesting Certificates without issuers
+	skipReasonNoIssuer skipReason = "no_issuer"
+	// skipReasonNoLoadBalancerIP is for owners whose load balancer has no addresses yet
+	skipReasonNoLoadBalancerIP skipReason = "no_lb_ip"
+	// skipReasonDisallowedNamespace is for owners requesting child resources in disallowed namespaces
+	skipReasonDisallowedNamespace skipReason = "disallowed_namespace"
+	// skipReasonFQDNConflict is for HTTPProxies whose FQDN is claimed by another HTTPProxy
+	skipReasonFQDNConflict skipReason = "fqdn_conflict"
+)
+
+var (
+	// fqdnConflicts reports HTTPProxies whose child resources are not generated because their FQDN is claimed by another HTTPProxy.
+	fqdnConflicts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "contour_plus_fqdn_conflicts",
+			Help: "HTTPProxies whose child resources are not generated because their FQDN is claimed by another HTTPProxy.",
+		},
+		[]string{"namespace", "name", "fqdn"},
+	)
+
+	// reconcileSkippedTotal counts the reconciliations that skip child resources for each skipReason.
+	reconcileSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "contour_plus_reconcile_skipped_total",
+			Help: "Total number of reconciliations that skipped generating child resources.",
+		},
+		[]string{"kind", "reason"},
+	)
+
+	// certificateReadySeconds observes the time from the creation of HTTPProxies to the first issuance of their Certificates.
+	certificateReadySeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "contour_plus_certificate_ready_seconds",
+			Help:    "Time from the creation of HTTPProxy to the first Ready condition of its Certificate in seconds.",
+			Buckets: prometheus.ExponentialBuckets(10, 2, 12),
+		},
+	)
+
+	// certificateExpiryTimestamp reports the expiry of the Certificates of HTTPProxies.
+	certificateExpiryTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "contour_plus_certificate_expiry_timestamp_seconds",
+			Help: "Expiry of the Certificate of HTTPProxy in seconds since the Unix epoch.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// certificateReady reports whether the Certificates of HTTPProxies are ready.
+	certificateReady = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "contour_plus_certificate_ready",
+			Help: "Whether the Certificate of HTTPProxy is ready (1) or not (0).",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// dryRunChangesTotal counts the changes that would be made if contour-plus were not in dry-run mode.
+	dryRunChangesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "contour_plus_dry_run_changes_total",
+			Help: "Total number of changes that would be made to resources in dry-run mode.",
+		},
+		[]string{"kind", "operation"},
+	)
+
+	// garbageCollectedTotal counts the child resources deleted because their owners do not exist.
+	garbageCollectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "contour_plus_garbage_collected_total",
+			Help: "Total number of child resources deleted because their owners do not exist.",
+		},
+		[]string{"kind"},
+	)
+
+	managedResourcesDesc = prometheus.NewDesc(
+		"contour_plus_managed_resources",
+		"Number of child resources managed by contour-plus.",
+		[]string{"namespace", "kind"},
+		nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(fqdnConflicts, reconcileSkippedTotal, certificateReadySeconds, certificateExpiryTimestamp, certificateReady, dryRunChangesTotal, garbageCollectedTotal)
+}
+
+// setFQDNConflictMetric updates fqdnConflicts for the HTTPProxy. An empty fqdn clears the metric.
+func setFQDNConflictMetric(key client.ObjectKey, fqdn string) {
+	fqdnConflicts.DeletePartialMatch(prometheus.Labels{"namespace": key.Namespace, "name": key.Name})
+	if fqdn != "" {
+		fqdnConflicts.WithLabelValues(key.Namespace, key.Name, fqdn).Set(1)
+	}
+}
+
+// setCertificateStatusMetrics updates certificateExpiryTimestamp and certificateReady for the HTTPProxy.
+// A nil cert clears the metrics.
+func setCertificateStatusMetrics(key client.ObjectKey, cert *cmv1.Certificate) {
+	if cert == nil {
+		certificateExpiryTimestamp.DeleteLabelValues(key.Namespace, key.Name)
+		certificateReady.DeleteLabelValues(key.Namespace, key.Name)
+		return
+	}
+	if cert.Status.NotAfter != nil {
+		certificateExpiryTimestamp.WithLabelValues(key.Namespace, key.Name).Set(float64(cert.Status.NotAfter.Unix()))
+	} else {
+		certificateExpiryTimestamp.DeleteLabelValues(key.Namespace, key.Name)
+	}
+	var ready float64
+	if isCertificateReady(cert) {
+		ready = 1
+	}
+	certificateReady.WithLabelValues(key.Namespace, key.Name).Set(ready)
+}
+
+// recordSkip increments reconcileSkippedTotal for the owner and sets the skip reason of the ownerStatus in ctx, if any.
+func recordSkip(ctx context.Context, owner client.Object, reason skipReason) {
+	setSkipReason(ctx, reason)
+	kind := HTTPProxyKind
+	if _, ok := owner.(*gatewayv1.HTTPRoute); ok {
+		kind = HTTPRouteKind
+	}
+	reconcileSkippedTotal.WithLabelValues(kind, string(reason)).Inc()
+}
+
+// managedResourcesCollector implements prometheus.Collector to report the number of the child resources
+// in the informer cache for each namespace and kind. The resources are counted when scraped
+// so that resources deleted by others are not left in the metric.
+type managedResourcesCollector struct {
+	r *HTTPProxyReconciler
+}
+
+func (c managedResourcesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedResourcesDesc
+}
+
+func (c managedResourcesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	for kind, list := range c.r.childLists() {
+		if err := c.r.ChildReader.List(ctx, list); err != nil {
+			c.r.Log.Error(err, "unable to list "+kind)
+			continue
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			c.r.Log.Error(err, "unable to extract "+kind)
+			continue
+		}
+		counts := make(map[string]int)
+		for _, item := range items {
+			obj := item.(client.Object)
+			if len(indexOwner(obj)) == 0 {
+				continue
+			}
+			if !c.r.watchesAllIngressClasses() && !c.r.isWatchedIngressClass(obj.GetAnnotations()[ingressClassOwnerAnnotation]) {
+				continue
+			}
+			counts[obj.GetNamespace()]++
+		}
+		for ns, count := range counts {
+			ch <- prometheus.MustNewConstMetric(managedResourcesDesc, prometheus.GaugeValue, float64(count), ns, kind)
+		}
+	}
+}
+
+// registerManagedResourcesCollector registers managedResourcesCollector for the reconciler.
+func (r *HTTPProxyReconciler) registerManagedResourcesCollector(registry prometheus.Registerer) error {
+	// see CertificateApplyWorker.RegisterMetrics for why MustRegister is not used
+	if err := registry.Register(managedResourcesCollector{r: r}); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeCertificateReady returns the handler for the Certificate informer to observe certificateReadySeconds.
+// Only the first issuance of the Certificates owned by HTTPProxies is observed; renewals and re-issuances are not.
+func observeCertificateReady(reader client.Reader, log logr.Logger) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCert, ok := oldObj.(*cmv1.Certificate)
+			if !ok {
+				return
+			}
+			newCert, ok := newObj.(*cmv1.Certificate)
+			if !ok {
+				return
+			}
+			if isCertificateReady(oldCert) || !isCertificateReady(newCert) {
+				return
+			}
+			if newCert.Status.Revision != nil && *newCert.Status.Revision > 1 {
+				return
+			}
+			owner, err := ownerFromKey(newCert.Annotations[ownerAnnotation])
+			if err != nil {
+				return
+			}
+			hp, ok := owner.(*projectcontourv1.HTTPProxy)
+			if !ok || hp.Name == "" {
+				return
+			}
+			if err := reader.Get(context.Background(), client.ObjectKeyFromObject(hp), hp); err != nil {
+				log.Error(err, "unable to get the owner of Certificate", "certificate", client.ObjectKeyFromObject(newCert))
+				return
+			}
+			readyTime := certificateCondition(newCert, cmv1.CertificateConditionReady).LastTransitionTime
+			if readyTime == nil || readyTime.Before(&hp.CreationTimestamp) {
+				return
+			}
+			certificateReadySeconds.Observe(readyTime.Sub(hp.CreationTimestamp.Time).Seconds())
+		},
+	}
+}
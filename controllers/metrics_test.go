@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestManagedResourcesCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := cmv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newCert := func(ns, name string, annotations map[string]string) *cmv1.Certificate {
+		return &cmv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{Namespace: ns, Name: name, Annotations: annotations},
+		}
+	}
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			newCert("default", "foo", map[string]string{ownerAnnotation: "default/foo"}),
+			newCert("default", "bar", map[string]string{ownerAnnotation: "default/bar", ingressClassOwnerAnnotation: "other"}),
+			newCert("default", "unmanaged", nil),
+			newCert("cert", "baz", map[string]string{ownerAnnotation: "default/baz", ingressClassOwnerAnnotation: "class-name"}),
+		).
+		Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		ChildReader: c,
+		Log:         logr.Discard(),
+	}
+
+	if n := testutil.CollectAndCount(managedResourcesCollector{r: r}); n != 0 {
+		t.Errorf("collected %d metrics, want 0 if no child resources are created", n)
+	}
+
+	// TLSCertificateDelegations are listed with Certificates, but they are not registered to the scheme
+	r.CreateCertificate = true
+	r.ChildReader = certificateOnlyReader{c}
+	expected := `
+# HELP contour_plus_managed_resources Number of child resources managed by contour-plus.
+# TYPE contour_plus_managed_resources gauge
+contour_plus_managed_resources{kind="Certificate",namespace="cert"} 1
+contour_plus_managed_resources{kind="Certificate",namespace="default"} 2
+`
+	if err := testutil.CollectAndCompare(managedResourcesCollector{r: r}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	r.IngressClassName = "class-name"
+	expected = `
+# HELP contour_plus_managed_resources Number of child resources managed by contour-plus.
+# TYPE contour_plus_managed_resources gauge
+contour_plus_managed_resources{kind="Certificate",namespace="cert"} 1
+`
+	if err := testutil.CollectAndCompare(managedResourcesCollector{r: r}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+// certificateOnlyReader returns empty lists for the kinds other than Certificate.
+type certificateOnlyReader struct {
+	client.Reader
+}
+
+func (r certificateOnlyReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*cmv1.CertificateList); !ok {
+		return nil
+	}
+	return r.Reader.List(ctx, list, opts...)
+}
+
+func TestObserveCertificateReady(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := projectcontourv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Now().Add(-time.Minute).Truncate(time.Second)
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.CreationTimestamp = v1.NewTime(created)
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(hp).
+		Build()
+	handler := observeCertificateReady(c, logr.Discard())
+
+	newCert := func(owner string, status cmmeta.ConditionStatus, revision int) *cmv1.Certificate {
+		return &cmv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "foo",
+				Annotations: map[string]string{ownerAnnotation: owner},
+			},
+			Status: cmv1.CertificateStatus{
+				Conditions: []cmv1.CertificateCondition{
+					{
+						Type:               cmv1.CertificateConditionReady,
+						Status:             status,
+						LastTransitionTime: ptr.To(v1.NewTime(created.Add(30 * time.Second))),
+					},
+				},
+				Revision: ptr.To(revision),
+			},
+		}
+	}
+
+	sampleCount := func() uint64 {
+		var m dto.Metric
+		if err := certificateReadySeconds.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+	before := sampleCount()
+
+	// not observed: the owner is HTTPRoute, the Certificate is renewed, or the Certificate is kept Ready
+	handler.OnUpdate(newCert("HTTPRoute/default/foo", cmmeta.ConditionFalse, 1), newCert("HTTPRoute/default/foo", cmmeta.ConditionTrue, 1))
+	handler.OnUpdate(newCert("default/foo", cmmeta.ConditionFalse, 2), newCert("default/foo", cmmeta.ConditionTrue, 2))
+	handler.OnUpdate(newCert("default/foo", cmmeta.ConditionTrue, 1), newCert("default/foo", cmmeta.ConditionTrue, 1))
+	if n := sampleCount(); n != before {
+		t.Errorf("sample count = %d, want %d", n, before)
+	}
+
+	handler.OnUpdate(newCert("default/foo", cmmeta.ConditionFalse, 1), newCert("default/foo", cmmeta.ConditionTrue, 1))
+	if n := sampleCount(); n != before+1 {
+		t.Errorf("sample count = %d, want %d", n, before+1)
+	}
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;patch
+
+// migrationRequeueInterval is the interval of checking whether the child resources replacing
+// those named with PreviousPrefix are ready, because the status changes of them do not trigger reconciliation.
+const migrationRequeueInterval = 30 * time.Second
+
+// previousName returns name of a child resource with PreviousPrefix instead of Prefix.
+func (r *HTTPProxyReconciler) previousName(name string) string {
+	return r.PreviousPrefix + strings.TrimPrefix(name, r.Prefix)
+}
+
+// getAdoptedSecretName returns the name of the Secret that the Certificate of the owner adopts from the Certificate
+// named with PreviousPrefix, or an empty string if none. secretName is the name of the Secret without adoption.
+//
+// A Secret is adopted only if the Certificate does not exist yet and the previous one is owned by the same owner,
+// so that the certificate in the Secret is reused instead of issued again. The adopted Secret is recorded
+// in adoptedSecretAnnotation of the Certificate to be kept after the previous Certificate is deleted.
+// If the previous Certificate has been deleted by handOverSecret and the Certificate is deleted afterwards,
+// the Secret is found by its name and the certificate-name annotation of cert-manager.
+func (r *HTTPProxyReconciler) getAdoptedSecretName(ctx context.Context, owner client.Object, secretName string) (string, error) {
+	if !r.CreateCertificate || secretName == "" {
+		return "", nil
+	}
+
+	namespace, err := r.getCertificateNamespace(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	key := client.ObjectKey{Namespace: namespace, Name: getCertificateName(r, owner)}
+	current := new(cmv1.Certificate)
+	err = r.ChildReader.Get(ctx, key, current)
+	if err == nil {
+		return current.Annotations[adoptedSecretAnnotation], nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	if !r.MigrateNamePrefix {
+		return "", nil
+	}
+
+	certName := key.Name
+	key.Name = r.previousName(key.Name)
+	previous := new(cmv1.Certificate)
+	err = r.ChildReader.Get(ctx, key, previous)
+	if k8serrors.IsNotFound(err) {
+		previousSecretName := r.previousName(secretName)
+		if previousSecretName == secretName {
+			return "", nil
+		}
+		secret, err := r.getCertificateSecret(ctx, key.Namespace, previousSecretName, key.Name, certName)
+		if err != nil || secret == nil {
+			return "", err
+		}
+		return previousSecretName, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	owners := indexOwner(previous)
+	if len(owners) == 0 || owners[0] != getOwnerKey(owner) || previous.Spec.SecretName == secretName {
+		return "", nil
+	}
+	return previous.Spec.SecretName, nil
+}
+
+// handOverSecret hands the Secret of the Certificate named with PreviousPrefix over to cert once cert is created.
+// The previous Certificate owned by the same owner is deleted with its Secret orphaned, and then the certificate-name
+// annotation of the Secret is changed to the name of cert. Otherwise, both Certificates would refer to the Secret, and
+// cert-manager would issue the certificate again because the Secret is annotated with the name of the other one.
+// The previous Certificate is kept while cert is queued by CertApplier, so that the Secret is always managed by
+// one of them; the Secret is handed over by a later reconciliation in that case.
+// cert must be the applied Certificate that refers to the Secret adopted by getAdoptedSecretName.
+func (r *HTTPProxyReconciler) handOverSecret(ctx context.Context, owner client.Object, cert *cmv1.Certificate, log logr.Logger) error {
+	if !r.MigrateNamePrefix {
+		return nil
+	}
+	// cert has no resourceVersion if it is queued, and it may exist from a previous reconciliation
+	if cert.GetResourceVersion() == "" {
+		current := new(cmv1.Certificate)
+		err := r.ChildReader.Get(ctx, client.ObjectKeyFromObject(cert), current)
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cert = current
+	}
+
+	previousName := r.previousName(cert.Name)
+	previous := new(cmv1.Certificate)
+	err := r.ChildReader.Get(ctx, client.ObjectKey{Namespace: cert.Namespace, Name: previousName}, previous)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && previous.DeletionTimestamp == nil && previous.Spec.SecretName == cert.Spec.SecretName {
+		if owners := indexOwner(previous); len(owners) == 0 || owners[0] != getOwnerKey(owner) {
+			return nil
+		}
+		err := r.Delete(ctx, previous, client.PropagationPolicy(metav1.DeletePropagationOrphan), client.Preconditions{UID: &previous.UID})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		log.Info("deleted previous Certificate to hand over its Secret", "name", previousName, "namespace", cert.Namespace, "secret", cert.Spec.SecretName)
+	}
+
+	// the Secret does not exist if the previous Certificate has never been issued
+	secret, err := r.getCertificateSecret(ctx, cert.Namespace, cert.Spec.SecretName, previousName)
+	if err != nil || secret == nil {
+		return err
+	}
+	patch := client.MergeFromWithOptions(secret.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	secret.Annotations[cmv1.CertificateNameKey] = cert.Name
+	if err := r.Patch(ctx, secret, patch); err != nil {
+		return err
+	}
+	log.Info("handed over Secret", "name", cert.Spec.SecretName, "namespace", cert.Namespace, "certificate", cert.Name)
+	return nil
+}
+
+// getCertificateSecret returns the metadata of the Secret if it is annotated to be issued for one of certNames, or nil otherwise.
+// The Secret is read from the API server because Secrets are not cached.
+func (r *HTTPProxyReconciler) getCertificateSecret(ctx context.Context, namespace, name string, certNames ...string) (*metav1.PartialObjectMetadata, error) {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(certNames, secret.Annotations[cmv1.CertificateNameKey]) {
+		return nil, nil
+	}
+	return secret, nil
+}
+
+// setAdoptedSecret makes cert store the certificate in the adopted Secret, and records it in adoptedSecretAnnotation.
+func setAdoptedSecret(cert *cmv1.Certificate, adoptedSecretName string) {
+	cert.Spec.SecretName = adoptedSecretName
+	// the annotations are shared with the secret template
+	annotations := maps.Clone(cert.GetAnnotations())
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[adoptedSecretAnnotation] = adoptedSecretName
+	cert.SetAnnotations(annotations)
+}
+
+// keepPreviousChildren returns desired with the child resources named with PreviousPrefix added
+// unless all the desired ones are ready, so that cleanupUnusedResources deletes the previous ones
+// only after they are replaced. It also returns true if the previous ones are kept.
+func (r *HTTPProxyReconciler) keepPreviousChildren(ctx context.Context, desired map[childKey]bool) (map[childKey]bool, bool, error) {
+	if !r.MigrateNamePrefix {
+		return desired, false, nil
+	}
+
+	ready := true
+	for key := range desired {
+		ok, err := r.isChildReady(ctx, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			ready = false
+			break
+		}
+	}
+	if ready {
+		return desired, false, nil
+	}
+
+	kept := maps.Clone(desired)
+	for key := range desired {
+		key.Name = r.previousName(key.Name)
+		kept[key] = true
+	}
+	return kept, true, nil
+}
+
+// isChildReady returns true if the child resource exists, and is Ready in the case of Certificate.
+func (r *HTTPProxyReconciler) isChildReady(ctx context.Context, key childKey) (bool, error) {
+	var obj client.Object
+	switch key.Kind {
+	case CertificateKind:
+		obj = new(cmv1.Certificate)
+	case DNSEndpointKind:
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		obj = u
+	default:
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(contourGroupVersion.WithKind(key.Kind))
+		obj = u
+	}
+
+	err := r.ChildReader.Get(ctx, key.ObjectKey, obj)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if cert, ok := obj.(*cmv1.Certificate); ok {
+		return isCertificateReady(cert), nil
+	}
+	return true, nil
+}
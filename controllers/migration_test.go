@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNamePrefixMigration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[issuerNamespaceAnnotation] = "certs"
+	previous := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "certs",
+			Name:        "old-default-foo",
+			Annotations: map[string]string{ownerAnnotation: "default/foo"},
+		},
+		Spec: cmv1.CertificateSpec{DNSNames: []string{dnsName}, SecretName: "old-default-foo"},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(hp, previous).Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		Scheme:      scheme,
+		ChildReader: c,
+		ReconcilerOptions: ReconcilerOptions{
+			Prefix:                  "new-",
+			PreviousPrefix:          "old-",
+			MigrateNamePrefix:       true,
+			AllowedIssuerNamespaces: []string{"certs"},
+			CreateCertificate:       true,
+		},
+	}
+	ctx := context.Background()
+
+	// the Secret of the previous Certificate is adopted until the Certificate is created
+	adopted, err := r.getAdoptedSecretName(ctx, hp, getCertificateSecretName(r, hp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted != "old-default-foo" {
+		t.Errorf("adopted Secret = %q, want old-default-foo", adopted)
+	}
+	r.MigrateNamePrefix = false
+	adopted, err = r.getAdoptedSecretName(ctx, hp, getCertificateSecretName(r, hp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted != "" {
+		t.Errorf("adopted Secret without migration = %q, want none", adopted)
+	}
+	r.MigrateNamePrefix = true
+
+	// the previous children are kept until the new ones are ready
+	desired, err := r.desiredChildren(ctx, hp, []string{dnsName}, getCertificateSecretName(r, hp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, migrating, err := r.keepPreviousChildren(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for key := range kept {
+		got = append(got, describeChild(key))
+	}
+	slices.Sort(got)
+	expect := []string{
+		"Certificate certs/new-default-foo",
+		"Certificate certs/old-default-foo",
+		"TLSCertificateDelegation certs/new-default-foo",
+		"TLSCertificateDelegation certs/old-default-foo",
+	}
+	if !migrating || !slices.Equal(got, expect) {
+		t.Errorf("keepPreviousChildren() = %v, %v, want %v, true", got, migrating, expect)
+	}
+
+	cert := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "certs",
+			Name:        "new-default-foo",
+			Annotations: map[string]string{ownerAnnotation: "default/foo", adoptedSecretAnnotation: "old-default-foo"},
+		},
+		Spec: cmv1.CertificateSpec{DNSNames: []string{dnsName}, SecretName: "old-default-foo"},
+	}
+	if err := c.Create(ctx, cert); err != nil {
+		t.Fatal(err)
+	}
+	tcd := r.buildTLSCertificateDelegation(hp, "certs", "old-default-foo")
+	if err := c.Create(ctx, tcd); err != nil {
+		t.Fatal(err)
+	}
+	if _, migrating, err = r.keepPreviousChildren(ctx, desired); err != nil {
+		t.Fatal(err)
+	}
+	if !migrating {
+		t.Error("previous children are not kept while the Certificate is not ready")
+	}
+
+	cert.Status.Conditions = []cmv1.CertificateCondition{{Type: cmv1.CertificateConditionReady, Status: cmmeta.ConditionTrue}}
+	if err := c.Update(ctx, cert); err != nil {
+		t.Fatal(err)
+	}
+	kept, migrating, err = r.keepPreviousChildren(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrating || !maps.Equal(kept, desired) {
+		t.Errorf("keepPreviousChildren() after migration = %v, %v, want desired only", kept, migrating)
+	}
+
+	// the adopted Secret is kept after the previous Certificate is deleted and the migration is disabled
+	if err := c.Delete(ctx, previous); err != nil {
+		t.Fatal(err)
+	}
+	r.MigrateNamePrefix = false
+	adopted, err = r.getAdoptedSecretName(ctx, hp, getCertificateSecretName(r, hp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted != "old-default-foo" {
+		t.Errorf("adopted Secret after migration = %q, want old-default-foo", adopted)
+	}
+}
+
+func TestSecretHandover(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	hp.Annotations[issuerNamespaceAnnotation] = "certs"
+	previous := &cmv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "certs",
+			Name:        "old-default-foo",
+			Annotations: map[string]string{ownerAnnotation: "default/foo"},
+		},
+		Spec: cmv1.CertificateSpec{DNSNames: []string{dnsName}, SecretName: "old-default-foo"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "certs",
+			Name:        "old-default-foo",
+			Annotations: map[string]string{cmv1.CertificateNameKey: "old-default-foo"},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(hp, previous, secret).Build()
+	r := &HTTPProxyReconciler{
+		Client:      c,
+		Scheme:      scheme,
+		ChildReader: c,
+		APIReader:   c,
+		CertApplier: NewCertificateApplier(c),
+		ReconcilerOptions: ReconcilerOptions{
+			Prefix:                  "new-",
+			PreviousPrefix:          "old-",
+			MigrateNamePrefix:       true,
+			AllowedIssuerNamespaces: []string{"certs"},
+			CreateCertificate:       true,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       ClusterIssuerKind,
+		},
+	}
+	ctx := context.Background()
+
+	// the previous Certificate keeps the Secret while the new one is queued
+	worker := NewCertificateApplyWorker(c, nil, ReconcilerOptions{
+		CertificateApplyLimit:          1,
+		CertificateApplyRetryBaseDelay: time.Millisecond,
+		CertificateApplyRetryMaxDelay:  time.Millisecond,
+	})
+	defer worker.workqueue.ShutDown()
+	r.CertApplier = worker
+	if err := r.reconcileCertificate(ctx, hp, []string{dnsName}, getCertificateSecretName(r, hp), nil, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := worker.manifests[client.ObjectKey{Namespace: "certs", Name: "new-default-foo"}]; !ok {
+		t.Fatal("new Certificate is not queued")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(previous), new(cmv1.Certificate)); err != nil {
+		t.Errorf("previous Certificate is deleted while the new one is queued: %v", err)
+	}
+	got := new(corev1.Secret)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), got); err != nil {
+		t.Fatal(err)
+	}
+	if name := got.Annotations[cmv1.CertificateNameKey]; name != "old-default-foo" {
+		t.Errorf("Secret is annotated with Certificate %q while the new one is queued, want old-default-foo", name)
+	}
+
+	r.CertApplier = NewCertificateApplier(c)
+	if err := r.reconcileCertificate(ctx, hp, []string{dnsName}, getCertificateSecretName(r, hp), nil, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the previous Certificate is deleted after the new one is created with the Secret handed over
+	if err := c.Get(ctx, client.ObjectKeyFromObject(previous), new(cmv1.Certificate)); !k8serrors.IsNotFound(err) {
+		t.Errorf("previous Certificate is not deleted: %v", err)
+	}
+	got = new(corev1.Secret)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), got); err != nil {
+		t.Fatal(err)
+	}
+	if name := got.Annotations[cmv1.CertificateNameKey]; name != "new-default-foo" {
+		t.Errorf("Secret is annotated with Certificate %q, want new-default-foo", name)
+	}
+	var certList cmv1.CertificateList
+	if err := c.List(ctx, &certList); err != nil {
+		t.Fatal(err)
+	}
+	var referring []string
+	for _, cert := range certList.Items {
+		if cert.Spec.SecretName == secret.Name {
+			referring = append(referring, cert.Name)
+		}
+	}
+	if !slices.Equal(referring, []string{"new-default-foo"}) {
+		t.Errorf("Certificates referring to the Secret = %v, want [new-default-foo]", referring)
+	}
+
+	// the Secret is still adopted if the new Certificate has not been created after the handover, e.g. queued
+	if err := c.DeleteAllOf(ctx, &cmv1.Certificate{}, client.InNamespace("certs")); err != nil {
+		t.Fatal(err)
+	}
+	adopted, err := r.getAdoptedSecretName(ctx, hp, getCertificateSecretName(r, hp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted != "old-default-foo" {
+		t.Errorf("adopted Secret after handover = %q, want old-default-foo", adopted)
+	}
+}
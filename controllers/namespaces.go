@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// allowedNamespacesKey is the context key of allowedNamespaces.
+type allowedNamespacesKey struct{}
+
+// allowedNamespaceKey identifies a namespace evaluated for DNSEndpoints or for Certificates.
+type allowedNamespaceKey struct {
+	issuer    bool
+	namespace string
+}
+
+// allowedNamespaces memoizes the namespaces evaluated by isAllowedNamespace during a reconciliation,
+// so that the child resources are placed consistently even if the labels of a namespace change in the middle of it.
+type allowedNamespaces map[allowedNamespaceKey]bool
+
+// withAllowedNamespaces returns a context carrying an empty allowedNamespaces.
+func withAllowedNamespaces(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowedNamespacesKey{}, allowedNamespaces{})
+}
+
+// isAllowedDNSNamespace returns true if DNSEndpoints can be placed in ns via dnsNamespaceAnnotation.
+func (r *HTTPProxyReconciler) isAllowedDNSNamespace(ctx context.Context, ns string) (bool, error) {
+	return r.isAllowedNamespace(ctx, allowedNamespaceKey{namespace: ns}, r.AllowedDNSNamespaces, r.AllowedDNSNamespaceSelector)
+}
+
+// isAllowedIssuerNamespace returns true if Certificates can be placed in ns via issuerNamespaceAnnotation.
+func (r *HTTPProxyReconciler) isAllowedIssuerNamespace(ctx context.Context, ns string) (bool, error) {
+	return r.isAllowedNamespace(ctx, allowedNamespaceKey{issuer: true, namespace: ns}, r.AllowedIssuerNamespaces, r.AllowedIssuerNamespaceSelector)
+}
+
+// isAllowedNamespace returns true if the namespace of key is listed in names or its labels match selector.
+// The labels are read from the informer cache of Namespaces, which is started by watchNamespaceSelectors.
+// A namespace that does not exist is not allowed. The result is memoized if ctx carries allowedNamespaces.
+func (r *HTTPProxyReconciler) isAllowedNamespace(ctx context.Context, key allowedNamespaceKey, names []string, selector labels.Selector) (bool, error) {
+	if slices.Contains(names, key.namespace) {
+		return true, nil
+	}
+	if selector == nil || key.namespace == "" {
+		return false, nil
+	}
+
+	memo, _ := ctx.Value(allowedNamespacesKey{}).(allowedNamespaces)
+	if allowed, ok := memo[key]; ok {
+		return allowed, nil
+	}
+	var namespace corev1.Namespace
+	err := r.Get(ctx, client.ObjectKey{Name: key.namespace}, &namespace)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	allowed := err == nil && selector.Matches(labels.Set(namespace.Labels))
+	if memo != nil {
+		memo[key] = allowed
+	}
+	return allowed, nil
+}
+
+// usesNamespaceSelectors returns true if allowed namespaces are selected by labels.
+func (r *HTTPProxyReconciler) usesNamespaceSelectors() bool {
+	return r.AllowedDNSNamespaceSelector != nil || r.AllowedIssuerNamespaceSelector != nil
+}
+
+// watchNamespaceSelectors adds a watch for Namespaces to the controller builder
+// so that the owners referring to a namespace via annotations are reconciled when its labels are changed.
+// newList returns an empty list of the owners.
+func (r *HTTPProxyReconciler) watchNamespaceSelectors(b *builder.Builder, newList func() client.ObjectList) *builder.Builder {
+	if !r.usesNamespaceSelectors() {
+		return b
+	}
+
+	listReferring := func(ctx context.Context, ns client.Object) []reconcile.Request {
+		return r.listRequestsFunc(ctx, newList(), func(owner client.Object) bool {
+			annotations := owner.GetAnnotations()
+			return annotations[dnsNamespaceAnnotation] == ns.GetName() || annotations[issuerNamespaceAnnotation] == ns.GetName()
+		})
+	}
+	return b.Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(listReferring), builder.WithPredicates(ignoreInitialCreateEvent, predicate.LabelChangedPredicate{}))
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestIsAllowedNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "shared", Labels: map[string]string{"shared": "true"}}},
+			&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "private"}},
+		).
+		Build()
+
+	dnsSelector, err := labels.Parse("shared=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &HTTPProxyReconciler{
+		Client: c,
+		ReconcilerOptions: ReconcilerOptions{
+			AllowedDNSNamespaces:        []string{"listed"},
+			AllowedDNSNamespaceSelector: dnsSelector,
+			AllowedIssuerNamespaces:     []string{"shared"},
+		},
+	}
+
+	tests := []struct {
+		namespace    string
+		expectDNS    bool
+		expectIssuer bool
+	}{
+		{namespace: "listed", expectDNS: true},
+		{namespace: "shared", expectDNS: true, expectIssuer: true},
+		{namespace: "private"},
+		{namespace: "missing"},
+		{namespace: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			got, err := r.isAllowedDNSNamespace(context.Background(), tt.namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expectDNS {
+				t.Errorf("isAllowedDNSNamespace(%q) = %v, want %v", tt.namespace, got, tt.expectDNS)
+			}
+			got, err = r.isAllowedIssuerNamespace(context.Background(), tt.namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expectIssuer {
+				t.Errorf("isAllowedIssuerNamespace(%q) = %v, want %v", tt.namespace, got, tt.expectIssuer)
+			}
+		})
+	}
+}
+
+func TestIsAllowedNamespaceMemoized(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+	errGet := errors.New("unavailable")
+	var failing bool
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "shared", Labels: map[string]string{"shared": "true"}}}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if failing {
+					return errGet
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).
+		Build()
+
+	selector, err := labels.Parse("shared=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &HTTPProxyReconciler{
+		Client:            c,
+		ReconcilerOptions: ReconcilerOptions{AllowedDNSNamespaceSelector: selector},
+	}
+
+	// the error is returned instead of treating the namespace as not allowed
+	failing = true
+	if _, err := r.isAllowedDNSNamespace(context.Background(), "shared"); !errors.Is(err, errGet) {
+		t.Errorf("isAllowedDNSNamespace() error = %v, want %v", err, errGet)
+	}
+
+	// the namespace is evaluated once during a reconciliation
+	ctx := withAllowedNamespaces(context.Background())
+	failing = false
+	if allowed, err := r.isAllowedDNSNamespace(ctx, "shared"); err != nil || !allowed {
+		t.Fatalf("isAllowedDNSNamespace() = %v, %v, want true", allowed, err)
+	}
+	failing = true
+	if allowed, err := r.isAllowedDNSNamespace(ctx, "shared"); err != nil || !allowed {
+		t.Errorf("memoized isAllowedDNSNamespace() = %v, %v, want true", allowed, err)
+	}
+}
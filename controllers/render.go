@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// renderResult is the result of renderHTTPProxy.
+type renderResult struct {
+	// objects are the child resources that would be applied, with the ownership set
+	objects []client.Object
+	// desired are the child resources kept by the reconciliation as desiredChildren returns
+	desired map[childKey]bool
+	// notes explain why child resources are not generated
+	notes []string
+}
+
+// Render returns the DNSEndpoints, the Certificate and the TLSCertificateDelegation that would be applied for hp,
+// in this order, without accessing the API server. serviceIPs and serviceHostnames are used as the addresses
+// of the load balancer unless hp specifies the targets of DNS records by the annotation.
+//
+// The checks that require the API server are not done: FQDN conflicts are not evaluated, DomainPolicy is
+// replaced with nil rules that permit every hostname, issuer and delegated domain, and the namespaces are
+// allowed only by AllowedDNSNamespaces and AllowedIssuerNamespaces.
+func Render(hp *projectcontourv1.HTTPProxy, opts ReconcilerOptions, scheme *runtime.Scheme, serviceIPs []net.IP, serviceHostnames []string, log logr.Logger) ([]client.Object, error) {
+	opts.AllowedDNSNamespaceSelector = nil
+	opts.AllowedIssuerNamespaceSelector = nil
+	r := &HTTPProxyReconciler{
+		ReconcilerOptions: opts,
+		Log:               log,
+		Scheme:            scheme,
+	}
+
+	// nil rules permit everything as if DomainPolicy were not enabled
+	result, err := r.renderHTTPProxy(context.Background(), hp, nil, serviceIPs, serviceHostnames, log)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range result.notes {
+		log.Info(note)
+	}
+	return result.objects, nil
+}
+
+// renderHTTPProxy follows the branches of Reconcile for hp without accessing the API server
+// except for evaluating the namespace selectors, if any.
+// rules are the DomainPolicy rules for the namespace of hp; nil permits everything. FQDN conflicts are not evaluated.
+func (r *HTTPProxyReconciler) renderHTTPProxy(ctx context.Context, hp *projectcontourv1.HTTPProxy, rules *domainPolicyRules, serviceIPs []net.IP, serviceHostnames []string, log logr.Logger) (*renderResult, error) {
+	result := &renderResult{}
+	note := func(format string, args ...interface{}) {
+		result.notes = append(result.notes, fmt.Sprintf(format, args...))
+	}
+	add := func(obj client.Object) error {
+		if err := r.setResourceOwnership(hp, obj); err != nil {
+			return err
+		}
+		result.objects = append(result.objects, obj)
+		return nil
+	}
+
+	if hp.Annotations[excludeAnnotation] == "true" {
+		note("excluded by the %s annotation", excludeAnnotation)
+		return result, nil
+	}
+	if !r.watchesAllIngressClasses() && !r.isClassNameMatched(hp) {
+		note("the ingress class is not watched")
+		return result, nil
+	}
+
+	if ns, ok := hp.Annotations[dnsNamespaceAnnotation]; ok && ns != "" && ns != hp.Namespace {
+		allowed, err := r.isAllowedDNSNamespace(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			note("%s: namespace %q is not allowed, so DNSEndpoints are placed in %q", dnsNamespaceAnnotation, ns, hp.Namespace)
+		}
+	}
+	if ns, ok := hp.Annotations[issuerNamespaceAnnotation]; ok && ns != "" && ns != hp.Namespace {
+		allowed, err := r.isAllowedIssuerNamespace(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			note("%s: namespace %q is not allowed, so the Certificate is placed in %q", issuerNamespaceAnnotation, ns, hp.Namespace)
+		}
+	}
+
+	hostnames := proxyHostnames(hp)
+	if len(hostnames) == 0 {
+		note("no resources are generated because spec.virtualhost.fqdn is empty")
+	}
+	dnsHostnames, certHostnames := r.expandHostnames(hp, hostnames, log)
+	for _, hostname := range certHostnames {
+		if !rules.permitsHostname(hostname) {
+			note("hostname %q is not permitted by DomainPolicy", hostname)
+		}
+	}
+	dnsHostnames, certHostnames = r.filterPermittedHostnames(hp, rules, dnsHostnames, certHostnames, log)
+	secretName := getCertificateSecretName(r, hp)
+	desired, err := r.desiredChildren(ctx, hp, hostnames, secretName)
+	if err != nil {
+		return nil, err
+	}
+	result.desired = desired
+
+	if r.CreateDNSEndpoint && len(dnsHostnames) != 0 {
+		ips, lbHostnames, err := r.getDNSTargets(hp)
+		var serviceErr error
+		if err == nil && len(ips) == 0 && len(lbHostnames) == 0 {
+			_, serviceErr = r.getTargetServiceKey(hp)
+			ips, lbHostnames = serviceIPs, serviceHostnames
+		}
+		switch {
+		case err != nil:
+			note("DNSEndpoint is not generated because of the invalid %s annotation: %v", dnsTargetsAnnotation, err)
+		case serviceErr != nil:
+			note("DNSEndpoint is not generated because of the invalid %s annotation: %v", targetServiceAnnotation, serviceErr)
+		case len(ips) == 0 && len(lbHostnames) == 0:
+			note("DNSEndpoint is not generated because the load balancer has no IP address or hostname")
+		default:
+			de, err := r.buildDNSEndpoint(ctx, hp, dnsHostnames, ips, lbHostnames, log)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(de); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if delegatedDomain := r.getDelegatedDomain(hp); r.CreateDNSEndpoint && delegatedDomain != "" && len(certHostnames) != 0 {
+		if !rules.permitsDelegatedDomain(delegatedDomain, certHostnames) {
+			note("delegation DNSEndpoint is not generated because delegated domain %q is not permitted by DomainPolicy", delegatedDomain)
+		} else {
+			de, err := r.buildDelegationDNSEndpoint(ctx, hp, certHostnames, delegatedDomain, log)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(de); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if r.CreateCertificate && len(certHostnames) != 0 {
+		issuerKind, issuerName := r.getIssuer(hp)
+		switch {
+		case hp.Annotations[testACMETLSAnnotation] != "true":
+			note("Certificate is not generated because the %s annotation is not \"true\"", testACMETLSAnnotation)
+		case secretName == "":
+			note("Certificate is not generated because spec.virtualhost.tls.secretName is empty")
+		case issuerName == "":
+			note("Certificate is not generated because no issuer is specified")
+		case !rules.permitsIssuer(issuerKind, issuerName, certHostnames):
+			note("Certificate is not generated because %s %q is not permitted by DomainPolicy", issuerKind, issuerName)
+		default:
+			cert, err := r.buildCertificate(ctx, hp, certHostnames, secretName, issuerKind, issuerName, log)
+			if err != nil {
+				return nil, err
+			}
+			if cert == nil {
+				note("Certificate is not generated because of an invalid annotation")
+				break
+			}
+			if strings.Contains(secretName, "/") {
+				note("spec.virtualhost.tls.secretName %q is namespaced, but it is used as is for the Secret of the Certificate", secretName)
+			}
+			if err := add(cert); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	namespace, err := r.getDelegationNamespace(ctx, hp)
+	if err != nil {
+		return nil, err
+	}
+	if namespace != "" {
+		if err := add(r.buildTLSCertificateDelegation(hp, namespace, getCertificateName(r, hp))); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRender(t *testing.T) {
+	scheme := runtime.NewScheme()
+	SetupScheme(scheme)
+
+	opts := ReconcilerOptions{
+		Prefix:                  "prefix-",
+		DefaultIssuerKind:       ClusterIssuerKind,
+		DefaultIssuerName:       "letsencrypt",
+		DefaultDelegatedDomain:  "acme.example.net",
+		AllowedIssuerNamespaces: []string{"certs"},
+		CreateDNSEndpoint:       true,
+		CreateCertificate:       true,
+	}
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+
+	hp := newDummyHTTPProxy(client.ObjectKey{Namespace: "default", Name: "foo"})
+	objs, err := Render(hp, opts, scheme, ips, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, obj := range objs {
+		got = append(got, obj.GetObjectKind().GroupVersionKind().Kind+" "+obj.GetNamespace()+"/"+obj.GetName())
+		if obj.GetAnnotations()[ownerAnnotation] != "default/foo" {
+			t.Errorf("%s has owner %q, want default/foo", obj.GetName(), obj.GetAnnotations()[ownerAnnotation])
+		}
+	}
+	expect := []string{
+		"DNSEndpoint default/prefix-foo",
+		"DNSEndpoint default/prefix-foo-delegation",
+		"Certificate default/prefix-foo",
+	}
+	if !slices.Equal(got, expect) {
+		t.Fatalf("Render() = %v, want %v", got, expect)
+	}
+
+	endpoints := objs[0].(*unstructured.Unstructured).Object["spec"].(map[string]interface{})["endpoints"].([]map[string]interface{})
+	if len(endpoints) != 1 || endpoints[0]["recordType"] != "A" {
+		t.Errorf("endpoints = %v, want an A record", endpoints)
+	}
+	cert := objs[2].(*cmv1.Certificate)
+	if !slices.Equal(cert.Spec.DNSNames, []string{dnsName}) || cert.Spec.SecretName != testSecretName || cert.Spec.IssuerRef.Name != "letsencrypt" {
+		t.Errorf("unexpected Certificate spec: %+v", cert.Spec)
+	}
+	if len(hp.Annotations) != 1 || len(hp.Finalizers) != 0 {
+		t.Errorf("HTTPProxy is modified: %+v", hp.ObjectMeta)
+	}
+
+	// the Certificate is placed in the issuer namespace and delegated back
+	hp.Annotations[issuerNamespaceAnnotation] = "certs"
+	objs, err = Render(hp, opts, scheme, ips, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, obj := range objs {
+		got = append(got, obj.GetObjectKind().GroupVersionKind().Kind+" "+obj.GetNamespace()+"/"+obj.GetName())
+	}
+	expect = []string{
+		"DNSEndpoint default/prefix-foo",
+		"DNSEndpoint default/prefix-foo-delegation",
+		"Certificate certs/prefix-default-foo",
+		"TLSCertificateDelegation certs/prefix-default-foo",
+	}
+	if !slices.Equal(got, expect) {
+		t.Fatalf("Render() = %v, want %v", got, expect)
+	}
+
+	// the addresses of the load balancer are not used for invalid DNS targets
+	hp.Annotations[dnsTargetsAnnotation] = "198.51.100.1"
+	objs, err = Render(hp, opts, scheme, ips, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().Kind == DNSEndpointKind && obj.GetName() == "prefix-foo" {
+			t.Errorf("Render() with invalid DNS targets rendered DNSEndpoint %s", obj.GetName())
+		}
+	}
+	delete(hp.Annotations, dnsTargetsAnnotation)
+
+	// the addresses of the load balancer are not used for an invalid target Service either
+	hp.Annotations[targetServiceAnnotation] = "ingress/envoy-other"
+	objs, err = Render(hp, opts, scheme, ips, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().Kind == DNSEndpointKind && obj.GetName() == "prefix-foo" {
+			t.Errorf("Render() with invalid target Service rendered DNSEndpoint %s", obj.GetName())
+		}
+	}
+	delete(hp.Annotations, targetServiceAnnotation)
+
+	// nothing is rendered without the addresses of the load balancer or for excluded HTTPProxies
+	delete(hp.Annotations, issuerNamespaceAnnotation)
+	opts.DefaultDelegatedDomain = ""
+	objs, err = Render(hp, opts, scheme, nil, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetObjectKind().GroupVersionKind().Kind != CertificateKind {
+		t.Errorf("Render() without addresses = %v, want only Certificate", objs)
+	}
+	hp.Annotations[excludeAnnotation] = "true"
+	objs, err = Render(hp, opts, scheme, ips, nil, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 0 {
+		t.Errorf("Render() for excluded HTTPProxy = %v, want none", objs)
+	}
+}
@@ -1,16 +1,23 @@
 package controllers
 
 import (
+	"net"
 	"time"
 
 	cmapiv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -18,6 +25,8 @@ import (
 type ReconcilerOptions struct {
 	ServiceKey                     client.ObjectKey
 	Prefix                         string
+	PreviousPrefix                 string
+	MigrateNamePrefix              bool
 	DefaultIssuerName              string
 	DefaultIssuerKind              string
 	DefaultDelegatedDomain         string
@@ -27,13 +36,32 @@ type ReconcilerOptions struct {
 	CreateDNSEndpoint              bool
 	CreateCertificate              bool
 	IngressClassName               string
+	IngressClassServiceKeys        map[string]client.ObjectKey
+	AllowedTargetServices          []client.ObjectKey
+	AllowedDNSTargetCIDRs          []*net.IPNet
+	AllowedDNSTargetDomains        []string
 	PropagatedAnnotations          []string
 	PropagatedLabels               []string
 	AllowedDNSNamespaces           []string
 	AllowedIssuerNamespaces        []string
+	AllowedDNSNamespaceSelector    labels.Selector
+	AllowedIssuerNamespaceSelector labels.Selector
 	CertificateApplyLimit          float64
+	CertificateApplyIssuerLimits   map[string]float64
 	CertificateApplyRetryBaseDelay time.Duration
 	CertificateApplyRetryMaxDelay  time.Duration
+	DNSEndpointApplyLimit          float64
+	DNSEndpointApplyRetryBaseDelay time.Duration
+	DNSEndpointApplyRetryMaxDelay  time.Duration
+	WatchHTTPRoute                 bool
+	DefaultDNSTTL                  int64
+	AllowedAdditionalDNSDomains    []string
+	DefaultCertificateDuration     time.Duration
+	DefaultCertificateRenewBefore  time.Duration
+	FQDNConflictPolicy             string
+	EnableDomainPolicy             bool
+	DryRun                         bool
+	GarbageCollectionInterval      time.Duration
 }
 
 // SetupScheme initializes a schema
@@ -41,32 +69,52 @@ func SetupScheme(scm *runtime.Scheme) {
 	utilruntime.Must(clientgoscheme.AddToScheme(scm))
 	utilruntime.Must(projectcontourv1.AddToScheme(scm))
 	utilruntime.Must(cmapiv1.AddToScheme(scm))
+	utilruntime.Must(gatewayv1.Install(scm))
+	utilruntime.Must(contourplusv1alpha1.AddToScheme(scm))
 
 	// +kubebuilder:scaffold:scheme
 }
 
 // SetupReconciler initializes reconcilers
 func SetupReconciler(mgr manager.Manager, scheme *runtime.Scheme, opts ReconcilerOptions) error {
+	// the workers and the reconcilers share the client so that each change in dry-run mode is reported once
+	c, recorder := setupClient(mgr, opts)
 	var certWorker Applier[*cmapiv1.Certificate]
-	if opts.CertificateApplyLimit > 0 {
-		certWorker = NewCertificateApplyWorker(mgr.GetClient(), opts)
+	if opts.CertificateApplyLimit > 0 || len(opts.CertificateApplyIssuerLimits) > 0 {
+		certWorker = NewCertificateApplyWorker(c, recorder, opts)
+	} else {
+		certWorker = NewCertificateApplier(c)
+	}
+	var dnsWorker Applier[*unstructured.Unstructured]
+	if opts.DNSEndpointApplyLimit > 0 {
+		dnsWorker = NewDNSEndpointApplyWorker(c, recorder, opts)
 	} else {
-		certWorker = NewCertificateApplier(mgr.GetClient())
+		dnsWorker = NewDNSEndpointApplier(c)
 	}
-	_, err := SetupAndGetReconciler(mgr, scheme, opts, certWorker)
+	_, err := setupReconcilers(mgr, scheme, opts, c, recorder, certWorker, dnsWorker)
 
 	// +kubebuilder:scaffold:builder
 	return err
 }
 
 // SetupAndGetReconciler initializes reconcilers and return the reconciler struct
-func SetupAndGetReconciler(mgr manager.Manager, scheme *runtime.Scheme, opts ReconcilerOptions, certWorker Applier[*cmapiv1.Certificate]) (*HTTPProxyReconciler, error) {
+func SetupAndGetReconciler(mgr manager.Manager, scheme *runtime.Scheme, opts ReconcilerOptions, certWorker Applier[*cmapiv1.Certificate], dnsWorker Applier[*unstructured.Unstructured]) (*HTTPProxyReconciler, error) {
+	c, recorder := setupClient(mgr, opts)
+	return setupReconcilers(mgr, scheme, opts, c, recorder, certWorker, dnsWorker)
+}
+
+// setupReconcilers initializes reconcilers with the client and the event recorder returned by setupClient.
+func setupReconcilers(mgr manager.Manager, scheme *runtime.Scheme, opts ReconcilerOptions, c client.Client, recorder events.EventRecorder, certWorker Applier[*cmapiv1.Certificate], dnsWorker Applier[*unstructured.Unstructured]) (*HTTPProxyReconciler, error) {
 	httpProxyReconciler := &HTTPProxyReconciler{
-		Client:            mgr.GetClient(),
+		Client:            c,
 		Log:               ctrl.Log.WithName("controllers").WithName("HTTPProxy"),
 		Scheme:            scheme,
+		ChildReader:       mgr.GetCache(),
+		APIReader:         mgr.GetAPIReader(),
+		Recorder:          recorder,
 		ReconcilerOptions: opts,
 		CertApplier:       certWorker,
+		DNSApplier:        dnsWorker,
 	}
 
 	err := httpProxyReconciler.SetupWithManager(mgr)
@@ -74,5 +122,33 @@ func SetupAndGetReconciler(mgr manager.Manager, scheme *runtime.Scheme, opts Rec
 		return nil, err
 	}
 
+	if opts.WatchHTTPRoute {
+		httpRouteReconciler := &HTTPRouteReconciler{
+			HTTPProxyReconciler: &HTTPProxyReconciler{
+				Client:            c,
+				Log:               ctrl.Log.WithName("controllers").WithName("HTTPRoute"),
+				Scheme:            scheme,
+				ChildReader:       mgr.GetCache(),
+				APIReader:         mgr.GetAPIReader(),
+				Recorder:          recorder,
+				ReconcilerOptions: opts,
+				CertApplier:       certWorker,
+				DNSApplier:        dnsWorker,
+			},
+		}
+		if err := httpRouteReconciler.SetupWithManager(mgr); err != nil {
+			return nil, err
+		}
+	}
+
 	return httpProxyReconciler, nil
 }
+
+// setupClient returns the client and the event recorder for the reconcilers and the workers.
+// In dry-run mode, the client makes changes in dry-run mode only, and no events are recorded.
+func setupClient(mgr manager.Manager, opts ReconcilerOptions) (client.Client, events.EventRecorder) {
+	if opts.DryRun {
+		return newDryRunClient(mgr.GetClient()), nil
+	}
+	return mgr.GetClient(), mgr.GetEventRecorder(eventRecorderName)
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// statusFieldManager is the field manager of statusAnnotation.
+// It differs from that of the other fields so that applying the status does not remove them, and vice versa.
+const statusFieldManager = "contour-plus-status"
+
+// ownerStatus is the value of statusAnnotation.
+type ownerStatus struct {
+	// Children are the child resources generated for the owner
+	Children []childStatus `json:"children,omitempty"`
+	// LastReconcileTime is the time of the last reconciliation
+	LastReconcileTime metav1.Time `json:"lastReconcileTime"`
+	// LastTransitionTime is the time when the fields other than the times last changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// LastError is the error of the last reconciliation, if any
+	LastError string `json:"lastError,omitempty"`
+	// SkipReason is the first reason why child resources were not generated in the last reconciliation, if any
+	SkipReason skipReason `json:"skipReason,omitempty"`
+
+	// childrenSet is true if Children are set by setChildren during the reconciliation
+	childrenSet bool
+}
+
+type childStatus struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type ownerStatusKey struct{}
+
+// withOwnerStatus returns a context carrying an empty ownerStatus, which is filled during the reconciliation.
+func withOwnerStatus(ctx context.Context) (context.Context, *ownerStatus) {
+	status := &ownerStatus{}
+	return context.WithValue(ctx, ownerStatusKey{}, status), status
+}
+
+// setSkipReason sets the skip reason of the ownerStatus in ctx unless it is already set.
+func setSkipReason(ctx context.Context, reason skipReason) {
+	status, ok := ctx.Value(ownerStatusKey{}).(*ownerStatus)
+	if !ok || status.SkipReason != "" {
+		return
+	}
+	status.SkipReason = reason
+}
+
+// setChildren sets the children of the status from the desired child resources.
+func (s *ownerStatus) setChildren(desired map[childKey]bool) {
+	s.childrenSet = true
+	s.Children = nil
+	for key := range desired {
+		s.Children = append(s.Children, childStatus{Kind: key.Kind, Namespace: key.Namespace, Name: key.Name})
+	}
+	slices.SortFunc(s.Children, func(a, b childStatus) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+// applyStatusAnnotation applies statusAnnotation of the HTTPProxy with statusFieldManager.
+// specOrMetadataChanged ignores statusAnnotation so that this does not trigger another reconciliation.
+// If the reconciliation fails before the children are known, the children of the current status are kept.
+// LastTransitionTime is kept if the status is the same as the current one except the times.
+// Failures are only logged because the status is informational.
+// The status is not applied in dry-run mode because it changes on every reconciliation.
+func (r *HTTPProxyReconciler) applyStatusAnnotation(ctx context.Context, hp *projectcontourv1.HTTPProxy, status *ownerStatus, reconcileErr error, log logr.Logger) {
+	if r.DryRun {
+		return
+	}
+	if reconcileErr != nil {
+		status.LastError = reconcileErr.Error()
+	}
+	current, ok := hp.Annotations[statusAnnotation]
+	if ok && reconcileErr != nil && !status.childrenSet {
+		status.keepChildren(current)
+	}
+	status.LastReconcileTime = metav1.Now()
+	status.LastTransitionTime = status.LastReconcileTime
+	if ok {
+		status.keepTransitionTime(current)
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Error(err, "unable to marshal status")
+		return
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(projectcontourv1.GroupVersion.WithKind(HTTPProxyKind))
+	obj.SetNamespace(hp.Namespace)
+	obj.SetName(hp.Name)
+	// the UID makes the apply fail instead of creating the HTTPProxy again if it has been deleted
+	obj.SetUID(hp.UID)
+	obj.SetAnnotations(map[string]string{statusAnnotation: string(data)})
+
+	//lint:ignore SA1019 client.Apply migration deferred to cert-manager v1.20+ upgrade PR
+	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
+		Force:        ptr.To(true),
+		FieldManager: statusFieldManager,
+	})
+	if err != nil {
+		log.Error(err, "unable to apply status annotation")
+	}
+}
+
+// keepChildren sets the children of the status to those of the status encoded in value.
+// An invalid value is ignored.
+func (s *ownerStatus) keepChildren(value string) {
+	var current ownerStatus
+	if err := json.Unmarshal([]byte(value), &current); err != nil {
+		return
+	}
+	s.Children = current.Children
+}
+
+// keepTransitionTime sets LastTransitionTime of s to that of the status encoded in value
+// if they are the same except the times.
+// An invalid value is ignored.
+func (s *ownerStatus) keepTransitionTime(value string) {
+	var current ownerStatus
+	if err := json.Unmarshal([]byte(value), &current); err != nil {
+		return
+	}
+	current.LastReconcileTime = s.LastReconcileTime
+	current.childrenSet = s.childrenSet
+	transitionTime := current.LastTransitionTime
+	current.LastTransitionTime = s.LastTransitionTime
+	if reflect.DeepEqual(&current, s) && !transitionTime.IsZero() {
+		s.LastTransitionTime = transitionTime
+	}
+}
+
+// withoutStatusAnnotation returns annotations without statusAnnotation.
+func withoutStatusAnnotation(annotations map[string]string) map[string]string {
+	if _, ok := annotations[statusAnnotation]; !ok {
+		return annotations
+	}
+	filtered := make(map[string]string, len(annotations)-1)
+	for k, v := range annotations {
+		if k != statusAnnotation {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
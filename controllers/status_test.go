@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestApplyStatusAnnotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := projectcontourv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	hpKey := client.ObjectKey{Namespace: "default", Name: "foo"}
+	hp := newDummyHTTPProxy(hpKey)
+	hp.UID = "foo-uid"
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(hp).
+		Build()
+	r := &HTTPProxyReconciler{Client: c}
+
+	ctx, status := withOwnerStatus(context.Background())
+	recordSkip(ctx, hp, skipReasonDisallowedNamespace)
+	recordSkip(ctx, hp, skipReasonNoIssuer)
+	status.setChildren(map[childKey]bool{
+		{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: "dns", Name: "foo"}}:            true,
+		{Kind: CertificateKind, ObjectKey: hpKey}:                                                      true,
+		{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: "dns", Name: "foo-delegation"}}: true,
+	})
+	r.applyStatusAnnotation(ctx, hp, status, errors.New("something failed"), logr.Discard())
+
+	applied := new(projectcontourv1.HTTPProxy)
+	if err := c.Get(context.Background(), hpKey, applied); err != nil {
+		t.Fatal(err)
+	}
+	var got ownerStatus
+	if err := json.Unmarshal([]byte(applied.Annotations[statusAnnotation]), &got); err != nil {
+		t.Fatalf("invalid status annotation %q: %v", applied.Annotations[statusAnnotation], err)
+	}
+	expectChildren := []childStatus{
+		{Kind: CertificateKind, Namespace: "default", Name: "foo"},
+		{Kind: DNSEndpointKind, Namespace: "dns", Name: "foo"},
+		{Kind: DNSEndpointKind, Namespace: "dns", Name: "foo-delegation"},
+	}
+	if !slices.Equal(got.Children, expectChildren) {
+		t.Errorf("children = %v, want %v", got.Children, expectChildren)
+	}
+	if got.SkipReason != skipReasonDisallowedNamespace {
+		t.Errorf("skipReason = %q, want %q", got.SkipReason, skipReasonDisallowedNamespace)
+	}
+	if got.LastError != "something failed" {
+		t.Errorf("lastError = %q, want %q", got.LastError, "something failed")
+	}
+	if got.LastReconcileTime.IsZero() {
+		t.Error("lastReconcileTime is not set")
+	}
+	if !got.LastTransitionTime.Equal(&got.LastReconcileTime) {
+		t.Errorf("lastTransitionTime = %v, want %v", got.LastTransitionTime, got.LastReconcileTime)
+	}
+	// the other annotations are kept
+	if applied.Annotations[testACMETLSAnnotation] != "true" {
+		t.Errorf("annotations = %v, want %s kept", applied.Annotations, testACMETLSAnnotation)
+	}
+
+	// updates of the status annotation do not trigger reconciliation
+	if specOrMetadataChanged.Update(event.UpdateEvent{ObjectOld: hp, ObjectNew: applied}) {
+		t.Error("specOrMetadataChanged passed the update of the status annotation")
+	}
+	changed := applied.DeepCopy()
+	changed.Annotations[excludeAnnotation] = "true"
+	if !specOrMetadataChanged.Update(event.UpdateEvent{ObjectOld: applied, ObjectNew: changed}) {
+		t.Error("specOrMetadataChanged ignored the update of the other annotations")
+	}
+
+	// the same status is applied again with lastTransitionTime kept
+	transitionTime := metav1.NewTime(got.LastTransitionTime.Add(-time.Hour))
+	got.LastTransitionTime = transitionTime
+	data, err := json.Marshal(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied.Annotations[statusAnnotation] = string(data)
+	if err := c.Update(context.Background(), applied); err != nil {
+		t.Fatal(err)
+	}
+	decode := func(hp *projectcontourv1.HTTPProxy) ownerStatus {
+		t.Helper()
+		var status ownerStatus
+		if err := json.Unmarshal([]byte(hp.Annotations[statusAnnotation]), &status); err != nil {
+			t.Fatalf("invalid status annotation %q: %v", hp.Annotations[statusAnnotation], err)
+		}
+		return status
+	}
+	reapply := func(reconcileErr error) *projectcontourv1.HTTPProxy {
+		t.Helper()
+		ctx, status := withOwnerStatus(context.Background())
+		recordSkip(ctx, applied, skipReasonDisallowedNamespace)
+		status.setChildren(map[childKey]bool{
+			{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: "dns", Name: "foo"}}:            true,
+			{Kind: CertificateKind, ObjectKey: hpKey}:                                                      true,
+			{Kind: DNSEndpointKind, ObjectKey: client.ObjectKey{Namespace: "dns", Name: "foo-delegation"}}: true,
+		})
+		r.applyStatusAnnotation(ctx, applied, status, reconcileErr, logr.Discard())
+		hp := new(projectcontourv1.HTTPProxy)
+		if err := c.Get(context.Background(), hpKey, hp); err != nil {
+			t.Fatal(err)
+		}
+		return hp
+	}
+	reapplied := decode(reapply(errors.New("something failed")))
+	if !reapplied.LastTransitionTime.Equal(&transitionTime) {
+		t.Errorf("lastTransitionTime of unchanged status = %v, want %v", reapplied.LastTransitionTime, transitionTime)
+	}
+	if !reapplied.LastReconcileTime.After(transitionTime.Time) {
+		t.Errorf("lastReconcileTime of unchanged status = %v, want after %v", reapplied.LastReconcileTime, transitionTime)
+	}
+	reapplied = decode(reapply(nil))
+	if reapplied.LastError != "" || !reapplied.LastTransitionTime.Equal(&reapplied.LastReconcileTime) {
+		t.Errorf("changed status = %+v, want no lastError and lastTransitionTime updated", reapplied)
+	}
+
+	// the children are kept if the reconciliation fails before they are known
+	applied = new(projectcontourv1.HTTPProxy)
+	if err := c.Get(context.Background(), hpKey, applied); err != nil {
+		t.Fatal(err)
+	}
+	ctx, status = withOwnerStatus(context.Background())
+	r.applyStatusAnnotation(ctx, applied, status, errors.New("unable to get DomainPolicy"), logr.Discard())
+	failed := new(projectcontourv1.HTTPProxy)
+	if err := c.Get(context.Background(), hpKey, failed); err != nil {
+		t.Fatal(err)
+	}
+	got = ownerStatus{}
+	if err := json.Unmarshal([]byte(failed.Annotations[statusAnnotation]), &got); err != nil {
+		t.Fatalf("invalid status annotation %q: %v", failed.Annotations[statusAnnotation], err)
+	}
+	if !slices.Equal(got.Children, expectChildren) {
+		t.Errorf("children after failure = %v, want %v", got.Children, expectChildren)
+	}
+	if got.LastError != "unable to get DomainPolicy" {
+		t.Errorf("lastError = %q, want %q", got.LastError, "unable to get DomainPolicy")
+	}
+
+	// the children are cleared if the reconciliation succeeds without them
+	ctx, status = withOwnerStatus(context.Background())
+	recordSkip(ctx, failed, skipReasonExcluded)
+	r.applyStatusAnnotation(ctx, failed, status, nil, logr.Discard())
+	cleared := new(projectcontourv1.HTTPProxy)
+	if err := c.Get(context.Background(), hpKey, cleared); err != nil {
+		t.Fatal(err)
+	}
+	got = ownerStatus{}
+	if err := json.Unmarshal([]byte(cleared.Annotations[statusAnnotation]), &got); err != nil {
+		t.Fatalf("invalid status annotation %q: %v", cleared.Annotations[statusAnnotation], err)
+	}
+	if len(got.Children) != 0 {
+		t.Errorf("children = %v, want none", got.Children)
+	}
+	applied = cleared
+
+	// the deleted HTTPProxy is not created again
+	if err := c.Delete(context.Background(), applied); err != nil {
+		t.Fatal(err)
+	}
+	ctx, status = withOwnerStatus(context.Background())
+	r.applyStatusAnnotation(ctx, applied, status, nil, logr.Discard())
+	if err := c.Get(context.Background(), hpKey, new(projectcontourv1.HTTPProxy)); !apierrors.IsNotFound(err) {
+		t.Errorf("deleted HTTPProxy is created again: %v", err)
+	}
+}
@@ -48,7 +48,10 @@ var _ = BeforeSuite(func() {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "third")},
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "config", "crd", "bases"),
+			filepath.Join("..", "config", "crd", "third"),
+		},
 	}
 
 	c, err := testEnv.Start()
@@ -105,6 +108,10 @@ var _ = Describe("Test contour-plus", func() {
 	Context("httpproxy", testHTTPProxyReconcile)
 })
 
+var _ = Describe("Test contour-plus with HTTPRoute", func() {
+	Context("httproute", testHTTPRouteReconcile)
+})
+
 var _ = Describe("Test Certificate apply worker", func() {
 	Context("certificate-apply-worker", testCertificateApplyWorker)
 })